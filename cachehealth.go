@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// cacheFailureThreshold is how many cache errors in a row mark the cache as
+// unavailable. A single failed call is not worth a warning.
+const cacheFailureThreshold = 3
+
+// cacheHealth tracks consecutive cache errors, so an unavailable cache is logged
+// once instead of on every call.
+var cacheHealth struct {
+	sync.Mutex
+	failures int
+	degraded bool
+}
+
+// noteCacheResult records the outcome of a cache call. It logs when the cache
+// becomes unavailable and when it recovers.
+func noteCacheResult(err error) {
+	cacheHealth.Lock()
+	defer cacheHealth.Unlock()
+	if err == nil {
+		if cacheHealth.degraded {
+			pdk.Log(pdk.LogInfo, "Cache is available again")
+		}
+		cacheHealth.failures = 0
+		cacheHealth.degraded = false
+		return
+	}
+	cacheHealth.failures++
+	if cacheHealth.failures >= cacheFailureThreshold && !cacheHealth.degraded {
+		cacheHealth.degraded = true
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Cache is unavailable, keeping sequence numbers and resolved links in memory until it recovers: %v", err))
+	}
+}
+
+// maxLocalEntries bounds how many values the in-memory fallback holds. It is emptied
+// when full, as the values are only meant to bridge a cache outage.
+const maxLocalEntries = 256
+
+// localEntry is a value kept in memory while the cache can't hold it.
+type localEntry struct {
+	value   string
+	expires int64
+}
+
+// localCache holds values whose cache write failed. It only lives as long as this
+// plugin instance and is never seen by others, so it is a fallback used while the
+// cache errors, never a replacement for it: a working cache always wins.
+var localCache = struct {
+	sync.Mutex
+	entries map[string]localEntry
+}{entries: map[string]localEntry{}}
+
+// setLocal keeps a value in memory for ttl seconds.
+func setLocal(key, value string, ttl int64) {
+	localCache.Lock()
+	defer localCache.Unlock()
+	if len(localCache.entries) >= maxLocalEntries {
+		localCache.entries = map[string]localEntry{}
+	}
+	localCache.entries[key] = localEntry{value: value, expires: now().Unix() + ttl}
+}
+
+// getLocal returns a value kept in memory, if it hasn't expired.
+func getLocal(key string) (string, bool) {
+	localCache.Lock()
+	defer localCache.Unlock()
+	entry, ok := localCache.entries[key]
+	if !ok || now().Unix() >= entry.expires {
+		delete(localCache.entries, key)
+		return "", false
+	}
+	return entry.value, true
+}
+
+// removeLocal forgets a value kept in memory.
+func removeLocal(key string) {
+	localCache.Lock()
+	delete(localCache.entries, key)
+	localCache.Unlock()
+}
+
+// cacheGetString reads a string from the cache. When the cache errors, a value this
+// instance kept in memory is returned instead, so lookups aren't repeated every call.
+func cacheGetString(key string) (string, bool, error) {
+	value, exists, err := host.CacheGetString(key)
+	noteCacheResult(err)
+	if err != nil {
+		if local, ok := getLocal(key); ok {
+			return local, true, nil
+		}
+	}
+	return value, exists, err
+}
+
+// cacheSetString writes a string to the cache, keeping it in memory when that fails.
+func cacheSetString(key, value string, ttl int64) error {
+	err := host.CacheSetString(key, value, ttl)
+	noteCacheResult(err)
+	if err != nil {
+		setLocal(key, value, ttl)
+		return err
+	}
+	removeLocal(key)
+	return nil
+}
+
+// cacheRemove removes a key from the cache and from memory.
+func cacheRemove(key string) {
+	_ = host.CacheRemove(key)
+	removeLocal(key)
+}
+
+// seqKey returns the cache key holding the last sequence number of a connection.
+func seqKey(connID string) string {
+	return fmt.Sprintf("discord.seq.%s", connID)
+}
+
+// storeSeq saves the last sequence number received on a connection, in memory when
+// the cache is unavailable, so this instance's heartbeats keep working.
+func storeSeq(connID string, seq int64) {
+	ttl := int64(heartbeatInterval * 2)
+	err := host.CacheSetInt(seqKey(connID), seq, ttl)
+	noteCacheResult(err)
+	if err != nil {
+		setLocal(seqKey(connID), strconv.FormatInt(seq, 10), ttl)
+		return
+	}
+	removeLocal(seqKey(connID))
+}
+
+// loadSeq returns the last sequence number of a connection. When the cache fails, a
+// sequence number kept in memory is used instead; without one, the error fails the
+// heartbeat, which counts towards the heartbeat failure tolerance.
+func loadSeq(connID string) (int64, error) {
+	seq, _, err := host.CacheGetInt(seqKey(connID))
+	noteCacheResult(err)
+	if err == nil {
+		return seq, nil
+	}
+	if local, ok := getLocal(seqKey(connID)); ok {
+		if seq, parseErr := strconv.ParseInt(local, 10, 64); parseErr == nil {
+			return seq, nil
+		}
+	}
+	return 0, err
+}
+
+// removeSeq forgets the sequence number of a closed connection.
+func removeSeq(connID string) {
+	cacheRemove(seqKey(connID))
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
@@ -12,19 +18,47 @@ import (
 
 // Cache TTLs for cover art lookups
 const (
-	caaCacheTTLHit  int64 = 24 * 60 * 60 // 24 hours for resolved CAA artwork
-	caaCacheTTLMiss int64 = 4 * 60 * 60  // 4 hours for CAA misses
-	uguuCacheTTL    int64 = 150 * 60     // 2.5 hours for uguu.se uploads
+	caaCacheTTLHit  int64 = 24 * 60 * 60      // 24 hours for resolved CAA artwork
+	caaCacheTTLMiss int64 = 4 * 60 * 60       // 4 hours for CAA misses
+	uguuCacheTTL    int64 = 150 * 60          // 2.5 hours for uguu.se uploads
+	catboxCacheTTL  int64 = 30 * 24 * 60 * 60 // 30 days for catbox.moe uploads, which don't expire
 
 	caaTimeOut = 4000 // 4 seconds timeout for CAA HEAD requests to avoid blocking NowPlaying
 )
 
+// Size bounds for track artwork requested from Navidrome, in pixels. The small image
+// slot only ever shows static icons, so only the large image size is configurable.
+const (
+	defaultLargeImageSize int32 = 300
+	maxLargeImageSize     int32 = 1024
+)
+
+// resolveLargeImageSize returns the configured artwork size for the large image.
+// Invalid values fall back to the default; oversized ones are capped, as Discord
+// doesn't render the large image any bigger.
+func resolveLargeImageSize() int32 {
+	option, _ := pdk.GetConfig(largeImageSizeKey)
+	option = strings.TrimSpace(option)
+	if option == "" {
+		return defaultLargeImageSize
+	}
+	size, err := strconv.Atoi(option)
+	if err != nil || size <= 0 {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid large image size %q, using %d", option, defaultLargeImageSize))
+		return defaultLargeImageSize
+	}
+	return int32(min(size, int(maxLargeImageSize)))
+}
+
+// caaDefaultBaseURL is the public Cover Art Archive, used unless caabaseurl points to a mirror.
+const caaDefaultBaseURL = "https://coverartarchive.org"
+
 // headCoverArt sends a HEAD request to the given CAA URL without following redirects.
 // Returns (location, true) on 307 with a Location header (image exists),
 // ("", true) on 404 (definitive miss — safe to cache),
 // ("", false) on network errors or unexpected responses (transient — do not cache).
 func headCoverArt(url string) (string, bool) {
-	resp, err := host.HTTPSend(host.HTTPRequest{
+	resp, err := httpSendWithRetry(host.HTTPRequest{
 		Method:            "HEAD",
 		URL:               url,
 		NoFollowRedirects: true,
@@ -48,8 +82,67 @@ func headCoverArt(url string) (string, bool) {
 	return location, true
 }
 
+// coverArtImage is an image listed for a release by the Cover Art Archive.
+type coverArtImage struct {
+	Front      bool              `json:"front"`
+	Back       bool              `json:"back"`
+	Image      string            `json:"image"`
+	Thumbnails map[string]string `json:"thumbnails"`
+}
+
+// pickCoverArtImage chooses the image to show from a release's images: the front
+// cover, then the back cover, then the first image of any type. It returns the
+// 250px thumbnail, or the full image when there is none.
+// Returns "" only when there are no images.
+func pickCoverArtImage(images []coverArtImage) string {
+	if len(images) == 0 {
+		return ""
+	}
+	chosen := images[0]
+	if i := slices.IndexFunc(images, func(img coverArtImage) bool { return img.Front }); i >= 0 {
+		chosen = images[i]
+	} else if i := slices.IndexFunc(images, func(img coverArtImage) bool { return img.Back }); i >= 0 {
+		chosen = images[i]
+	}
+	if thumbnail := chosen.Thumbnails["250"]; thumbnail != "" {
+		return thumbnail
+	}
+	return chosen.Image
+}
+
+// listCoverArt fetches the list of images for a release. Like headCoverArt, it
+// reports whether a miss is definitive.
+func listCoverArt(url string) (string, bool) {
+	resp, err := httpSendWithRetry(host.HTTPRequest{
+		Method:    "GET",
+		URL:       url,
+		Headers:   map[string]string{"Accept": "application/json"},
+		TimeoutMs: caaTimeOut,
+	})
+	if err != nil {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("CAA listing request failed for %s: %v", url, err))
+		return "", false
+	}
+	if resp.StatusCode == 404 {
+		return "", true
+	}
+	if resp.StatusCode != 200 {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("CAA listing unexpected status %d for %s", resp.StatusCode, url))
+		return "", false
+	}
+	var listing struct {
+		Images []coverArtImage `json:"images"`
+	}
+	if err := json.Unmarshal(resp.Body, &listing); err != nil {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Failed to parse CAA listing for %s: %v", url, err))
+		return "", false
+	}
+	return pickCoverArtImage(listing.Images), true
+}
+
 // getImageViaCoverArt checks the Cover Art Archive for album artwork.
-// Tries the release first, then falls back to the release group.
+// Tries the release's front cover first, then the release group's, then any
+// other image of the release.
 // Returns the archive.org image URL on success, "" on failure.
 func getImageViaCoverArt(mbzAlbumID, mbzReleaseGroupID string) string {
 	if mbzAlbumID == "" && mbzReleaseGroupID == "" {
@@ -70,15 +163,21 @@ func getImageViaCoverArt(mbzAlbumID, mbzReleaseGroupID string) string {
 	}
 
 	// Try release first
+	baseURL := configBaseURL(caaBaseURLKey, caaDefaultBaseURL)
 	var imageURL string
 	definitive := false
 	if mbzAlbumID != "" {
-		imageURL, definitive = headCoverArt(fmt.Sprintf("https://coverartarchive.org/release/%s/front-500", mbzAlbumID))
+		imageURL, definitive = headCoverArt(fmt.Sprintf("%s/release/%s/front-500", baseURL, mbzAlbumID))
 	}
 
 	// Fall back to release group
 	if imageURL == "" && mbzReleaseGroupID != "" {
-		imageURL, definitive = headCoverArt(fmt.Sprintf("https://coverartarchive.org/release-group/%s/front-500", mbzReleaseGroupID))
+		imageURL, definitive = headCoverArt(fmt.Sprintf("%s/release-group/%s/front-500", baseURL, mbzReleaseGroupID))
+	}
+
+	// Fall back to any other image of the release, such as the back cover
+	if imageURL == "" && definitive && mbzAlbumID != "" {
+		imageURL, definitive = listCoverArt(fmt.Sprintf("%s/release/%s", baseURL, mbzAlbumID))
 	}
 
 	// Cache hits always; only cache misses if the response was definitive (404),
@@ -104,27 +203,248 @@ type uguuResponse struct {
 	} `json:"files"`
 }
 
-// getImageURL retrieves the track artwork URL, checking CAA first if enabled,
-// then uguu.se, then direct Navidrome URL.
-func getImageURL(username string, track scrobbler.TrackInfo) string {
+// Artwork providers, reported by getImageURL
+const (
+	imageProviderCAA       = "caa"
+	imageProviderUguu      = "uguu"
+	imageProviderCatbox    = "catbox"
+	imageProviderNavidrome = "navidrome"
+)
+
+// Image hosts selectable with imagehost
+const (
+	imageHostUguu   = "uguu"
+	imageHostCatbox = "catbox"
+	imageHostDirect = "direct"
+)
+
+// configuredImageHost returns the explicitly configured image host, or "" when
+// imagehost is unset or unknown.
+func configuredImageHost() string {
+	switch imageHost, _ := pdk.GetConfig(imageHostKey); imageHost {
+	case imageHostUguu, imageHostCatbox, imageHostDirect:
+		return imageHost
+	case "":
+	default:
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown image host %q, ignoring", imageHost))
+	}
+	return ""
+}
+
+// resolveImageHost returns where Navidrome artwork is served from. Without an
+// imagehost setting, the older uguu.se toggle decides.
+func resolveImageHost() string {
+	if imageHost := configuredImageHost(); imageHost != "" {
+		return imageHost
+	}
+	if uguuEnabled, _ := pdk.GetConfig(uguuEnabledKey); uguuEnabled == "true" {
+		return imageHostUguu
+	}
+	return imageHostDirect
+}
+
+// Values of the publicinstance option
+const (
+	publicInstanceYes  = "true"
+	publicInstanceNo   = "false"
+	publicInstanceAuto = "auto"
+)
+
+// resolvePublicInstance reports whether Discord can load artwork straight from the
+// Navidrome instance. known is false when publicinstance is unset, leaving the image
+// options to decide on their own. In auto mode, the instance is public when its
+// artwork URLs point to a host reachable from the internet.
+func resolvePublicInstance(trackID string) (public, known bool) {
+	switch option, _ := pdk.GetConfig(publicInstanceKey); option {
+	case publicInstanceYes:
+		return true, true
+	case publicInstanceNo:
+		return false, true
+	case publicInstanceAuto:
+		return detectPublicInstance(trackID), true
+	case "":
+	default:
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown public instance option %q, ignoring", option))
+	}
+	return false, false
+}
+
+// publicInstanceCacheKey is the cache key holding the auto-detected public instance decision.
+const publicInstanceCacheKey = "discord.publicinstance"
+
+// publicInstanceTTL is how long an auto-detected decision is reused, in seconds. The
+// artwork URLs only move when Navidrome's base URL changes, so most updates skip the lookup.
+const publicInstanceTTL int64 = 60 * 60
+
+// detectPublicInstance reports whether the instance's artwork URLs point to a host
+// reachable from the internet, reusing the cached decision while it is fresh. Failed
+// lookups count as private and aren't cached, so the next update tries again.
+func detectPublicInstance(trackID string) bool {
+	if cached, exists, err := host.CacheGetString(publicInstanceCacheKey); err == nil && exists && cached != "" {
+		return cached == publicInstanceYes
+	}
+	artworkURL, err := host.ArtworkGetTrackUrl(trackID, resolveLargeImageSize())
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to get artwork URL to detect a public instance: %v", err))
+		return false
+	}
+	public := isPublicURL(artworkURL)
+	_ = host.CacheSetString(publicInstanceCacheKey, strconv.FormatBool(public), publicInstanceTTL)
+	return public
+}
+
+// isPublicURL reports whether a URL points to a host Discord can reach: not a
+// loopback, private, or link-local address, nor a single-label or local-only name.
+func isPublicURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	hostname := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(hostname); ip != nil {
+		return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified()
+	}
+	if !strings.Contains(hostname, ".") {
+		return false
+	}
+	for _, suffix := range []string{".local", ".lan", ".internal", ".home.arpa", ".localhost"} {
+		if strings.HasSuffix(hostname, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveImageChain returns whether the Cover Art Archive is tried first and the
+// image host used after it. When the instance is known to be public or private, that
+// picks the defaults: direct artwork URLs for public instances, and the Cover Art
+// Archive followed by uguu.se for private ones. An explicit imagehost or an enabled
+// Cover Art Archive option still apply.
+func resolveImageChain(trackID string) (useCAA bool, imageHost string) {
 	caaEnabled, _ := pdk.GetConfig(caaEnabledKey)
-	if caaEnabled == "true" {
+	public, known := resolvePublicInstance(trackID)
+	if !known {
+		return caaEnabled == "true", resolveImageHost()
+	}
+	imageHost = configuredImageHost()
+	switch {
+	case imageHost != "":
+	case public:
+		imageHost = imageHostDirect
+	default:
+		imageHost = imageHostUguu
+	}
+	return caaEnabled == "true" || !public, imageHost
+}
+
+// getImageURL retrieves the track artwork URL, checking CAA first if enabled,
+// then the configured image host. It also returns the provider of the URL.
+func getImageURL(username string, track scrobbler.TrackInfo) (string, string) {
+	useCAA, imageHost := resolveImageChain(track.ID)
+	if useCAA {
 		if url := getImageViaCoverArt(track.MBZAlbumID, track.MBZReleaseGroupID); url != "" {
-			return url
+			return url, imageProviderCAA
 		}
 	}
 
-	uguuEnabled, _ := pdk.GetConfig(uguuEnabledKey)
-	if uguuEnabled == "true" {
-		return getImageViaUguu(username, track.ID)
+	switch imageHost {
+	case imageHostUguu:
+		return getImageViaUguu(username, track.ID, resolveLargeImageSize()), imageProviderUguu
+	case imageHostCatbox:
+		return getImageViaCatbox(username, track.ID), imageProviderCatbox
 	}
 
-	return getImageDirect(track.ID)
+	return getImageDirect(track.ID, resolveLargeImageSize()), imageProviderNavidrome
+}
+
+// isArtworkHidden reports whether the track's artwork must not be shown, because its
+// track ID or MusicBrainz release or release group ID is listed in hiddenalbums.
+// The presence text is unaffected.
+func isArtworkHidden(track scrobbler.TrackInfo) bool {
+	option, _ := pdk.GetConfig(hiddenAlbumsKey)
+	for _, id := range strings.FieldsFunc(option, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		if id == track.ID || id == track.MBZAlbumID || id == track.MBZReleaseGroupID {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveImageAttribution returns the attribution for artwork from the given
+// provider. Only Cover Art Archive artwork is attributed, when configured.
+func resolveImageAttribution(provider string) string {
+	if provider != imageProviderCAA {
+		return ""
+	}
+	attribution, _ := pdk.GetConfig(caaAttributionKey)
+	return strings.TrimSpace(attribution)
+}
+
+// sensitiveQueryParams lists query parameters that may carry credentials in artwork
+// URLs: Subsonic auth parameters plus common token/signature names.
+var sensitiveQueryParams = map[string]bool{
+	"t": true, "s": true, "p": true, "apikey": true, "jwt": true, "token": true,
+	"access_token": true, "auth": true, "key": true, "api_key": true, "sig": true, "signature": true,
+}
+
+// redactURL returns imageURL with credentials replaced, for use in log messages.
+// Sensitive query parameters, userinfo, and JWT path segments (as used by Navidrome's
+// public image URLs) are masked. The original URL is still what gets sent to Discord.
+func redactURL(imageURL string) string {
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return "[unparseable URL]"
+	}
+	if u.User != nil {
+		u.User = url.User("REDACTED")
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "eyJ") {
+			segments[i] = "REDACTED"
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			if sensitiveQueryParams[strings.ToLower(name)] {
+				query.Set(name, "REDACTED")
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// redactAsset returns a Discord image asset with credentials replaced, for use in log
+// messages. External assets (mp:external/...) embed the original image URL as path
+// segments, so JWT segments and query strings are masked there too.
+func redactAsset(asset string) string {
+	if !strings.HasPrefix(asset, "mp:") {
+		if asset == "" {
+			return ""
+		}
+		return redactURL(asset)
+	}
+	path, query, hasQuery := strings.Cut(asset, "?")
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "eyJ") {
+			segments[i] = "REDACTED"
+		}
+	}
+	path = strings.Join(segments, "/")
+	if hasQuery && query != "" {
+		path += "?REDACTED"
+	}
+	return path
 }
 
 // getImageDirect returns the artwork URL directly from Navidrome (current behavior).
-func getImageDirect(trackID string) string {
-	artworkURL, err := host.ArtworkGetTrackUrl(trackID, 300)
+func getImageDirect(trackID string, size int32) string {
+	artworkURL, err := host.ArtworkGetTrackUrl(trackID, size)
 	if err != nil {
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to get artwork URL: %v", err))
 		return ""
@@ -134,13 +454,14 @@ func getImageDirect(trackID string) string {
 	if strings.HasPrefix(artworkURL, "http://localhost") {
 		return ""
 	}
-	return artworkURL
+	return withSquareArtwork(artworkURL, resolveImageFit())
 }
 
 // getImageViaUguu fetches artwork and uploads it to uguu.se.
-func getImageViaUguu(username, trackID string) string {
+func getImageViaUguu(username, trackID string, size int32) string {
 	// Check cache first
-	cacheKey := fmt.Sprintf("uguu.artwork.%s", trackID)
+	fit := resolveImageFit()
+	cacheKey := fmt.Sprintf("uguu.artwork.%s.%d", trackID, size) + fitCacheSuffix(fit)
 	cachedURL, exists, err := host.CacheGetString(cacheKey)
 	if err == nil && exists {
 		pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for uguu artwork: %s", trackID))
@@ -148,12 +469,17 @@ func getImageViaUguu(username, trackID string) string {
 	}
 
 	// Fetch artwork data from Navidrome
-	contentType, data, err := host.SubsonicAPICallRaw(fmt.Sprintf("/getCoverArt?u=%s&id=%s&size=300", username, trackID))
+	contentType, data, err := host.SubsonicAPICallRaw(coverArtPath(username, trackID, size, fit))
 	if err != nil {
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to fetch artwork data: %v", err))
 		return ""
 	}
 
+	if err := checkArtwork(contentType, data); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Not uploading artwork for %s: %v", trackID, err))
+		return ""
+	}
+
 	// Upload to uguu.se
 	url, err := uploadToUguu(data, contentType)
 	if err != nil {
@@ -165,23 +491,77 @@ func getImageViaUguu(username, trackID string) string {
 	return url
 }
 
-// uploadToUguu uploads image data to uguu.se and returns the file URL.
-func uploadToUguu(imageData []byte, contentType string) (string, error) {
-	// Build multipart/form-data body manually (TinyGo-compatible)
-	boundary := "----NavidromeCoverArt"
+// imageExtensions maps the artwork types Navidrome serves to upload file extensions.
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// imageContentType returns the type of artwork data. The type reported by Navidrome
+// depends on the source file and the instance's configuration, so it is trusted when
+// it names a known image type; otherwise the data is sniffed, defaulting to JPEG.
+func imageContentType(contentType string, data []byte) string {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if _, ok := imageExtensions[contentType]; ok {
+		return contentType
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return "image/gif"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
+
+// checkArtwork rejects artwork Discord can't render before it is uploaded, such as
+// error pages or SVG images returned in place of the cover.
+func checkArtwork(contentType string, data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("artwork is empty")
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if _, ok := imageExtensions[mediaType]; !ok {
+		return fmt.Errorf("unsupported artwork type %q", contentType)
+	}
+	return nil
+}
+
+// multipartBoundary separates the parts of artwork upload bodies.
+const multipartBoundary = "----NavidromeCoverArt"
+
+// multipartImageBody builds a multipart/form-data body manually (TinyGo-compatible)
+// holding the plain form fields, in order, followed by the image in fileField.
+func multipartImageBody(fileField string, imageData []byte, contentType string, fields [][2]string) []byte {
 	var body []byte
-	body = append(body, []byte(fmt.Sprintf("--%s\r\n", boundary))...)
-	body = append(body, []byte(fmt.Sprintf("Content-Disposition: form-data; name=\"files[]\"; filename=\"cover.jpg\"\r\n"))...)
+	for _, field := range fields {
+		body = append(body, []byte(fmt.Sprintf("--%s\r\n", multipartBoundary))...)
+		body = append(body, []byte(fmt.Sprintf("Content-Disposition: form-data; name=%q\r\n\r\n", field[0]))...)
+		body = append(body, []byte(field[1]+"\r\n")...)
+	}
+	body = append(body, []byte(fmt.Sprintf("--%s\r\n", multipartBoundary))...)
+	contentType = imageContentType(contentType, imageData)
+	body = append(body, []byte(fmt.Sprintf("Content-Disposition: form-data; name=%q; filename=\"cover.%s\"\r\n", fileField, imageExtensions[contentType]))...)
 	body = append(body, []byte(fmt.Sprintf("Content-Type: %s\r\n", contentType))...)
 	body = append(body, []byte("\r\n")...)
 	body = append(body, imageData...)
-	body = append(body, []byte(fmt.Sprintf("\r\n--%s--\r\n", boundary))...)
+	body = append(body, []byte(fmt.Sprintf("\r\n--%s--\r\n", multipartBoundary))...)
+	return body
+}
 
+// uploadToUguu uploads image data to uguu.se and returns the file URL.
+func uploadToUguu(imageData []byte, contentType string) (string, error) {
 	resp, err := host.HTTPSend(host.HTTPRequest{
 		Method:  "POST",
 		URL:     "https://uguu.se/upload",
-		Headers: map[string]string{"Content-Type": fmt.Sprintf("multipart/form-data; boundary=%s", boundary)},
-		Body:    body,
+		Headers: map[string]string{"Content-Type": fmt.Sprintf("multipart/form-data; boundary=%s", multipartBoundary)},
+		Body:    multipartImageBody("files[]", imageData, contentType, nil),
 	})
 	if err != nil {
 		return "", fmt.Errorf("uguu.se upload failed: %w", err)
@@ -205,3 +585,58 @@ func uploadToUguu(imageData []byte, contentType string) (string, error) {
 
 	return result.Files[0].URL, nil
 }
+
+// getImageViaCatbox fetches artwork from Navidrome and uploads it to catbox.moe.
+// Unlike uguu.se, catbox.moe files don't expire, so uploads are cached for longer.
+func getImageViaCatbox(username, trackID string) string {
+	fit, size := resolveImageFit(), resolveLargeImageSize()
+	cacheKey := fmt.Sprintf("catbox.artwork.%s.%d", trackID, size) + fitCacheSuffix(fit)
+	cachedURL, exists, err := host.CacheGetString(cacheKey)
+	if err == nil && exists {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for catbox artwork: %s", trackID))
+		return cachedURL
+	}
+
+	contentType, data, err := host.SubsonicAPICallRaw(coverArtPath(username, trackID, size, fit))
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to fetch artwork data: %v", err))
+		return ""
+	}
+
+	if err := checkArtwork(contentType, data); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Not uploading artwork for %s: %v", trackID, err))
+		return ""
+	}
+
+	url, err := uploadToCatbox(data, contentType)
+	if err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to upload to catbox.moe: %v", err))
+		return ""
+	}
+
+	_ = host.CacheSetString(cacheKey, url, catboxCacheTTL)
+	return url
+}
+
+// uploadToCatbox uploads image data to catbox.moe and returns the file URL, which
+// the API returns as plain text.
+func uploadToCatbox(imageData []byte, contentType string) (string, error) {
+	resp, err := host.HTTPSend(host.HTTPRequest{
+		Method:  "POST",
+		URL:     "https://catbox.moe/user/api.php",
+		Headers: map[string]string{"Content-Type": fmt.Sprintf("multipart/form-data; boundary=%s", multipartBoundary)},
+		Body:    multipartImageBody("fileToUpload", imageData, contentType, [][2]string{{"reqtype", "fileupload"}}),
+	})
+	if err != nil {
+		return "", fmt.Errorf("catbox.moe upload failed: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("catbox.moe upload failed: HTTP %d", resp.StatusCode)
+	}
+
+	url := strings.TrimSpace(string(resp.Body))
+	if !strings.HasPrefix(url, "https://") {
+		return "", fmt.Errorf("catbox.moe returned an unexpected response: %q", url)
+	}
+	return url, nil
+}
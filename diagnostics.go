@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// lastErrorTTL is how long the last error is kept for a user: 7 days
+const lastErrorTTL int64 = 7 * 24 * 60 * 60
+
+// lastError is the most recent failure seen for a user, kept in cache so users and admin
+// tools can find out why presence isn't working without digging through the logs.
+type lastError struct {
+	Error string `json:"error"`
+	Time  int64  `json:"time"` // Unix seconds
+}
+
+func (e lastError) String() string {
+	return fmt.Sprintf("last error: %s at %s", e.Error, time.Unix(e.Time, 0).UTC().Format(time.RFC3339))
+}
+
+func lastErrorKey(username string) string {
+	return fmt.Sprintf("discord.lasterror.%s", username)
+}
+
+// recordLastError stores err as the last error for username.
+func recordLastError(username string, err error) {
+	b, _ := json.Marshal(lastError{Error: err.Error(), Time: now().Unix()})
+	if cacheErr := host.CacheSetString(lastErrorKey(username), string(b), lastErrorTTL); cacheErr != nil {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Failed to record last error for user %s: %v", username, cacheErr))
+	}
+}
+
+// getLastError returns the last error recorded for username, if any.
+func getLastError(username string) (lastError, bool) {
+	value, exists, err := host.CacheGetString(lastErrorKey(username))
+	if err != nil || !exists {
+		return lastError{}, false
+	}
+	var le lastError
+	if err := json.Unmarshal([]byte(value), &le); err != nil {
+		return lastError{}, false
+	}
+	return le, true
+}
+
+// Scheduler callback payload forcing a reconnect of the user named in the schedule ID
+const payloadForceReconnect = "force-reconnect"
+
+// forceReconnectScheduleIDPrefix prefixes the username in force-reconnect schedule IDs.
+const forceReconnectScheduleIDPrefix = "forcereconnect."
+
+// handleForceReconnectCallback forces a reconnect of the user named in the schedule ID.
+func (p *discordPlugin) handleForceReconnectCallback(scheduleID string) error {
+	return p.ForceReconnect(strings.TrimPrefix(scheduleID, forceReconnectScheduleIDPrefix))
+}
+
+// ForceReconnect replaces a user's gateway connection with a fresh one. It is a
+// recovery lever for support, for users whose presence is stuck, without restarting
+// Navidrome; operators reach it by scheduling the force-reconnect payload. The gateway
+// session is dropped first, so the new connection identifies instead of resuming a
+// possibly broken one, and the last presence sent to the user is restored once Discord
+// reports the new session ready.
+func (p *discordPlugin) ForceReconnect(username string) error {
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Forcing a reconnect for user %s", username))
+	rpc.clearSession(username)
+	if err := rpc.reconnect(username); err != nil {
+		recordLastError(username, err)
+		return err
+	}
+	return nil
+}
+
+// Scheduler callback payload clearing the presence of every configured user
+const payloadDisconnectAll = "disconnect-all"
+
+// handleDisconnectAllCallback clears the presence of every configured user and closes
+// their connections. Operators schedule it to clean up before a shutdown or config
+// change, so presences don't linger. Users without a live connection are skipped, and
+// a failure for one user doesn't keep the others connected.
+func (p *discordPlugin) handleDisconnectAllCallback() error {
+	_, users, err := getConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	usernames := make([]string, 0, len(users))
+	for username := range users {
+		usernames = append(usernames, username)
+	}
+	slices.Sort(usernames)
+
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Disconnecting all %d configured users", len(usernames)))
+	var errs []error
+	for _, username := range usernames {
+		cancelRetry(username)
+		cancelHeldClear(username)
+		cancelPendingPresence(username)
+		if state := rpc.connState(username); state != connStateReady && state != connStateConnecting {
+			pdk.Log(pdk.LogDebug, fmt.Sprintf("User %s is not connected, nothing to clear", username))
+			continue
+		}
+		if err := p.clearPresence(username); err != nil {
+			recordLastError(username, err)
+			errs = append(errs, fmt.Errorf("user %s: %w", username, err))
+		}
+	}
+	return errors.Join(errs...)
+}
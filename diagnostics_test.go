@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scheduler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("lastError", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	It("stores the last error per user and reads it back", func() {
+		pinClock(time.Unix(1714600000, 0))
+
+		var stored string
+		host.CacheMock.On("SetString", "discord.lasterror.alice", mock.Anything, lastErrorTTL).Run(func(args mock.Arguments) {
+			stored = args.String(1)
+		}).Return(nil)
+
+		recordLastError("alice", errors.New("4004 authentication failed"))
+		Expect(stored).To(ContainSubstring("4004 authentication failed"))
+
+		host.CacheMock.On("GetString", "discord.lasterror.alice").Return(stored, true, nil)
+		le, ok := getLastError("alice")
+		Expect(ok).To(BeTrue())
+		Expect(le.Error).To(Equal("4004 authentication failed"))
+		Expect(le.Time).To(Equal(int64(1714600000)))
+	})
+
+	It("reports nothing when no error was recorded", func() {
+		host.CacheMock.On("GetString", "discord.lasterror.bob").Return("", false, nil)
+
+		_, ok := getLastError("bob")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("ignores corrupted entries", func() {
+		host.CacheMock.On("GetString", "discord.lasterror.bob").Return("not json", true, nil)
+
+		_, ok := getLastError("bob")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("formats the error with its time", func() {
+		le := lastError{Error: "4004 authentication failed", Time: 1714600000}
+		Expect(le.String()).To(Equal("last error: 4004 authentication failed at 2024-05-01T21:46:40Z"))
+	})
+})
+
+var _ = Describe("ForceReconnect", func() {
+	var plugin discordPlugin
+	var sent []string
+
+	lastPresence := `{"v":1,"presence":{"activities":[{"name":"Test Song","type":2,"details":"Test Song","state":"Test Artist","application_id":"1234567890123456789","status_display_type":0,"timestamps":{"start":1714600000000},"assets":{"large_image":"mp:external/art","large_text":"Test Album"}}],"since":0,"status":"dnd","afk":false}}`
+
+	BeforeEach(func() {
+		plugin = discordPlugin{}
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.WebSocketMock.ExpectedCalls = nil
+		host.WebSocketMock.Calls = nil
+		host.SchedulerMock.ExpectedCalls = nil
+		host.SchedulerMock.Calls = nil
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+		pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+
+		host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+		host.CacheMock.On("SetInt", "discord.seq.testuser", mock.Anything, mock.Anything).Return(nil).Maybe()
+		host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+		host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Connection lost").Return(nil)
+		host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"url":"wss://gateway.discord.gg"}`)}, nil)
+		host.WebSocketMock.On("Connect", mock.Anything, mock.Anything, "testuser").Return("testuser", nil)
+		host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").Return("testuser", nil)
+		sent = nil
+		host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+			sent = append(sent, args.String(1))
+		}).Return(nil)
+	})
+
+	It("identifies on a new connection and restores the last presence once ready", func() {
+		host.CacheMock.On("GetString", "discord.lastpresence.testuser").Return(lastPresence, true, nil)
+		host.CacheMock.On("Remove", "discord.session.testuser").Return(nil)
+		host.CacheMock.On("SetString", "discord.session.testuser", mock.Anything, sessionTTL).Return(nil)
+		host.CacheMock.On("Remove", "discord.reconnects.testuser").Return(nil)
+		registerCacheDefaults()
+
+		Expect(plugin.ForceReconnect("testuser")).To(Succeed())
+		host.WebSocketMock.AssertCalled(GinkgoT(), "CloseConnection", "testuser", int32(1000), "Connection lost")
+		host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.session.testuser")
+		Expect(sent).To(HaveLen(1))
+		Expect(sent[0]).To(ContainSubstring(`"op":2`))
+
+		Expect(rpc.handleWebSocketMessage("testuser", `{"op":0,"t":"READY","s":1,"d":{"session_id":"abc123"}}`)).To(Succeed())
+		Expect(sent).To(HaveLen(2))
+		Expect(sent[1]).To(ContainSubstring(`"op":3`))
+		Expect(sent[1]).To(ContainSubstring(`"large_image":"mp:external/art"`))
+		Expect(sent[1]).To(ContainSubstring(`"details":"Test Song"`))
+	})
+
+	It("only reconnects when no presence was sent", func() {
+		host.CacheMock.On("SetString", "discord.session.testuser", mock.Anything, sessionTTL).Return(nil)
+		host.CacheMock.On("Remove", "discord.reconnects.testuser").Return(nil)
+		registerCacheDefaults()
+
+		Expect(plugin.ForceReconnect("testuser")).To(Succeed())
+		Expect(rpc.handleWebSocketMessage("testuser", `{"op":0,"t":"READY","s":1,"d":{"session_id":"abc123"}}`)).To(Succeed())
+		Expect(sent).To(HaveLen(1))
+		Expect(sent[0]).To(ContainSubstring(`"op":2`))
+	})
+
+	It("is reached through the force-reconnect scheduler payload", func() {
+		host.CacheMock.On("Remove", "discord.session.testuser").Return(nil)
+		registerCacheDefaults()
+
+		Expect(plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "forcereconnect.testuser", Payload: payloadForceReconnect})).To(Succeed())
+		host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.session.testuser")
+		host.WebSocketMock.AssertCalled(GinkgoT(), "CloseConnection", "testuser", int32(1000), "Connection lost")
+		host.WebSocketMock.AssertCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, "testuser")
+		Expect(sent).To(ConsistOf(ContainSubstring(`"op":2`)))
+	})
+
+	It("records the failure for unknown users", func() {
+		var recorded string
+		host.CacheMock.On("SetString", "discord.lasterror.otheruser", mock.Anything, lastErrorTTL).Run(func(args mock.Arguments) {
+			recorded = args.String(1)
+		}).Return(nil)
+		registerCacheDefaults()
+
+		Expect(plugin.ForceReconnect("otheruser")).To(MatchError(ContainSubstring("not authorized")))
+		Expect(recorded).To(ContainSubstring("not authorized"))
+		host.WebSocketMock.AssertNotCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, mock.Anything)
+	})
+})
+
+var _ = Describe("last presence", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		pinClock(time.Unix(1714600000, 0))
+	})
+
+	It("round-trips the presence and expires it when the track ends", func() {
+		var stored string
+		host.CacheMock.On("SetString", "discord.lastpresence.alice", mock.Anything, int64(120)).Run(func(args mock.Arguments) {
+			stored = args.String(1)
+		}).Return(nil)
+
+		presence := presencePayload{
+			Activities: []activity{{
+				Name:       "Test Song",
+				Details:    "Test Song",
+				Timestamps: activityTimestamps{Start: 1714599940000, End: 1714600120000},
+				Assets:     activityAssets{LargeImage: "mp:external/art"},
+				Party:      &activityParty{ID: "party"},
+			}},
+			Status: presenceStatusOnline,
+		}
+		storeLastPresence("alice", presence)
+
+		var envelope storedPresence
+		Expect(json.Unmarshal([]byte(stored), &envelope)).To(Succeed())
+		Expect(envelope.Version).To(Equal(lastPresenceVersion))
+		Expect(envelope.Presence).To(Equal(presence))
+
+		host.CacheMock.On("GetString", "discord.lastpresence.alice").Return(stored, true, nil)
+		restored, ok, err := loadLastPresence("alice")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(restored).To(Equal(presence))
+	})
+
+	DescribeTable("ignores presences it can't restore",
+		func(stored string) {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.On("GetString", "discord.lastpresence.alice").Return(stored, true, nil)
+			_, ok, err := loadLastPresence("alice")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		},
+		Entry("stored before the format was versioned", `{"activities":[{"name":"Test Song"}],"status":"online"}`),
+		Entry("stored in a newer format", `{"v":2,"presence":{"activities":[{"name":"Test Song"}]}}`),
+		Entry("without an activity", `{"v":1,"presence":{"activities":[]}}`),
+	)
+
+	It("reports presences that can't be parsed", func() {
+		host.CacheMock.On("GetString", "discord.lastpresence.alice").Return("not json", true, nil)
+		_, ok, err := loadLastPresence("alice")
+		Expect(err).To(MatchError(ContainSubstring("failed to parse last presence")))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("does not keep presences of tracks that already ended", func() {
+		storeLastPresence("alice", presencePayload{Activities: []activity{{
+			Timestamps: activityTimestamps{Start: 1714599000000, End: 1714599180000},
+		}}})
+		host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+	})
+})
+
+var _ = Describe("disconnect-all callback", func() {
+	var plugin discordPlugin
+
+	BeforeEach(func() {
+		plugin = discordPlugin{}
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.WebSocketMock.ExpectedCalls = nil
+		host.WebSocketMock.Calls = nil
+		host.SchedulerMock.ExpectedCalls = nil
+		host.SchedulerMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+		pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"alice","token":"token-a"},{"username":"bob","token":"token-b"},{"username":"carol","token":"token-c"}]`, true)
+	})
+
+	It("clears and disconnects every connected user, skipping users that were never connected", func() {
+		host.CacheMock.On("GetString", "discord.conn.alice").Return(connStateReady, true, nil)
+		host.CacheMock.On("GetString", "discord.conn.carol").Return(connStateConnecting, true, nil)
+		host.CacheMock.On("Remove", keyWithPrefix("discord.seq.")).Return(nil)
+		registerCacheDefaults()
+		host.WebSocketMock.On("SendText", mock.Anything, mock.MatchedBy(func(msg string) bool {
+			return strings.Contains(msg, `"op":3`) && strings.Contains(msg, `"activities":null`)
+		})).Return(nil)
+		host.WebSocketMock.On("CloseConnection", mock.Anything, int32(1000), "Navidrome disconnect").Return(nil)
+		host.SchedulerMock.On("CancelSchedule", mock.Anything).Return(nil)
+
+		Expect(plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "cleanup", Payload: payloadDisconnectAll})).To(Succeed())
+		host.WebSocketMock.AssertCalled(GinkgoT(), "CloseConnection", "alice", int32(1000), "Navidrome disconnect")
+		host.WebSocketMock.AssertCalled(GinkgoT(), "CloseConnection", "carol", int32(1000), "Navidrome disconnect")
+		host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", "bob", mock.Anything)
+		host.WebSocketMock.AssertNotCalled(GinkgoT(), "CloseConnection", "bob", mock.Anything, mock.Anything)
+		host.WebSocketMock.AssertNumberOfCalls(GinkgoT(), "SendText", 2)
+	})
+
+	It("keeps disconnecting the other users when one fails", func() {
+		host.CacheMock.On("GetString", keyWithPrefix("discord.conn.")).Return(connStateReady, true, nil)
+		host.CacheMock.On("Remove", keyWithPrefix("discord.seq.")).Return(nil)
+		registerCacheDefaults()
+		host.WebSocketMock.On("SendText", "alice", mock.Anything).Return(errors.New("connection closed"))
+		host.WebSocketMock.On("SendText", mock.Anything, mock.Anything).Return(nil)
+		host.WebSocketMock.On("CloseConnection", mock.Anything, int32(1000), "Navidrome disconnect").Return(nil)
+		host.SchedulerMock.On("CancelSchedule", mock.Anything).Return(nil)
+
+		err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "cleanup", Payload: payloadDisconnectAll})
+		Expect(err).To(MatchError(ContainSubstring("user alice")))
+		host.WebSocketMock.AssertNumberOfCalls(GinkgoT(), "CloseConnection", 3)
+	})
+})
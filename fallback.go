@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// customStatusFallbackThreshold is how many consecutive failures of the mechanism in
+// use a user can have before the other one is tried: a custom status in place of rich
+// presence, or rich presence again in place of a failing custom status.
+const customStatusFallbackThreshold = 3
+
+// presenceFailuresTTL bounds how long a run of failures is remembered: 1 hour
+const presenceFailuresTTL int64 = 60 * 60
+
+// customStatusTTL bounds how long a user stays on the custom status fallback without a
+// failure sending it, in seconds. Rich presence is tried again afterwards.
+const customStatusTTL int64 = 24 * 60 * 60
+
+// presenceFailuresKey returns the cache key counting a user's consecutive rich presence failures.
+func presenceFailuresKey(username string) string {
+	return fmt.Sprintf("discord.failures.%s", username)
+}
+
+// statusFailuresKey returns the cache key counting a user's consecutive custom status failures.
+func statusFailuresKey(username string) string {
+	return fmt.Sprintf("discord.statusfailures.%s", username)
+}
+
+// customStatusKey returns the cache key marking a user as switched to the custom status.
+func customStatusKey(username string) string {
+	return fmt.Sprintf("discord.customstatus.%s", username)
+}
+
+// recordFailure counts a failure under key, returning the number of consecutive failures.
+func recordFailure(key string) int64 {
+	failures, _, _ := host.CacheGetInt(key)
+	failures++
+	_ = host.CacheSetInt(key, failures, presenceFailuresTTL)
+	return failures
+}
+
+// resetFailures clears a run of failures counted under key.
+func resetFailures(key string) {
+	_ = host.CacheRemove(key)
+}
+
+// usingCustomStatus reports whether the user was switched to the custom status fallback.
+func usingCustomStatus(username string) bool {
+	_, exists, err := host.CacheGetInt(customStatusKey(username))
+	return err == nil && exists
+}
+
+// customStatusFallbackEnabled reports whether the custom status fallback is configured.
+func customStatusFallbackEnabled() bool {
+	enabled, _ := pdk.GetConfig(customStatusFallbackKey)
+	return enabled == "true"
+}
+
+// forgetSentActivity drops the fingerprint and the stored copy of the last rich presence
+// sent to a user. Once a custom status replaced it on Discord, the same activity must be
+// sent again in full rather than skipped as unchanged, and neither the presence refresh
+// nor a new connection may restore it over the custom status.
+func forgetSentActivity(username string) {
+	_ = host.CacheRemove(lastActivityKey(username))
+	_ = host.CacheRemove(lastPresenceKey(username))
+}
+
+// customStatusText describes the track for a custom status, which only shows one line.
+func customStatusText(track scrobbler.TrackInfo, paused bool) string {
+	if paused {
+		return fmt.Sprintf("Paused: %s by %s", track.Title, track.Artist)
+	}
+	return fmt.Sprintf("Listening to %s by %s", track.Title, track.Artist)
+}
+
+// sendWithFallback sends the rich presence activity. When enabled, repeated failures
+// fall back to a plain custom status, which carries no images or links that Discord
+// could reject, so the user still shows what they're listening to. Each mechanism
+// counts its own failures, and the one that works is kept until it fails repeatedly
+// in turn, which switches back to the other.
+func sendWithFallback(clientID, username, token string, data activity, opts activityOptions, track scrobbler.TrackInfo, paused bool) error {
+	enabled := customStatusFallbackEnabled()
+	if enabled && usingCustomStatus(username) {
+		statusErr := rpc.sendCustomStatus(username, customStatusText(track, paused), opts.Status)
+		if statusErr == nil {
+			resetFailures(statusFailuresKey(username))
+			forgetSentActivity(username)
+			return nil
+		}
+		failures := recordFailure(statusFailuresKey(username))
+		if failures < customStatusFallbackThreshold {
+			return statusErr
+		}
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Custom status failed %d times in a row for user %s, switching back to rich presence: %v", failures, username, statusErr))
+		_ = host.CacheRemove(customStatusKey(username))
+		resetFailures(statusFailuresKey(username))
+		forgetSentActivity(username)
+	}
+
+	err := rpc.sendActivity(clientID, username, token, data, opts)
+	if err == nil || errors.Is(err, errImageDeferred) {
+		resetFailures(presenceFailuresKey(username))
+		return err
+	}
+	if !enabled || errors.Is(err, errAuthFailed) {
+		return err
+	}
+	failures := recordFailure(presenceFailuresKey(username))
+	if failures < customStatusFallbackThreshold {
+		return err
+	}
+
+	pdk.Log(pdk.LogWarn, fmt.Sprintf("Rich presence failed %d times in a row for user %s, falling back to a custom status: %v", failures, username, err))
+	if statusErr := rpc.sendCustomStatus(username, customStatusText(track, paused), opts.Status); statusErr != nil {
+		return fmt.Errorf("%w; custom status fallback also failed: %w", err, statusErr)
+	}
+	resetFailures(presenceFailuresKey(username))
+	forgetSentActivity(username)
+	cancelPresenceRefresh(username)
+	_ = host.CacheSetInt(customStatusKey(username), now().Unix(), customStatusTTL)
+	recordLastError(username, fmt.Errorf("rich presence failed, showing a custom status: %w", err))
+	return nil
+}
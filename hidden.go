@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// normalizeName folds case and whitespace, so "The  Beatles" matches "the beatles".
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// parseNameList splits a comma-separated config option into normalized names.
+func parseNameList(option string) map[string]bool {
+	names := map[string]bool{}
+	for _, name := range strings.Split(option, ",") {
+		if name = normalizeName(name); name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// trackArtistNames returns every artist credited on the track: the individual track
+// and album artists, plus the formatted names for tracks without artist lists.
+func trackArtistNames(track scrobbler.TrackInfo) []string {
+	names := []string{track.Artist, track.AlbumArtist}
+	for _, a := range track.Artists {
+		names = append(names, a.Name)
+	}
+	for _, a := range track.AlbumArtists {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+// isTrackHidden reports whether the track must not be shown at all, because one of
+// its artists is listed in hideartists or one of its genres in hidegenres.
+func isTrackHidden(username string, track scrobbler.TrackInfo) bool {
+	option, _ := pdk.GetConfig(hideArtistsKey)
+	if hidden := parseNameList(option); len(hidden) > 0 {
+		for _, name := range trackArtistNames(track) {
+			if hidden[normalizeName(name)] {
+				return true
+			}
+		}
+	}
+
+	option, _ = pdk.GetConfig(hideGenresKey)
+	if hidden := parseNameList(option); len(hidden) > 0 && track.ID != "" {
+		song, _ := trackSong(username, track.ID)
+		for _, genre := range song.Genres {
+			if hidden[normalizeName(genre)] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Retry bounds for lookups against third-party services. Lookups run while a
+// presence update waits, so retries are few and short.
+const (
+	httpMaxAttempts    = 3                      // Attempts per request, including the first
+	httpRetryBaseDelay = 250 * time.Millisecond // Backoff before the first retry, doubled after each
+	httpMaxRetryAfter  = 2 * time.Second        // Longer Retry-After waits end the retries
+	httpMaxRetryTime   = 5 * time.Second        // No retry starts later than this after the first attempt
+)
+
+// isRetryableStatus reports whether a response status may succeed if retried:
+// rate limits and server errors.
+func isRetryableStatus(status int32) bool {
+	return status == 429 || status >= 500
+}
+
+// retryAfter returns the wait a response asks for in its Retry-After header, given
+// either in seconds or as an HTTP date. Returns ok=false when there is none.
+func retryAfter(resp *host.HTTPResponse) (time.Duration, bool) {
+	value := strings.TrimSpace(headerValue(resp.Headers, "Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if at, err := time.Parse(time.RFC1123, value); err == nil {
+		return max(at.Sub(now()), 0), true
+	}
+	return 0, false
+}
+
+// httpSendWithRetry sends a request, retrying network errors, rate limits and server
+// errors with exponential backoff. A Retry-After header replaces the backoff, and one
+// asking for more than httpMaxRetryAfter ends the retries, as does a retry that would
+// start more than httpMaxRetryTime after the first attempt, so slow responses can't
+// stack up behind a presence update. The last response or error is returned, so
+// callers handle a failure the same way with or without retries.
+func httpSendWithRetry(req host.HTTPRequest) (*host.HTTPResponse, error) {
+	delay := httpRetryBaseDelay
+	start := now()
+	for attempt := 1; ; attempt++ {
+		resp, err := host.HTTPSend(req)
+		if attempt >= httpMaxAttempts || (err == nil && !isRetryableStatus(resp.StatusCode)) {
+			return resp, err
+		}
+
+		wait, reason := delay, fmt.Sprint(err)
+		if err == nil {
+			reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+			if after, ok := retryAfter(resp); ok {
+				if after > httpMaxRetryAfter {
+					pdk.Log(pdk.LogDebug, fmt.Sprintf("Not retrying %s %s: Retry-After of %s is too long", req.Method, req.URL, after))
+					return resp, nil
+				}
+				wait = after
+			}
+		}
+		if now().Add(wait).Sub(start) > httpMaxRetryTime {
+			pdk.Log(pdk.LogDebug, fmt.Sprintf("Not retrying %s %s after %s: out of retry time", req.Method, req.URL, reason))
+			return resp, err
+		}
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Retrying %s %s in %s after %s (attempt %d of %d)", req.Method, req.URL, wait, reason, attempt+1, httpMaxAttempts))
+		sleep(wait)
+		delay *= 2
+	}
+}
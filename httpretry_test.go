@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("httpSendWithRetry", func() {
+	var slept []time.Duration
+
+	request := host.HTTPRequest{Method: "GET", URL: "https://labs.api.listenbrainz.org/test"}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		slept = nil
+		sleep = func(d time.Duration) { slept = append(slept, d) }
+	})
+
+	It("retries a server error until the request succeeds", func() {
+		host.HTTPMock.On("Send", request).Return(&host.HTTPResponse{StatusCode: 503}, nil).Once()
+		host.HTTPMock.On("Send", request).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte("ok")}, nil).Once()
+
+		resp, err := httpSendWithRetry(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(int32(200)))
+		Expect(string(resp.Body)).To(Equal("ok"))
+		Expect(slept).To(Equal([]time.Duration{httpRetryBaseDelay}))
+	})
+
+	It("returns the last response when server errors persist", func() {
+		host.HTTPMock.On("Send", request).Return(&host.HTTPResponse{StatusCode: 500}, nil)
+
+		resp, err := httpSendWithRetry(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(int32(500)))
+		host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", httpMaxAttempts)
+		Expect(slept).To(Equal([]time.Duration{httpRetryBaseDelay, 2 * httpRetryBaseDelay}))
+	})
+
+	It("returns the last error when network errors persist", func() {
+		host.HTTPMock.On("Send", request).Return((*host.HTTPResponse)(nil), errors.New("connection refused"))
+
+		_, err := httpSendWithRetry(request)
+		Expect(err).To(MatchError("connection refused"))
+		host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", httpMaxAttempts)
+	})
+
+	It("waits as long as Retry-After asks", func() {
+		host.HTTPMock.On("Send", request).Return(&host.HTTPResponse{StatusCode: 429, Headers: map[string]string{"retry-after": "1"}}, nil).Once()
+		host.HTTPMock.On("Send", request).Return(&host.HTTPResponse{StatusCode: 200}, nil).Once()
+
+		resp, err := httpSendWithRetry(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(int32(200)))
+		Expect(slept).To(Equal([]time.Duration{time.Second}))
+	})
+
+	It("gives up when Retry-After asks for too long", func() {
+		host.HTTPMock.On("Send", request).Return(&host.HTTPResponse{StatusCode: 429, Headers: map[string]string{"Retry-After": "60"}}, nil)
+
+		resp, err := httpSendWithRetry(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(int32(429)))
+		host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+		Expect(slept).To(BeEmpty())
+	})
+
+	It("stops retrying once the retry time is used up", func() {
+		clock := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+		original := now
+		now = func() time.Time { return clock }
+		DeferCleanup(func() { now = original })
+		// Each attempt times out after a while
+		host.HTTPMock.On("Send", request).Run(func(mock.Arguments) {
+			clock = clock.Add(3 * time.Second)
+		}).Return((*host.HTTPResponse)(nil), errors.New("timeout"))
+
+		_, err := httpSendWithRetry(request)
+		Expect(err).To(MatchError("timeout"))
+		host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 2)
+		Expect(slept).To(Equal([]time.Duration{httpRetryBaseDelay}))
+	})
+
+	It("doesn't retry client errors", func() {
+		host.HTTPMock.On("Send", request).Return(&host.HTTPResponse{StatusCode: 404}, nil)
+
+		resp, err := httpSendWithRetry(request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(int32(404)))
+		host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+	})
+
+	It("accepts Retry-After as an HTTP date", func() {
+		pinClock(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+		wait, ok := retryAfter(&host.HTTPResponse{Headers: map[string]string{"Retry-After": "Fri, 01 May 2026 12:00:02 UTC"}})
+		Expect(ok).To(BeTrue())
+		Expect(wait).To(Equal(2 * time.Second))
+	})
+})
+
+var _ = Describe("ListenBrainz lookups", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false).Maybe()
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+	})
+
+	It("survives a momentary server error", func() {
+		isMBIDLookup := mock.MatchedBy(func(req host.HTTPRequest) bool {
+			return req.URL == listenBrainzDefaultBaseURL+"/spotify-id-from-mbid/json" && req.TimeoutMs == listenBrainzTimeOut
+		})
+		host.HTTPMock.On("Send", isMBIDLookup).Return(&host.HTTPResponse{StatusCode: 503}, nil).Once()
+		host.HTTPMock.On("Send", isMBIDLookup).Return(&host.HTTPResponse{
+			StatusCode: 200,
+			Body:       []byte(`[{"spotify_track_ids":["4uLU6hMCjMI75M1A2tKUQC"]}]`),
+		}, nil).Once()
+
+		Expect(trySpotifyFromMBID("mbid-1")).To(Equal("4uLU6hMCjMI75M1A2tKUQC"))
+	})
+})
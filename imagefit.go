@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Image fit options, deciding how non-square artwork fills Discord's square image slot.
+// The plugin never decodes artwork itself; padding is left to Navidrome.
+const (
+	imageFitNone = "none" // Artwork is sent as is
+	imageFitPad  = "pad"  // Navidrome pads artwork to a square
+)
+
+// resolveImageFit returns the configured image fit, defaulting to none.
+func resolveImageFit() string {
+	option, _ := pdk.GetConfig(imageFitKey)
+	switch option = strings.ToLower(strings.TrimSpace(option)); option {
+	case imageFitPad, imageFitNone:
+		return option
+	case "":
+		return imageFitNone
+	}
+	pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown image fit %q, sending artwork as is", option))
+	return imageFitNone
+}
+
+// coverArtPath returns the Subsonic getCoverArt request for a track's artwork. Padding
+// is left to Navidrome, which letterboxes artwork onto a square canvas when asked to.
+func coverArtPath(username, trackID string, size int32, fit string) string {
+	path := fmt.Sprintf("/getCoverArt?u=%s&id=%s&size=%d", url.QueryEscape(username), url.QueryEscape(trackID), size)
+	if fit == imageFitPad {
+		path += "&square=true"
+	}
+	return path
+}
+
+// withSquareArtwork asks Navidrome for padded artwork on a direct artwork URL.
+func withSquareArtwork(artworkURL, fit string) string {
+	if fit != imageFitPad || artworkURL == "" {
+		return artworkURL
+	}
+	u, err := url.Parse(artworkURL)
+	if err != nil {
+		return artworkURL
+	}
+	q := u.Query()
+	q.Set("square", "true")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// fitCacheSuffix keeps uploads made with different fits apart in the cache.
+func fitCacheSuffix(fit string) string {
+	if fit == imageFitNone {
+		return ""
+	}
+	return "." + fit
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// testArtwork returns plain blue artwork of the given size.
+func testArtwork(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{B: 255, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	Expect(png.Encode(&buf, img)).To(Succeed())
+	return buf.Bytes()
+}
+
+var _ = Describe("image fit", func() {
+	BeforeEach(func() {
+		pdk.ResetMock()
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	DescribeTable("resolveImageFit",
+		func(option, expected string) {
+			pdk.PDKMock.On("GetConfig", imageFitKey).Return(option, option != "")
+			Expect(resolveImageFit()).To(Equal(expected))
+		},
+		Entry("defaults to none", "", imageFitNone),
+		Entry("pad", "pad", imageFitPad),
+		Entry("pad, ignoring case", " Pad ", imageFitPad),
+		Entry("unknown values", "stretch", imageFitNone),
+	)
+
+	Describe("padding", func() {
+		It("asks Navidrome for square artwork", func() {
+			Expect(coverArtPath("testuser", "track1", 300, imageFitPad)).To(Equal("/getCoverArt?u=testuser&id=track1&size=300&square=true"))
+			Expect(coverArtPath("testuser", "track1", 300, imageFitNone)).To(Equal("/getCoverArt?u=testuser&id=track1&size=300"))
+		})
+
+		It("escapes the username and track ID", func() {
+			Expect(coverArtPath("jane doe&u=admin", "al-1/2", 300, imageFitNone)).
+				To(Equal("/getCoverArt?u=jane+doe%26u%3Dadmin&id=al-1%2F2&size=300"))
+		})
+
+		It("adds the square option to direct artwork URLs", func() {
+			Expect(withSquareArtwork("https://music.example.com/share/img/abc?size=300", imageFitPad)).
+				To(Equal("https://music.example.com/share/img/abc?size=300&square=true"))
+			Expect(withSquareArtwork("https://music.example.com/share/img/abc?size=300", imageFitNone)).
+				To(Equal("https://music.example.com/share/img/abc?size=300"))
+		})
+	})
+
+	Describe("uploads", func() {
+		BeforeEach(func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.Calls = nil
+			host.SubsonicAPIMock.ExpectedCalls = nil
+			host.HTTPMock.ExpectedCalls = nil
+			host.HTTPMock.Calls = nil
+		})
+
+		It("keeps uploads of padded artwork apart in the cache", func() {
+			pdk.PDKMock.On("GetConfig", imageFitKey).Return("pad", true)
+			host.CacheMock.On("GetString", "uguu.artwork.track1.300.pad").Return("", false, nil)
+			host.SubsonicAPIMock.On("CallRaw", "/getCoverArt?u=testuser&id=track1&size=300&square=true").
+				Return("image/png", encodePNG(testArtwork(20, 20)), nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://uguu.se/upload"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"success":true,"files":[{"url":"https://a.uguu.se/padded.png"}]}`)}, nil)
+			host.CacheMock.On("SetString", "uguu.artwork.track1.300.pad", "https://a.uguu.se/padded.png", uguuCacheTTL).Return(nil)
+
+			Expect(getImageViaUguu("testuser", "track1", 300)).To(Equal("https://a.uguu.se/padded.png"))
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+	})
+})
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// listeningAlbumTTL bounds how long a user counts as listening to an album without
+// a new report, for tracks without a duration: 10 minutes
+const listeningAlbumTTL int64 = 10 * 60
+
+// listeningAlbumKey returns the cache key holding the album a user is listening to.
+func listeningAlbumKey(username string) string {
+	return fmt.Sprintf("discord.album.%s", username)
+}
+
+// albumIdentity identifies a track's album across users: the MusicBrainz release ID
+// when tagged, otherwise the album artist and name. Returns "" without an album.
+func albumIdentity(track scrobbler.TrackInfo) string {
+	if track.MBZAlbumID != "" {
+		return "mbid:" + track.MBZAlbumID
+	}
+	if track.Album == "" {
+		return ""
+	}
+	return hashKey(strings.ToLower(track.AlbumArtist) + "\x00" + strings.ToLower(track.Album))
+}
+
+// recordListeningAlbum marks the user as listening to the track's album until the
+// track would end.
+func recordListeningAlbum(username string, track scrobbler.TrackInfo, remainingMs int64) {
+	album := albumIdentity(track)
+	if album == "" {
+		_ = host.CacheRemove(listeningAlbumKey(username))
+		return
+	}
+	ttl := listeningAlbumTTL
+	if remainingMs > 0 {
+		ttl = remainingMs/1000 + 60
+	}
+	_ = host.CacheSetString(listeningAlbumKey(username), album, ttl)
+}
+
+// clearListeningAlbum stops counting the user as a listener.
+func clearListeningAlbum(username string) {
+	_ = host.CacheRemove(listeningAlbumKey(username))
+}
+
+// countAlbumListeners returns how many of the configured users are listening to the
+// given album, including the current one.
+func countAlbumListeners(users map[string]userConfig, album string) int {
+	count := 0
+	for username := range users {
+		current, exists, err := host.CacheGetString(listeningAlbumKey(username))
+		if err == nil && exists && current == album {
+			count++
+		}
+	}
+	return count
+}
+
+// withListenerCount appends how many users are listening to the same album to the
+// album text, when enabled and anyone else is. The user's own listen is recorded
+// first, so they're included in the count.
+func withListenerCount(albumText, username string, track scrobbler.TrackInfo, remainingMs int64) string {
+	enabled, _ := pdk.GetConfig(showListenersKey)
+	if enabled != "true" {
+		return albumText
+	}
+	recordListeningAlbum(username, track, remainingMs)
+	album := albumIdentity(track)
+	_, users, err := getConfig()
+	if album == "" || err != nil {
+		return albumText
+	}
+	count := countAlbumListeners(users, album)
+	if count < 2 || albumText == "" {
+		return albumText
+	}
+	return fmt.Sprintf("%s · %d listening", albumText, count)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("album listeners", func() {
+	track := scrobbler.TrackInfo{ID: "track1", Album: "Test Album", AlbumArtist: "Test Artist"}
+	album := albumIdentity(track)
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	DescribeTable("albumIdentity",
+		func(track scrobbler.TrackInfo, expected string) {
+			Expect(albumIdentity(track)).To(Equal(expected))
+		},
+		Entry("prefers the release MBID", scrobbler.TrackInfo{Album: "Test Album", MBZAlbumID: "release-1"}, "mbid:release-1"),
+		Entry("ignores case in names", scrobbler.TrackInfo{Album: "TEST ALBUM", AlbumArtist: "test artist"}, album),
+		Entry("is empty without an album", scrobbler.TrackInfo{Title: "Loose Track"}, ""),
+	)
+
+	It("counts the users listening to the same album", func() {
+		host.CacheMock.On("GetString", "discord.album.alice").Return(album, true, nil)
+		host.CacheMock.On("GetString", "discord.album.bob").Return(album, true, nil)
+		host.CacheMock.On("GetString", "discord.album.carol").Return("mbid:other", true, nil)
+		host.CacheMock.On("GetString", "discord.album.dave").Return("", false, nil)
+
+		users := map[string]userConfig{"alice": {Token: "t1"}, "bob": {Token: "t2"}, "carol": {Token: "t3"}, "dave": {Token: "t4"}}
+		Expect(countAlbumListeners(users, album)).To(Equal(2))
+	})
+
+	Describe("withListenerCount", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true).Maybe()
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"alice","token":"t1"},{"username":"bob","token":"t2"}]`, true).Maybe()
+		})
+
+		It("leaves the text alone when disabled", func() {
+			pdk.PDKMock.On("GetConfig", showListenersKey).Return("", false)
+
+			Expect(withListenerCount("Test Album", "alice", track, 60000)).To(Equal("Test Album"))
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		Context("when enabled", func() {
+			BeforeEach(func() {
+				pdk.PDKMock.On("GetConfig", showListenersKey).Return("true", true)
+				host.CacheMock.On("SetString", "discord.album.alice", album, int64(120)).Return(nil)
+				host.CacheMock.On("GetString", "discord.album.alice").Return(album, true, nil)
+			})
+
+			It("shows the count when others listen to the same album", func() {
+				host.CacheMock.On("GetString", "discord.album.bob").Return(album, true, nil)
+
+				Expect(withListenerCount("Test Album", "alice", track, 60000)).To(Equal("Test Album · 2 listening"))
+				host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.album.alice", album, int64(120))
+			})
+
+			It("shows nothing when the user listens alone", func() {
+				host.CacheMock.On("GetString", "discord.album.bob").Return("mbid:other", true, nil)
+
+				Expect(withListenerCount("Test Album", "alice", track, 60000)).To(Equal("Test Album"))
+			})
+		})
+	})
+})
@@ -12,9 +12,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
 	"github.com/navidrome/navidrome/plugins/pdk/go/scheduler"
 	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
@@ -23,19 +30,72 @@ import (
 
 // Configuration keys
 const (
-	clientIDKey             = "clientid"
-	usersKey                = "users"
-	activityNameKey         = "activityname"
-	activityNameTemplateKey = "activitynametemplate"
-	spotifyLinksKey         = "spotifylinks"
-	caaEnabledKey           = "caaenabled"
-	uguuEnabledKey          = "uguuenabled"
+	clientIDKey              = "clientid"
+	usersKey                 = "users"
+	activityNameKey          = "activityname"
+	activityNameTemplateKey  = "activitynametemplate"
+	spotifyLinksKey          = "spotifylinks"
+	caaEnabledKey            = "caaenabled"
+	uguuEnabledKey           = "uguuenabled"
+	defaultImageListeningKey = "defaultimagelistening"
+	defaultImagePlayingKey   = "defaultimageplaying"
+	defaultImageWatchingKey  = "defaultimagewatching"
+	noDefaultImageKey        = "nodefaultimage"
+	listenBrainzBaseURLKey   = "listenbrainzbaseurl"
+	caaBaseURLKey            = "caabaseurl"
+	showDiscNumberKey        = "showdiscnumber"
+	presenceStatusKey        = "presencestatus"
+	partyIDKey               = "partyid"
+	partySizeKey             = "partysize"
+	displayArtistKey         = "displayartist"
+	linkArtistKey            = "linkartist"
+	releaseMBIDLookupKey     = "releasembidlookup"
+	anchorPositionKey        = "anchorunknownposition"
+	spotifyMBIDLookupKey     = "spotifymbidlookup"
+	spotifyMetadataLookupKey = "spotifymetadatalookup"
+	spotifySearchFallbackKey = "spotifysearchfallback"
+	nameVerbKey              = "nameverb"
+	truncationKey            = "truncation"
+	losslessBadgeKey         = "losslessbadge"
+	caaAttributionKey        = "caaattribution"
+	largeImageSizeKey        = "largeimagesize"
+	activityTypeKey          = "activitytype"
+	hiddenAlbumsKey          = "hiddenalbums"
+	customStatusFallbackKey  = "customstatusfallback"
+	linkServiceKey           = "linkservice"
+	holdUntilScrobbleKey     = "holduntilscrobble"
+	reconnectOnErrorKey      = "reconnectonerror"
+	showListenersKey         = "showlisteners"
+	imageHostKey             = "imagehost"
+	showLabelKey             = "showlabel"
+	untaggedArtistKey        = "untaggedartist"
+	titleStripPatternsKey    = "titlestrippatterns"
+	pausedStatusKey          = "pausedstatus"
+	assetModeKey             = "assetmode"
+	transitionGraceKey       = "transitiongrace"
+	publicInstanceKey        = "publicinstance"
+	displayStripPatternsKey  = "displaystrippatterns"
+	assetFreshnessKey        = "assetfreshness"
+	hideArtistsKey           = "hideartists"
+	hideGenresKey            = "hidegenres"
+	imageFitKey              = "imagefit"
+	presenceRefreshKey       = "presencerefresh"
+	showScrobbledKey         = "showscrobbled"
+	artistSeparatorKey       = "artistseparator"
+	joinArtistsKey           = "joinartists"
+	assetAuthFailureKey      = "assetauthfailure"
+	detailsTemplateKey       = "detailstemplate"
+	stateTemplateKey         = "statetemplate"
+	minimumDisplaySecondsKey = "minimumdisplayseconds"
+	clearGraceKey            = "cleargrace"
+	heartbeatToleranceKey    = "heartbeatfailuretolerance"
 )
 
 const (
 	navidromeWebsiteURL = "https://www.navidrome.org"
 
-	// navidromeLogoURL is used as fallback large image when track artwork is unavailable.
+	// navidromeLogoURL is used as fallback large image when track artwork is unavailable
+	// and no default image is configured for the activity type.
 	navidromeLogoURL = "https://raw.githubusercontent.com/navidrome/website/refs/heads/master/assets/icons/logo.webp"
 
 	pauseIconURL = "https://raw.githubusercontent.com/navidrome/discord-rich-presence-plugin/800bfacfb8e85c33692373b10ddbd27388f262d2/assets/pause.png"
@@ -49,6 +109,12 @@ const (
 	stateExpired = "expired"
 )
 
+// Artist source options for display and link resolution
+const (
+	artistSourceTrack = "track"
+	artistSourceAlbum = "album"
+)
+
 // Activity name display options
 const (
 	activityNameDefault = "Default"
@@ -58,10 +124,13 @@ const (
 	activityNameCustom  = "Custom"
 )
 
-// userToken represents a user-token mapping from the config
-type userToken struct {
-	Username string `json:"username"`
-	Token    string `json:"token"`
+// userConfig is a user entry from the config: their Discord token, plus optional
+// settings overriding the global ones for that user's presence.
+type userConfig struct {
+	Username     string `json:"username"`
+	Token        string `json:"token"`
+	ActivityName string `json:"activityName,omitempty"` // Overrides activityname
+	Status       string `json:"status,omitempty"`       // Overrides presencestatus
 }
 
 // discordPlugin implements the scrobbler and scheduler interfaces.
@@ -70,6 +139,13 @@ type discordPlugin struct{}
 // rpc handles Discord gateway communication (via websockets).
 var rpc = &discordRPC{}
 
+// Clock, random source and sleep, replaceable in tests to pin timestamps and jitter.
+var (
+	now      = time.Now
+	randIntn = rand.Intn
+	sleep    = time.Sleep
+)
+
 // init registers the plugin capabilities
 func init() {
 	scrobbler.Register(&discordPlugin{})
@@ -77,13 +153,38 @@ func init() {
 	websocket.Register(rpc)
 }
 
+// errMissingClientID is returned when no Discord application ID is configured.
+var errMissingClientID = errors.New("missing ClientID in configuration")
+
+// errInvalidClientID is returned when the configured ClientID can't be a Discord
+// application ID, e.g. when a bot token or application name was pasted instead.
+var errInvalidClientID = errors.New("invalid ClientID in configuration")
+
+// isSnowflake reports whether value looks like a Discord ID: 17 to 20 digits.
+func isSnowflake(value string) bool {
+	if len(value) < 17 || len(value) > 20 {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 // getConfig loads the plugin configuration.
-func getConfig() (clientID string, users map[string]string, err error) {
+func getConfig() (clientID string, users map[string]userConfig, err error) {
 	clientID, ok := pdk.GetConfig(clientIDKey)
 	if !ok || clientID == "" {
 		pdk.Log(pdk.LogWarn, "missing ClientID in configuration")
 		return "", nil, nil
 	}
+	if !isSnowflake(clientID) {
+		// The value isn't logged, as it may be a pasted token
+		pdk.Log(pdk.LogError, fmt.Sprintf("ClientID must be the numeric Application ID of your Discord application (17-20 digits), got %d characters", len(clientID)))
+		return "", nil, errInvalidClientID
+	}
 
 	// Get the users array from config
 	usersJSON, ok := pdk.GetConfig(usersKey)
@@ -93,22 +194,22 @@ func getConfig() (clientID string, users map[string]string, err error) {
 	}
 
 	// Parse the JSON array
-	var userTokens []userToken
-	if err := json.Unmarshal([]byte(usersJSON), &userTokens); err != nil {
+	var entries []userConfig
+	if err := json.Unmarshal([]byte(usersJSON), &entries); err != nil {
 		pdk.Log(pdk.LogError, fmt.Sprintf("failed to parse users config: %v", err))
 		return clientID, nil, nil
 	}
 
-	if len(userTokens) == 0 {
+	if len(entries) == 0 {
 		pdk.Log(pdk.LogWarn, "no users configured")
 		return clientID, nil, nil
 	}
 
 	// Build the users map
-	users = make(map[string]string)
-	for _, ut := range userTokens {
-		if ut.Username != "" && ut.Token != "" {
-			users[ut.Username] = ut.Token
+	users = make(map[string]userConfig)
+	for _, entry := range entries {
+		if entry.Username != "" && entry.Token != "" {
+			users[entry.Username] = entry
 		}
 	}
 
@@ -117,9 +218,33 @@ func getConfig() (clientID string, users map[string]string, err error) {
 		return clientID, nil, nil
 	}
 
+	for _, shared := range sharedTokenUsers(users) {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("users %s share the same Discord token and will keep disconnecting each other; give each user their own token",
+			strings.Join(shared, ", ")))
+	}
+
 	return clientID, users, nil
 }
 
+// sharedTokenUsers returns the groups of users configured with the same Discord
+// token. Discord allows one gateway session per token, so these users would
+// replace each other's connection on every update.
+func sharedTokenUsers(users map[string]userConfig) [][]string {
+	byToken := make(map[string][]string)
+	for username, user := range users {
+		byToken[user.Token] = append(byToken[user.Token], username)
+	}
+	var shared [][]string
+	for _, usernames := range byToken {
+		if len(usernames) > 1 {
+			slices.Sort(usernames)
+			shared = append(shared, usernames)
+		}
+	}
+	slices.SortFunc(shared, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+	return shared
+}
+
 // ============================================================================
 // Scrobbler Implementation
 // ============================================================================
@@ -142,24 +267,36 @@ func (p *discordPlugin) NowPlaying(_ scrobbler.NowPlayingRequest) error {
 }
 
 // Scrobble handles scrobble requests (no-op for Discord).
-func (p *discordPlugin) Scrobble(_ scrobbler.ScrobbleRequest) error {
-	// Discord Rich Presence doesn't need scrobble events
+func (p *discordPlugin) Scrobble(input scrobbler.ScrobbleRequest) error {
+	// Scrobbles only matter for the optional scrobbled badge
+	recordScrobble(input)
 	return nil
 }
 
 // PlaybackReport handles playback state reports from Navidrome.
 func (p *discordPlugin) PlaybackReport(input scrobbler.PlaybackReportRequest) error {
 	pdk.Log(pdk.LogDebug, fmt.Sprintf("PlaybackReport request: %s", formatRequest(input)))
+	cancelRetry(input.Username)
+	cancelHeldClear(input.Username)
+	cancelPendingPresence(input.Username)
+	if deferPresence(input) {
+		return nil
+	}
+
+	var err error
 	switch input.State {
-	case statePlaying:
-		return p.handlePlayingOrPaused(input)
-	case statePaused:
-		return p.handlePlayingOrPaused(input)
+	case statePlaying, statePaused:
+		err = p.handlePlayingOrPaused(input, false)
+		if err != nil && isTransientError(err) {
+			scheduleRetry(input)
+		}
 	case stateStopped, stateExpired:
-		return p.handleStopped(input)
-	default:
-		return nil
+		err = p.handleStopped(input)
+	}
+	if err != nil {
+		recordLastError(input.Username, err)
 	}
+	return err
 }
 
 func formatRequest(input scrobbler.PlaybackReportRequest) string {
@@ -168,18 +305,62 @@ func formatRequest(input scrobbler.PlaybackReportRequest) string {
 		input.PositionMs, input.PlaybackRate, input.PlayerName)
 }
 
-func (p *discordPlugin) handlePlayingOrPaused(input scrobbler.PlaybackReportRequest) error {
+// handlePlayingOrPaused sets the presence for a playing or paused report. A retrying
+// report is the single retry of an earlier one, so nothing is deferred to another retry.
+func (p *discordPlugin) handlePlayingOrPaused(input scrobbler.PlaybackReportRequest, retrying bool) error {
+	if isTrackHidden(input.Username, input.Track) {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Hiding presence for user %s, track: %s", input.Username, input.Track.Title))
+		if !rpc.hasLiveConnection(input.Username) {
+			return nil
+		}
+		return p.clearPresence(input.Username)
+	}
+
 	paused := input.State == statePaused
 	pdk.Log(pdk.LogInfo, fmt.Sprintf("Setting presence for user %s, track: %s (paused=%v)", input.Username, input.Track.Title, paused))
 
-	clientID, userToken, err := connectUser(input.Username)
+	clientID, user, err := connectUser(input.Username)
+	if errors.Is(err, errAuthFailed) {
+		// The last error holds why Discord rejected the token, e.g. the close code
+		reason := err.Error()
+		if le, ok := getLastError(input.Username); ok {
+			reason = le.String()
+		}
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Skipping presence for user %s: %s", input.Username, reason))
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	activityName, statusDisplayType := resolveActivityName(input.Track)
-
-	spotifyURL, artistSearchURL := resolveSpotifyLinks(input.Track)
+	displayTrack := withDisplaySuffixesStripped(withArtistSource(input.Track, displayArtistKey))
+	titleOnly := isTitleOnly(input.Track)
+	activityType := resolveActivityType()
+	activityName, statusDisplayType := resolveActivityName(displayTrack, input.Username, user.ActivityName)
+	if titleOnly {
+		// Artist and album name options would leave the name empty
+		activityName, statusDisplayType = "Navidrome", statusDisplayDetails
+	}
+	if activityType == activityTypePlaying {
+		// The classic "Playing" style shows the activity name, like a game
+		statusDisplayType = statusDisplayName
+	}
+	activityName, statusDisplayType = withNameVerb(activityName, statusDisplayType, activityType)
+
+	// A title alone can't be matched reliably, so title-only tracks get no links
+	var spotifyURL, artistSearchURL string
+	var buttons []activityButton
+	if !titleOnly {
+		linkTrack := withArtistSource(input.Track, linkArtistKey)
+		spotifyURL, artistSearchURL = resolveSpotifyLinks(linkTrack)
+		buttons = listenButtons(linkTrack, spotifyURL)
+	}
+	state := displayArtist(displayTrack)
+	if titleOnly {
+		state = resolveUntaggedArtist()
+	}
+	details := resolveTextTemplate(detailsTemplateKey, displayTrack.Title, input.Username, displayTrack)
+	state = resolveTextTemplate(stateTemplateKey, state, input.Username, displayTrack)
 
 	rate := input.PlaybackRate
 	if rate <= 0 {
@@ -190,41 +371,145 @@ func (p *discordPlugin) handlePlayingOrPaused(input scrobbler.PlaybackReportRequ
 	wallElapsedMs := int64(float64(input.PositionMs) / rate)
 	wallDurationMs := int64(float64(int64(input.Track.Duration)*1000) / rate)
 
-	ts := activityTimestamps{
-		Start: input.Timestamp*1000 - wallElapsedMs,
-		End:   input.Timestamp*1000 - wallElapsedMs + wallDurationMs,
+	start := reportTimeMs(input) - wallElapsedMs
+	if !paused && input.PositionMs == 0 {
+		start = anchorUnknownPosition(input, start, wallDurationMs)
 	}
+	ts := activityTimestamps{Start: start}
+	if !isLiveStream(input.Track) {
+		ts.End = start + wallDurationMs
+	}
+	imageURL, imageProvider := resolveDefaultImage(activityType), ""
+	if !isArtworkHidden(input.Track) {
+		imageURL, imageProvider = getImageURL(input.Username, input.Track)
+	}
+	albumText := withReleaseLabel(resolveAlbumText(displayTrack), input.Track)
+	albumText = withQualityBadge(albumText, input.Username, input.Track)
+	albumText = withListenerCount(albumText, input.Username, input.Track, wallDurationMs-wallElapsedMs)
+	albumText = withScrobbledBadge(albumText, input.Username, input.Track)
 	assets := activityAssets{
-		LargeImage: getImageURL(input.Username, input.Track),
-		LargeText:  input.Track.Album,
+		LargeImage: imageURL,
+		LargeText:  albumText,
 		LargeURL:   spotifyURL,
 	}
 
 	if paused {
-		ts = activityTimestamps{Start: input.Timestamp * 1000}
+		ts = activityTimestamps{Start: reportTimeMs(input)}
 		assets.SmallImage = pauseIconURL
 		assets.SmallText = "Paused"
 	}
 
-	return rpc.sendActivity(clientID, input.Username, userToken, activity{
+	err = sendWithFallback(clientID, input.Username, user.Token, activity{
 		Application:       clientID,
 		Name:              activityName,
-		Type:              2,
-		Details:           input.Track.Title,
+		Type:              activityType,
+		Details:           details,
 		DetailsURL:        spotifyURL,
-		State:             input.Track.Artist,
+		State:             state,
 		StateURL:          artistSearchURL,
 		StatusDisplayType: statusDisplayType,
 		Timestamps:        ts,
 		Assets:            assets,
-	})
+		Party:             resolveParty(),
+		Buttons:           buttons,
+	}, activityOptions{
+		DefaultImage: resolveDefaultImage(activityType),
+		Status:       resolveStatus(paused, user),
+		Truncation:   resolveTruncation(),
+		Attribution:  resolveImageAttribution(imageProvider),
+		AssetMode:    resolveAssetMode(),
+		FinalAttempt: retrying,
+	}, displayTrack, paused)
+	if errors.Is(err, errImageDeferred) {
+		scheduleRetry(input)
+	} else if err != nil {
+		return err
+	}
+	storePresentedTrack(input.Username, input.Track.ID)
+	schedulePresenceRefresh(input.Username)
+	return nil
+}
+
+// isTitleOnly reports whether a track is tagged with nothing but a title.
+func isTitleOnly(track scrobbler.TrackInfo) bool {
+	return track.Artist == "" && track.AlbumArtist == "" && len(track.Artists) == 0 && track.Album == ""
+}
+
+// resolveUntaggedArtist returns the text shown in place of the artist for title-only
+// tracks. Unset, the artist line is left out.
+func resolveUntaggedArtist() string {
+	text, _ := pdk.GetConfig(untaggedArtistKey)
+	return strings.TrimSpace(text)
+}
+
+// reportTimeMs returns when the report was generated, in milliseconds. The
+// server-side timestamp is preferred, as it excludes delivery delay; reports
+// without one fall back to the current time.
+func reportTimeMs(input scrobbler.PlaybackReportRequest) int64 {
+	if input.Timestamp > 0 {
+		return input.Timestamp * 1000
+	}
+	return now().UnixMilli()
+}
+
+// liveAnchorTTL is how long the start anchor of a live stream is kept, in seconds.
+// Streams have no duration to expire it with, so it lasts until playback stops.
+const liveAnchorTTL int64 = 6 * 60 * 60
+
+// isLiveStream reports whether a track is a live stream, like internet radio, which
+// has no duration. Its presence shows elapsed time only and is never cleared on its own.
+func isLiveStream(track scrobbler.TrackInfo) bool {
+	return track.Duration <= 0
+}
+
+// startAnchorKey returns the cache key holding the anchored start time of a track.
+func startAnchorKey(username, trackID string) string {
+	return fmt.Sprintf("discord.anchor.%s.%s", username, trackID)
+}
+
+// anchorUnknownPosition keeps the start time stable for clients that don't report a
+// playback position. When enabled, a zero position is treated as unknown: the start
+// time seen first for the track is stored and reused, instead of restarting the
+// elapsed time on every report. The anchor expires once the track would have ended,
+// or, for live streams, after liveAnchorTTL unless playback stops first.
+func anchorUnknownPosition(input scrobbler.PlaybackReportRequest, start, wallDurationMs int64) int64 {
+	anchorOption, _ := pdk.GetConfig(anchorPositionKey)
+	if anchorOption != "true" {
+		return start
+	}
+	key := startAnchorKey(input.Username, input.Track.ID)
+	if anchored, exists, err := host.CacheGetInt(key); err == nil && exists {
+		return anchored
+	}
+	ttl := wallDurationMs/1000 + 60
+	if isLiveStream(input.Track) {
+		ttl = liveAnchorTTL
+	}
+	if err := host.CacheSetInt(key, start, ttl); err != nil {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Failed to store start anchor for user %s: %v", input.Username, err))
+	}
+	return start
 }
 
 func (p *discordPlugin) handleStopped(input scrobbler.PlaybackReportRequest) error {
-	pdk.Log(pdk.LogInfo, fmt.Sprintf("Clearing presence for user %s", input.Username))
+	if isLiveStream(input.Track) {
+		// Tuning in to the same station again starts a new elapsed time
+		_ = host.CacheRemove(startAnchorKey(input.Username, input.Track.ID))
+	}
+	if holdClear(input) {
+		return nil
+	}
+	return p.clearPresence(input.Username)
+}
+
+// clearPresence clears the user's activity and closes their gateway connection.
+func (p *discordPlugin) clearPresence(username string) error {
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Clearing presence for user %s", username))
+	clearListeningAlbum(username)
+	cancelPresenceRefresh(username)
 
-	clearErr := rpc.clearActivity(input.Username)
-	disconnectErr := rpc.disconnect(input.Username)
+	clearErr := rpc.clearActivity(username)
+	disconnectErr := rpc.disconnect(username)
 
 	if clearErr != nil {
 		return fmt.Errorf("failed to clear activity: %w", clearErr)
@@ -235,28 +520,34 @@ func (p *discordPlugin) handleStopped(input scrobbler.PlaybackReportRequest) err
 	return nil
 }
 
-func connectUser(username string) (clientID, token string, err error) {
+func connectUser(username string) (clientID string, user userConfig, err error) {
 	clientID, users, err := getConfig()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to get config: %w", err)
+		return "", userConfig{}, fmt.Errorf("failed to get config: %w", err)
 	}
 	if clientID == "" {
-		return "", "", fmt.Errorf("missing ClientID in configuration")
+		return "", userConfig{}, errMissingClientID
 	}
 
-	token, authorized := users[username]
+	user, authorized := users[username]
 	if !authorized {
-		return "", "", fmt.Errorf("%w: user '%s' not authorized", scrobbler.ScrobblerErrorNotAuthorized, username)
+		return "", userConfig{}, fmt.Errorf("%w: user '%s' not authorized", scrobbler.ScrobblerErrorNotAuthorized, username)
+	}
+	if rpc.isAuthFailed(username, user.Token) {
+		return "", userConfig{}, errAuthFailed
 	}
 
-	if err := rpc.connect(username, token); err != nil {
-		return "", "", fmt.Errorf("failed to connect to Discord: %w", err)
+	if err := rpc.connect(username, user.Token); err != nil {
+		return "", userConfig{}, fmt.Errorf("failed to connect to Discord: %w", err)
 	}
-	return clientID, token, nil
+	return clientID, user, nil
 }
 
-func resolveActivityName(track scrobbler.TrackInfo) (string, int) {
-	activityNameOption, _ := pdk.GetConfig(activityNameKey)
+func resolveActivityName(track scrobbler.TrackInfo, username, userOption string) (string, int) {
+	activityNameOption := userOption
+	if activityNameOption == "" {
+		activityNameOption, _ = pdk.GetConfig(activityNameKey)
+	}
 	switch activityNameOption {
 	case activityNameTrack:
 		return track.Title, statusDisplayName
@@ -267,23 +558,395 @@ func resolveActivityName(track scrobbler.TrackInfo) (string, int) {
 	case activityNameCustom:
 		template, _ := pdk.GetConfig(activityNameTemplateKey)
 		if template != "" {
-			r := strings.NewReplacer(
-				"{track}", track.Title,
-				"{artist}", track.Artist,
-				"{album}", track.Album,
-			)
-			return r.Replace(template), statusDisplayName
+			return renderTemplate(template, username, track), statusDisplayName
 		}
 	}
 	return "Navidrome", statusDisplayDetails
 }
 
+// displayNameTTL is how long a user's Navidrome display name is cached: 24 hours
+const displayNameTTL int64 = 24 * 60 * 60
+
+// userDisplayName returns the user's Navidrome display name, falling back to the
+// username when it can't be looked up.
+func userDisplayName(username string) string {
+	cacheKey := fmt.Sprintf("discord.displayname.%s", username)
+	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		return cached
+	}
+
+	users, err := host.UsersGetUsers()
+	if err != nil {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Failed to get display name for user %s: %v", username, err))
+		return username
+	}
+	displayName := username
+	for _, u := range users {
+		if u.UserName == username && strings.TrimSpace(u.Name) != "" {
+			displayName = strings.TrimSpace(u.Name)
+			break
+		}
+	}
+	_ = host.CacheSetString(cacheKey, displayName, displayNameTTL)
+	return displayName
+}
+
+// activityTypeNames maps the activitytype option to Discord activity types.
+var activityTypeNames = map[string]int{
+	"listening": activityTypeListening,
+	"playing":   activityTypePlaying,
+	"watching":  activityTypeWatching,
+}
+
+// resolveActivityType returns the configured Discord activity type, defaulting to
+// listening when unset or unknown.
+func resolveActivityType() int {
+	value, _ := pdk.GetConfig(activityTypeKey)
+	name := strings.ToLower(strings.TrimSpace(value))
+	if name == "" {
+		return activityTypeListening
+	}
+	activityType, ok := activityTypeNames[name]
+	if !ok {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown activity type %q, using listening", value))
+		return activityTypeListening
+	}
+	return activityType
+}
+
+// activityVerbs are the verbs Discord shows before the activity name for each type.
+var activityVerbs = map[int]string{
+	activityTypePlaying:   "Playing",
+	activityTypeListening: "Listening to",
+	activityTypeWatching:  "Watching",
+}
+
+// withNameVerb bakes the activity verb into the name when enabled, as a compatibility
+// mode for clients that ignore status_display_type and never show "Listening to".
+// The name is then shown as-is, so the status display type is switched to the name.
+func withNameVerb(name string, statusDisplayType, activityType int) (string, int) {
+	verbOption, _ := pdk.GetConfig(nameVerbKey)
+	verb, ok := activityVerbs[activityType]
+	if verbOption != "true" || !ok {
+		return name, statusDisplayType
+	}
+	return verb + " " + name, statusDisplayName
+}
+
+// withArtistSource returns a copy of track whose artist fields come from the source
+// configured under key: the track artist (default) or the album artist. Tracks
+// without an album artist keep their track artist.
+func withArtistSource(track scrobbler.TrackInfo, key string) scrobbler.TrackInfo {
+	source, _ := pdk.GetConfig(key)
+	if !strings.EqualFold(strings.TrimSpace(source), artistSourceAlbum) || track.AlbumArtist == "" {
+		return track
+	}
+	track.Artist = track.AlbumArtist
+	if len(track.AlbumArtists) > 0 {
+		track.Artists = track.AlbumArtists
+	}
+	return track
+}
+
+// artistNames returns the names of a track's artists, skipping empty ones.
+func artistNames(track scrobbler.TrackInfo) []string {
+	var names []string
+	for _, a := range track.Artists {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// joinArtistNames joins artist names with the separator, or as "A, B & C" when the
+// separator is empty.
+func joinArtistNames(names []string, separator string) string {
+	if separator != "" || len(names) < 2 {
+		return strings.Join(names, separator)
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " & " + names[len(names)-1]
+}
+
+// artistCredit returns all of a track's artists joined with the separator. Tracks
+// listing fewer than two artists keep the artist as tagged, which may credit
+// collaborators in its own way, e.g. "A feat. B".
+func artistCredit(track scrobbler.TrackInfo, separator string) string {
+	if names := artistNames(track); len(names) > 1 {
+		return joinArtistNames(names, separator)
+	}
+	return track.Artist
+}
+
+// displayArtist returns the artist shown for a track: all of its artists joined with the
+// configured separator when joinartists is enabled, and the artist as tagged otherwise.
+func displayArtist(track scrobbler.TrackInfo) string {
+	if join, _ := pdk.GetConfig(joinArtistsKey); join != "true" {
+		return track.Artist
+	}
+	separator, _ := pdk.GetConfig(artistSeparatorKey)
+	return artistCredit(track, separator)
+}
+
+// withDisplaySuffixesStripped removes suffixes matching the configured display
+// patterns, such as "(Remastered 2017)", from the shown title and album. Lookups
+// keep using the names as tagged.
+func withDisplaySuffixesStripped(track scrobbler.TrackInfo) scrobbler.TrackInfo {
+	option, _ := pdk.GetConfig(displayStripPatternsKey)
+	patterns := parseSuffixPatterns(option)
+	track.Title = stripTitleSuffixes(track.Title, patterns)
+	track.Album = stripTitleSuffixes(track.Album, patterns)
+	return track
+}
+
+// resolveAlbumText returns the album name, decorated with the disc number when enabled.
+// TrackInfo carries no disc count, so only discs after the first are decorated: a disc
+// number above 1 is the only reliable sign of a multi-disc album.
+func resolveAlbumText(track scrobbler.TrackInfo) string {
+	showDisc, _ := pdk.GetConfig(showDiscNumberKey)
+	if showDisc != "true" || track.DiscNumber <= 1 || track.Album == "" {
+		return track.Album
+	}
+	return fmt.Sprintf("%s (Disc %d)", track.Album, track.DiscNumber)
+}
+
+// resolveDefaultImage returns the fallback large image for the given activity type,
+// using the Navidrome logo when no image is configured for that type. It returns an
+// empty string when the fallback is disabled, so activities are sent without images.
+func resolveDefaultImage(activityType int) string {
+	if noDefault, _ := pdk.GetConfig(noDefaultImageKey); noDefault == "true" {
+		return ""
+	}
+	var key string
+	switch activityType {
+	case activityTypePlaying:
+		key = defaultImagePlayingKey
+	case activityTypeWatching:
+		key = defaultImageWatchingKey
+	default:
+		key = defaultImageListeningKey
+	}
+	if imageURL, ok := pdk.GetConfig(key); ok && imageURL != "" {
+		return imageURL
+	}
+	return navidromeLogoURL
+}
+
+// presenceStatusAliases maps accepted spellings of the presence status to Discord's tokens.
+var presenceStatusAliases = map[string]string{
+	"online":         presenceStatusOnline,
+	"idle":           presenceStatusIdle,
+	"away":           presenceStatusIdle,
+	"dnd":            presenceStatusDND,
+	"do_not_disturb": presenceStatusDND,
+	"donotdisturb":   presenceStatusDND,
+	"busy":           presenceStatusDND,
+	"invisible":      presenceStatusInvisible,
+	"offline":        presenceStatusInvisible,
+}
+
+// normalizePresenceStatus maps a configured status such as "DND" or "Do Not Disturb"
+// to Discord's lowercase token. It reports false for unknown values.
+func normalizePresenceStatus(value string) (string, bool) {
+	key := strings.ToLower(strings.TrimSpace(value))
+	key = strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+	status, ok := presenceStatusAliases[key]
+	return status, ok
+}
+
+// resolvePresenceStatus returns the configured presence status, defaulting to dnd
+// when unset or unknown.
+func resolvePresenceStatus() string {
+	value, _ := pdk.GetConfig(presenceStatusKey)
+	if strings.TrimSpace(value) == "" {
+		return presenceStatusDND
+	}
+	status, ok := normalizePresenceStatus(value)
+	if !ok {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown presence status %q, using %s", value, presenceStatusDND))
+		return presenceStatusDND
+	}
+	return status
+}
+
+// resolveUserStatus returns the presence status for a user: their own status when
+// set in the users config, otherwise the global one.
+func resolveUserStatus(user userConfig) string {
+	if strings.TrimSpace(user.Status) == "" {
+		return resolvePresenceStatus()
+	}
+	status, ok := normalizePresenceStatus(user.Status)
+	if !ok {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown status %q for user %s, ignoring", user.Status, user.Username))
+		return resolvePresenceStatus()
+	}
+	return status
+}
+
+// resolveStatus returns the presence status for a report. While paused, the paused
+// status is used when configured, so friends can tell playback has stopped.
+func resolveStatus(paused bool, user userConfig) string {
+	if !paused {
+		return resolveUserStatus(user)
+	}
+	value, _ := pdk.GetConfig(pausedStatusKey)
+	if strings.TrimSpace(value) == "" {
+		return resolveUserStatus(user)
+	}
+	status, ok := normalizePresenceStatus(value)
+	if !ok {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown paused status %q, ignoring", value))
+		return resolveUserStatus(user)
+	}
+	return status
+}
+
+// resolveAssetMode returns how images are turned into Discord assets, defaulting to
+// Discord's external-assets endpoint when unset or unknown.
+func resolveAssetMode() string {
+	value, _ := pdk.GetConfig(assetModeKey)
+	mode := strings.ToLower(strings.TrimSpace(value))
+	switch mode {
+	case assetModeExternalAssets, assetModeDirect:
+		return mode
+	case "":
+		return assetModeExternalAssets
+	default:
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown asset mode %q, using %s", value, assetModeExternalAssets))
+		return assetModeExternalAssets
+	}
+}
+
+// resolveTruncation returns the configured text truncation strategy, defaulting to
+// ellipsis when unset or unknown.
+func resolveTruncation() string {
+	value, _ := pdk.GetConfig(truncationKey)
+	strategy := strings.ToLower(strings.TrimSpace(value))
+	switch strategy {
+	case truncateCut, truncateEllipsis, truncateWord:
+		return strategy
+	case "":
+		return truncateEllipsis
+	default:
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown truncation strategy %q, using %s", value, truncateEllipsis))
+		return truncateEllipsis
+	}
+}
+
+// resolveParty returns the party to show with the activity, or nil when no party ID
+// is configured. The party size is either a maximum ("4", read as 1 of 4) or a
+// "current/max" pair ("2/4"); invalid sizes are ignored with a warning.
+func resolveParty() *activityParty {
+	partyID, _ := pdk.GetConfig(partyIDKey)
+	partyID = strings.TrimSpace(partyID)
+	if partyID == "" {
+		return nil
+	}
+	party := &activityParty{ID: partyID}
+
+	sizeOption, _ := pdk.GetConfig(partySizeKey)
+	sizeOption = strings.TrimSpace(sizeOption)
+	if sizeOption == "" {
+		return party
+	}
+	current, maxSize := "1", sizeOption
+	if before, after, found := strings.Cut(sizeOption, "/"); found {
+		current, maxSize = before, after
+	}
+	c, errCurrent := strconv.Atoi(strings.TrimSpace(current))
+	m, errMax := strconv.Atoi(strings.TrimSpace(maxSize))
+	if errCurrent != nil || errMax != nil || c < 1 || m < c {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid party size %q, ignoring", sizeOption))
+		return party
+	}
+	party.Size = []int{c, m}
+	return party
+}
+
+// configBaseURL returns the base URL configured under key, without a trailing slash.
+// It falls back to def when the key is unset or the value is not a well-formed https URL.
+func configBaseURL(key, def string) string {
+	raw, _ := pdk.GetConfig(key)
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return def
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid %s %q: must be an https URL, using %s", key, raw, def))
+		return def
+	}
+	return strings.TrimRight(raw, "/")
+}
+
+// resolveSpotifyLinks returns the links for the track title and the artist name. Either
+// is empty when it doesn't resolve, and both are when link-through is disabled.
 func resolveSpotifyLinks(track scrobbler.TrackInfo) (string, string) {
 	spotifyLinksOption, _ := pdk.GetConfig(spotifyLinksKey)
 	if spotifyLinksOption != "true" {
 		return "", ""
 	}
-	return resolveSpotifyURL(track), spotifySearchURL(track.Artist)
+	return cleanLinkURL(resolveSpotifyURL(track)), resolveArtistURL(track)
+}
+
+// resolveArtistURL returns the link for the artist name: the MusicBrainz page of the
+// primary artist when its MBID is known, since Spotify artist pages can't be resolved,
+// and a Spotify search for the artist otherwise, unless the search fallback is disabled.
+func resolveArtistURL(track scrobbler.TrackInfo) string {
+	if len(track.Artists) > 0 && track.Artists[0].MBID != "" {
+		return "https://musicbrainz.org/artist/" + url.PathEscape(track.Artists[0].MBID)
+	}
+	if _, _, search := spotifyLookupSteps(); !search {
+		return ""
+	}
+	return cleanLinkURL(spotifySearchURL(track.Artist))
+}
+
+// listenButtons returns the activity buttons linking to the track on the configured
+// link service, or nil when no link resolved. Spotify is the default, and its button
+// follows the Spotify link-through option, as does song.link, which falls back to the
+// Spotify button when the track has no universal link.
+func listenButtons(track scrobbler.TrackInfo, spotifyURL string) []activityButton {
+	service, _ := pdk.GetConfig(linkServiceKey)
+	switch service {
+	case linkServiceYouTubeMusic:
+		if ytmURL := resolveYouTubeMusicURL(track); ytmURL != "" {
+			return []activityButton{{Label: "Listen on YouTube Music", URL: ytmURL}}
+		}
+		return nil
+	case linkServiceSongLink:
+		if pageURL := resolveOdesliURL(spotifyURL); pageURL != "" {
+			return []activityButton{{Label: "Listen on song.link", URL: pageURL}}
+		}
+	case "", linkServiceSpotify:
+	default:
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown link service %q, using Spotify", service))
+	}
+	if spotifyURL == "" {
+		return nil
+	}
+	return []activityButton{{Label: "Listen on Spotify", URL: spotifyURL}}
+}
+
+// cleanLinkURL strips tracking query parameters (Spotify's si and utm_*) from a
+// link URL. They add nothing for the listener, take up payload space, and can
+// identify whoever shared the link. Other parameters are kept in order.
+func cleanLinkURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.RawQuery == "" {
+		return link
+	}
+	var query []string
+	for _, part := range strings.Split(u.RawQuery, "&") {
+		name, _, _ := strings.Cut(part, "=")
+		name = strings.ToLower(name)
+		if part == "" || name == "si" || strings.HasPrefix(name, "utm_") {
+			continue
+		}
+		query = append(query, part)
+	}
+	u.RawQuery = strings.Join(query, "&")
+	return u.String()
 }
 
 // ============================================================================
@@ -299,6 +962,38 @@ func (p *discordPlugin) OnCallback(input scheduler.SchedulerCallbackRequest) err
 		if err := rpc.handleHeartbeatCallback(input.ScheduleID); err != nil {
 			return err
 		}
+	case payloadReconnect:
+		if err := rpc.handleReconnectCallback(input.ScheduleID); err != nil {
+			return err
+		}
+	case payloadRetry:
+		if err := p.handleRetryCallback(input.ScheduleID); err != nil {
+			return err
+		}
+	case payloadHeldClear:
+		if err := p.handleHeldClearCallback(input.ScheduleID); err != nil {
+			return err
+		}
+	case payloadPendingPresence:
+		if err := p.handlePendingPresenceCallback(input.ScheduleID); err != nil {
+			return err
+		}
+	case payloadRefreshPresence:
+		if err := rpc.handleRefreshPresenceCallback(input.ScheduleID); err != nil {
+			return err
+		}
+	case payloadSpotifyRefresh:
+		if err := handleSpotifyRefreshCallback(input.ScheduleID); err != nil {
+			return err
+		}
+	case payloadDisconnectAll:
+		if err := p.handleDisconnectAllCallback(); err != nil {
+			return err
+		}
+	case payloadForceReconnect:
+		if err := p.handleForceReconnectCallback(input.ScheduleID); err != nil {
+			return err
+		}
 	default:
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown scheduler callback payload: %s", input.Payload))
 	}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
@@ -35,20 +37,32 @@ var _ = Describe("discordPlugin", func() {
 		host.SubsonicAPIMock.Calls = nil
 		host.HTTPMock.ExpectedCalls = nil
 		host.HTTPMock.Calls = nil
+		registerCacheDefaults()
 	})
 
 	Describe("getConfig", func() {
 		It("returns config values when properly set", func() {
-			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
 			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"user1","token":"token1"},{"username":"user2","token":"token2"}]`, true)
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
 
 			clientID, users, err := getConfig()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(clientID).To(Equal("test-client-id"))
+			Expect(clientID).To(Equal("1234567890123456789"))
 			Expect(users).To(HaveLen(2))
-			Expect(users["user1"]).To(Equal("token1"))
-			Expect(users["user2"]).To(Equal("token2"))
+			Expect(users["user1"].Token).To(Equal("token1"))
+			Expect(users["user2"].Token).To(Equal("token2"))
+		})
+
+		It("reads per-user overrides next to users that inherit the global settings", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"user1","token":"token1","activityName":"Track","status":"online"},{"username":"user2","token":"token2"}]`, true)
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+
+			_, users, err := getConfig()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(users["user1"]).To(Equal(userConfig{Username: "user1", Token: "token1", ActivityName: activityNameTrack, Status: "online"}))
+			Expect(users["user2"]).To(Equal(userConfig{Username: "user2", Token: "token2"}))
 		})
 
 		It("returns empty client ID when not set", func() {
@@ -62,15 +76,81 @@ var _ = Describe("discordPlugin", func() {
 		})
 
 		It("returns nil users when users not configured", func() {
-			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
 			pdk.PDKMock.On("GetConfig", usersKey).Return("", false)
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
 
 			clientID, users, err := getConfig()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(clientID).To(Equal("test-client-id"))
+			Expect(clientID).To(Equal("1234567890123456789"))
 			Expect(users).To(BeNil())
 		})
+
+		DescribeTable("rejects values that can't be a Discord application ID",
+			func(value string) {
+				var logged []string
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return(value, true)
+				pdk.PDKMock.On("Log", pdk.LogError, mock.Anything).Run(func(args mock.Arguments) {
+					logged = append(logged, args.String(1))
+				}).Maybe()
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+
+				clientID, users, err := getConfig()
+				Expect(err).To(MatchError(errInvalidClientID))
+				Expect(clientID).To(BeEmpty())
+				Expect(users).To(BeNil())
+				Expect(logged).To(HaveLen(1))
+				Expect(logged[0]).To(ContainSubstring("Application ID"))
+				Expect(logged[0]).ToNot(ContainSubstring(value))
+			},
+			Entry("application name", "My Navidrome"),
+			Entry("bot token", "MTk4NjIyNDgzNDcxOTI1MjQ4.Cl2FMQ.ZnCjm1XVW7vRze4b7Cq4se7kKWs"),
+			Entry("too short", "1234567890"),
+			Entry("too long", "123456789012345678901"),
+			Entry("digits with spaces", "1234567890 123456789"),
+		)
+	})
+
+	Describe("isSnowflake", func() {
+		DescribeTable("accepts 17 to 20 digit IDs",
+			func(value string, expected bool) {
+				Expect(isSnowflake(value)).To(Equal(expected))
+			},
+			Entry("documented example", "1234567890123456789", true),
+			Entry("shortest", "12345678901234567", true),
+			Entry("longest", "12345678901234567890", true),
+			Entry("empty", "", false),
+			Entry("non-numeric", "123456789012345678a", false),
+		)
+	})
+
+	Describe("sharedTokenUsers", func() {
+		It("groups users configured with the same token", func() {
+			Expect(sharedTokenUsers(map[string]userConfig{
+				"user1": {Token: "token1"},
+				"user2": {Token: "token2"},
+				"user3": {Token: "token1"},
+				"user4": {Token: "token2"},
+				"user5": {Token: "token5"},
+			})).To(Equal([][]string{{"user1", "user3"}, {"user2", "user4"}}))
+		})
+
+		It("returns nothing when every user has their own token", func() {
+			Expect(sharedTokenUsers(map[string]userConfig{"user1": {Token: "token1"}, "user2": {Token: "token2"}})).To(BeEmpty())
+		})
+
+		It("warns about shared tokens when reading the config", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"user1","token":"token1"},{"username":"user2","token":"token1"}]`, true)
+			pdk.PDKMock.On("Log", pdk.LogWarn, mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, "users user1, user2 share the same Discord token")
+			})).Once()
+
+			_, users, err := getConfig()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(users).To(HaveLen(2))
+			pdk.PDKMock.AssertExpectations(GinkgoT())
+		})
 	})
 
 	Describe("IsAuthorized", func() {
@@ -79,7 +159,7 @@ var _ = Describe("discordPlugin", func() {
 		})
 
 		It("returns true for authorized user", func() {
-			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
 			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"token123"}]`, true)
 
 			authorized, err := plugin.IsAuthorized(scrobbler.IsAuthorizedRequest{
@@ -90,7 +170,7 @@ var _ = Describe("discordPlugin", func() {
 		})
 
 		It("returns false for unauthorized user", func() {
-			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
 			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"otheruser","token":"token123"}]`, true)
 
 			authorized, err := plugin.IsAuthorized(scrobbler.IsAuthorizedRequest{
@@ -124,7 +204,6 @@ var _ = Describe("discordPlugin", func() {
 		}
 
 		setupConnectMocks := func() {
-			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(0), false, errors.New("not found"))
 			gatewayResp := []byte(`{"url":"wss://gateway.discord.gg"}`)
 			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
 				return req.Method == "GET" && req.URL == "https://discord.com/api/gateway"
@@ -135,18 +214,26 @@ var _ = Describe("discordPlugin", func() {
 			host.SchedulerMock.On("ScheduleRecurring", mock.Anything, payloadHeartbeat, "testuser").Return("testuser", nil)
 		}
 
+		// setupDefaultConfigMocks treats every option not explicitly mocked as unset.
+		// It must be called after any specific GetConfig expectations.
+		setupDefaultConfigMocks := func() {
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false).Maybe()
+		}
+
 		setupConfigMocks := func() {
-			pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
 			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
 			pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false)
 			pdk.PDKMock.On("GetConfig", caaEnabledKey).Return("", false)
 			pdk.PDKMock.On("GetConfig", activityNameKey).Return("", false)
 			pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("", false)
+			setupDefaultConfigMocks()
 		}
 
 		setupImageMocks := func() {
 			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
 			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil)
 			host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("https://example.com/art.jpg", nil)
 			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"}]`)}, nil)
 		}
@@ -167,7 +254,10 @@ var _ = Describe("discordPlugin", func() {
 
 		Context("playing state", func() {
 			It("returns not authorized error when user not in config", func() {
-				pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
+				pdk.PDKMock.On("GetConfig", transitionGraceKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", hideArtistsKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", hideGenresKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
 				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"otheruser","token":"token"}]`, true)
 
 				err := plugin.PlaybackReport(baseRequest("playing"))
@@ -175,6 +265,101 @@ var _ = Describe("discordPlugin", func() {
 				Expect(err.Error()).To(ContainSubstring("not authorized"))
 			})
 
+			DescribeTable("sends the configured activity type",
+				func(value string, expectedType, expectedDisplay int) {
+					pdk.PDKMock.On("GetConfig", activityTypeKey).Return(value, value != "")
+					setupConfigMocks()
+					setupConnectMocks()
+					setupImageMocks()
+
+					var sentPayload string
+					host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+						sentPayload = args.Get(1).(string)
+					}).Return(nil)
+
+					Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+					Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"type":%d`, expectedType)))
+					Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"status_display_type":%d`, expectedDisplay)))
+				},
+				Entry("unset keeps listening", "", activityTypeListening, statusDisplayDetails),
+				Entry("listening", "listening", activityTypeListening, statusDisplayDetails),
+				Entry("playing shows the activity name", "Playing", activityTypePlaying, statusDisplayName),
+				Entry("watching", "watching", activityTypeWatching, statusDisplayDetails),
+				Entry("unknown falls back to listening", "streaming", activityTypeListening, statusDisplayDetails),
+			)
+
+			DescribeTable("sends the configured presence status",
+				func(value, expected string) {
+					pdk.PDKMock.On("GetConfig", presenceStatusKey).Return(value, value != "")
+					setupConfigMocks()
+					setupConnectMocks()
+					setupImageMocks()
+
+					var sentPayload string
+					host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+						sentPayload = args.Get(1).(string)
+					}).Return(nil)
+
+					Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+					Expect(sentPayload).To(ContainSubstring(`"status":"` + expected + `"`))
+				},
+				Entry("unset keeps dnd", "", presenceStatusDND),
+				Entry("online", "online", presenceStatusOnline),
+				Entry("idle", "idle", presenceStatusIdle),
+				Entry("dnd", "dnd", presenceStatusDND),
+				Entry("invisible", "invisible", presenceStatusInvisible),
+				Entry("invalid falls back to dnd", "busy", presenceStatusDND),
+			)
+
+			It("records the failure as the user's last error", func() {
+				pdk.PDKMock.On("GetConfig", transitionGraceKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", hideArtistsKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", hideGenresKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return("", false)
+
+				err := plugin.PlaybackReport(baseRequest("playing"))
+				Expect(err).To(HaveOccurred())
+				host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.lasterror.testuser", mock.MatchedBy(func(value string) bool {
+					return strings.Contains(value, "missing ClientID")
+				}), lastErrorTTL)
+			})
+
+			It("skips users whose token was rejected until the sentinel is cleared", func() {
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.authfailed.testuser").Return(tokenFingerprint("test-token"), true, nil).Twice()
+				registerCacheDefaults()
+
+				for range 2 {
+					Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				}
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, mock.Anything)
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.lasterror.testuser", mock.Anything, mock.Anything)
+
+				// Once the sentinel is gone, the next report connects again
+				setupConnectMocks()
+				setupImageMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, "testuser")
+			})
+
+			It("logs why the token was rejected when skipping a user", func() {
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.authfailed.testuser").Return(tokenFingerprint("test-token"), true, nil)
+				host.CacheMock.On("GetString", "discord.lasterror.testuser").
+					Return(`{"error":"discord token was rejected: Authentication failed (4004)","time":1714600000}`, true, nil)
+				registerCacheDefaults()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				pdk.PDKMock.AssertCalled(GinkgoT(), "Log", mock.Anything, mock.MatchedBy(func(msg string) bool {
+					return strings.HasPrefix(msg, "Skipping presence for user testuser") && strings.Contains(msg, "Authentication failed (4004) at 2024-05-01T21:46:40Z")
+				}))
+			})
+
 			It("sends activity with running timestamps and no small overlay", func() {
 				setupConfigMocks()
 				setupConnectMocks()
@@ -191,6 +376,128 @@ var _ = Describe("discordPlugin", func() {
 				Expect(sentPayload).ToNot(ContainSubstring(`"small_image"`))
 				Expect(sentPayload).To(ContainSubstring(`"start":`))
 				Expect(sentPayload).To(ContainSubstring(`"end":`))
+				Expect(sentPayload).ToNot(ContainSubstring(`"party"`))
+			})
+
+			It("sends a truncated presence for a very long artist", func() {
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				req := baseRequest("playing")
+				req.Track.Artist = strings.Repeat("アーティスト", 50)
+				err := plugin.PlaybackReport(req)
+				Expect(err).ToNot(HaveOccurred())
+
+				var msg struct {
+					D presencePayload `json:"d"`
+				}
+				Expect(json.Unmarshal([]byte(sentPayload), &msg)).To(Succeed())
+				Expect(msg.D.Activities).To(HaveLen(1))
+				Expect(msg.D.Activities[0].State).To(Equal(truncateField(req.Track.Artist, maxTextLength)))
+				Expect([]rune(msg.D.Activities[0].State)).To(HaveLen(maxTextLength))
+			})
+
+			It("includes the configured party", func() {
+				pdk.PDKMock.On("GetConfig", partyIDKey).Return("navidrome-party", true)
+				pdk.PDKMock.On("GetConfig", partySizeKey).Return("2/5", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				err := plugin.PlaybackReport(baseRequest("playing"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sentPayload).To(ContainSubstring(`"party":{"id":"navidrome-party","size":[2,5]}`))
+			})
+
+			Context("when the client doesn't report a position", func() {
+				anchorKey := "discord.anchor.testuser.track1"
+
+				sendTwice := func() []string {
+					var payloads []string
+					host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+						if msg := args.Get(1).(string); strings.Contains(msg, `"op":3`) {
+							payloads = append(payloads, msg)
+						}
+					}).Return(nil)
+
+					first := baseRequest("playing")
+					first.PositionMs = 0
+					Expect(plugin.PlaybackReport(first)).To(Succeed())
+
+					second := first
+					second.Timestamp += 30
+					Expect(plugin.PlaybackReport(second)).To(Succeed())
+					return payloads
+				}
+
+				It("keeps the start time anchored at first sight when enabled", func() {
+					pdk.PDKMock.On("GetConfig", anchorPositionKey).Return("true", true)
+					setupConfigMocks()
+					setupConnectMocks()
+					setupImageMocks()
+					host.CacheMock.On("GetInt", anchorKey).Return(int64(0), false, nil).Once()
+					host.CacheMock.On("SetInt", anchorKey, int64(1714600000000), int64(240)).Return(nil)
+					host.CacheMock.On("GetInt", anchorKey).Return(int64(1714600000000), true, nil)
+
+					payloads := sendTwice()
+					Expect(payloads).To(HaveLen(2))
+					for _, payload := range payloads {
+						Expect(payload).To(ContainSubstring(`"start":1714600000000`))
+						Expect(payload).To(ContainSubstring(`"end":1714600180000`))
+					}
+				})
+
+				It("keeps the anchor of a live stream until playback stops", func() {
+					pdk.PDKMock.On("GetConfig", anchorPositionKey).Return("true", true)
+					setupConfigMocks()
+					setupConnectMocks()
+					setupImageMocks()
+					host.CacheMock.On("GetInt", anchorKey).Return(int64(0), false, nil).Once()
+					host.CacheMock.On("SetInt", anchorKey, int64(1714600000000), liveAnchorTTL).Return(nil)
+					host.CacheMock.On("GetInt", anchorKey).Return(int64(1714600000000), true, nil)
+
+					var payloads []string
+					host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+						if msg := args.Get(1).(string); strings.Contains(msg, `"op":3`) {
+							payloads = append(payloads, msg)
+						}
+					}).Return(nil)
+
+					req := baseRequest("playing")
+					req.PositionMs = 0
+					req.Track.Duration = 0
+					Expect(plugin.PlaybackReport(req)).To(Succeed())
+					req.Timestamp += 600
+					Expect(plugin.PlaybackReport(req)).To(Succeed())
+
+					Expect(payloads).To(HaveLen(2))
+					for _, payload := range payloads {
+						Expect(payload).To(ContainSubstring(`"timestamps":{"start":1714600000000}`))
+					}
+				})
+
+				It("restarts the elapsed time on every report when disabled", func() {
+					setupConfigMocks()
+					setupConnectMocks()
+					setupImageMocks()
+
+					payloads := sendTwice()
+					Expect(payloads).To(HaveLen(2))
+					Expect(payloads[0]).To(ContainSubstring(`"start":1714600000000`))
+					Expect(payloads[1]).To(ContainSubstring(`"start":1714600030000`))
+					host.CacheMock.AssertNotCalled(GinkgoT(), "GetInt", anchorKey)
+				})
 			})
 
 			It("adjusts end time for non-1.0 playback rate", func() {
@@ -217,10 +524,8 @@ var _ = Describe("discordPlugin", func() {
 				Expect(sentPayload).To(ContainSubstring(`"start":1714599995000`))
 				Expect(sentPayload).To(ContainSubstring(`"end":1714600085000`))
 			})
-		})
 
-		Context("paused state", func() {
-			It("sends activity with frozen timestamps and pause icon overlay", func() {
+			It("sets start and end from a mid-track position", func() {
 				setupConfigMocks()
 				setupConnectMocks()
 				setupImageMocks()
@@ -230,51 +535,57 @@ var _ = Describe("discordPlugin", func() {
 					sentPayload = args.Get(1).(string)
 				}).Return(nil)
 
-				err := plugin.PlaybackReport(baseRequest("paused"))
-				Expect(err).ToNot(HaveOccurred())
+				req := baseRequest("playing")
+				req.PositionMs = 95000
 
-				Expect(sentPayload).To(ContainSubstring(`"small_text":"Paused"`))
-				Expect(sentPayload).ToNot(ContainSubstring(`"end":`))
-				// Paused start = Timestamp * 1000 = 1714600000000
-				Expect(sentPayload).To(ContainSubstring(`"start":1714600000000`))
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				// startTime = 1714600000*1000 - 95000 = 1714599905000
+				// endTime = 1714599905000 + 180*1000 = 1714600085000
+				Expect(sentPayload).To(ContainSubstring(`"timestamps":{"start":1714599905000,"end":1714600085000}`))
 			})
-		})
 
-		Context("stopped state", func() {
-			It("clears activity and disconnects", func() {
-				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
-					return strings.Contains(msg, `"op":3`) && strings.Contains(msg, `"activities":null`)
-				})).Return(nil)
-				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
-				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+			It("omits the end for live streams without a duration", func() {
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
 
-				err := plugin.PlaybackReport(baseRequest("stopped"))
-				Expect(err).ToNot(HaveOccurred())
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				req := baseRequest("playing")
+				req.Track.Duration = 0
+
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				Expect(sentPayload).To(ContainSubstring(`"timestamps":{"start":1714599990000}`))
 			})
-		})
 
-		Context("expired state", func() {
-			It("clears activity and disconnects (same as stopped)", func() {
-				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
-					return strings.Contains(msg, `"op":3`) && strings.Contains(msg, `"activities":null`)
-				})).Return(nil)
-				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
-				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+			It("falls back to the current time when the report has no timestamp", func() {
+				pinClock(time.UnixMilli(1714600050000))
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
 
-				err := plugin.PlaybackReport(baseRequest("expired"))
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				req := baseRequest("playing")
+				req.Timestamp = 0
+
+				err := plugin.PlaybackReport(req)
 				Expect(err).ToNot(HaveOccurred())
-			})
-		})
 
-		DescribeTable("activity name configuration",
-			func(configValue string, configExists bool, expectedName string, expectedDisplayType int) {
-				pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
-				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
-				pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false)
-				pdk.PDKMock.On("GetConfig", caaEnabledKey).Return("", false)
-				pdk.PDKMock.On("GetConfig", activityNameKey).Return(configValue, configExists)
-				pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("", false)
+				// startTime = 1714600050000 - 10000 = 1714600040000
+				Expect(sentPayload).To(ContainSubstring(`"start":1714600040000`))
+				Expect(sentPayload).To(ContainSubstring(`"end":1714600220000`))
+			})
 
+			It("prefers the report timestamp over the current time", func() {
+				pinClock(time.UnixMilli(1714600050000))
+				setupConfigMocks()
 				setupConnectMocks()
 				setupImageMocks()
 
@@ -285,28 +596,22 @@ var _ = Describe("discordPlugin", func() {
 
 				err := plugin.PlaybackReport(baseRequest("playing"))
 				Expect(err).ToNot(HaveOccurred())
-				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"name":"%s"`, expectedName)))
-				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"status_display_type":%d`, expectedDisplayType)))
-			},
-			Entry("defaults to Navidrome when not configured", "", false, "Navidrome", 2),
-			Entry("defaults to Navidrome with explicit default value", "Default", true, "Navidrome", 2),
-			Entry("uses track title when configured", "Track", true, "Test Song", 0),
-			Entry("uses track album when configured", "Album", true, "Test Album", 0),
-			Entry("uses track artist when configured", "Artist", true, "Test Artist", 0),
-		)
 
-		DescribeTable("custom activity name template",
-			func(template string, templateExists bool, expectedName string) {
-				pdk.PDKMock.On("GetConfig", clientIDKey).Return("test-client-id", true)
-				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
-				pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false)
-				pdk.PDKMock.On("GetConfig", caaEnabledKey).Return("", false)
-				pdk.PDKMock.On("GetConfig", activityNameKey).Return("Custom", true)
-				pdk.PDKMock.On("GetConfig", activityNameTemplateKey).Return(template, templateExists)
-				pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("", false)
+				// startTime = 1714600000*1000 - 10000 = 1714599990000
+				Expect(sentPayload).To(ContainSubstring(`"start":1714599990000`))
+			})
 
+			It("falls back to the configured default image when artwork is unavailable", func() {
+				pdk.PDKMock.On("GetConfig", defaultImageListeningKey).Return("https://example.com/listening.png", true)
+				setupConfigMocks()
 				setupConnectMocks()
-				setupImageMocks()
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+				host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil)
+				host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("", errors.New("not found"))
+				host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+					return strings.Contains(req.URL, "external-assets") && strings.Contains(string(req.Body), "https://example.com/listening.png")
+				})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/listening"}]`)}, nil)
 
 				var sentPayload string
 				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
@@ -315,32 +620,1524 @@ var _ = Describe("discordPlugin", func() {
 
 				err := plugin.PlaybackReport(baseRequest("playing"))
 				Expect(err).ToNot(HaveOccurred())
-				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"name":"%s"`, expectedName)))
-			},
-			Entry("uses custom template with all placeholders", "{artist} - {track} ({album})", true, "Test Artist - Test Song (Test Album)"),
-			Entry("uses custom template with only track", "{track}", true, "Test Song"),
-			Entry("uses custom template with only artist", "{artist}", true, "Test Artist"),
-			Entry("uses custom template with only album", "{album}", true, "Test Album"),
-			Entry("uses custom template with plain text", "Now Playing", true, "Now Playing"),
-			Entry("falls back to Navidrome when template is empty", "", false, "Navidrome"),
-		)
-	})
+				Expect(sentPayload).To(ContainSubstring(`"large_image":"mp:external/listening"`))
+			})
 
-	Describe("OnCallback", func() {
-		BeforeEach(func() {
-			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			It("sends the presence without artwork and retries while image uploads are rate limited", func() {
+				pdk.PDKMock.On("GetConfig", defaultImageListeningKey).Return("https://example.com/listening.png", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("GetInt", rateLimitKey).Return(int64(1), true, nil)
+				host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("https://example.com/art.jpg", nil)
+				host.CacheMock.On("SetString", "discord.retry.testuser", mock.Anything, int64(retryDelay*6)).Return(nil)
+				host.SchedulerMock.On("ScheduleOneTime", int32(retryDelay), payloadRetry, "retry.testuser").Return("retry.testuser", nil)
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				Expect(sentPayload).To(ContainSubstring(`"details":"Test Song"`))
+				Expect(sentPayload).To(ContainSubstring(`"large_image":""`))
+				// Neither the artwork nor the default image is uploaded until the limit resets
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", externalAssetsReq)
+				host.SchedulerMock.AssertExpectations(GinkgoT())
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.lastactivity.testuser", mock.Anything, mock.Anything)
+			})
+
+			It("uses the default image instead of retrying again when the artwork is still rate limited", func() {
+				pdk.PDKMock.On("GetConfig", defaultImageListeningKey).Return("https://example.com/listening.png", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.retry.testuser").Return(`{"username":"testuser","state":"playing","track":{"id":"track1","title":"Test Song","duration":180}}`, true, nil)
+				host.CacheMock.On("Remove", "discord.retry.testuser").Return(nil)
+				host.CacheMock.On("GetString", "discord.image."+hashKey("https://example.com/listening.png")).Return("mp:external/listening", true, nil)
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("GetInt", rateLimitKey).Return(int64(1), true, nil)
+				registerCacheDefaults()
+				host.ArtworkMock.On("GetTrackUrl", "track1", int32(300)).Return("https://example.com/art.jpg", nil)
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "retry.testuser", Payload: payloadRetry})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sentPayload).To(ContainSubstring(`"large_image":"mp:external/listening"`))
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", externalAssetsReq)
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
 		})
 
-		It("handles heartbeat callback", func() {
-			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(42), true, nil)
-			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+		Context("per-user settings", func() {
+			var sentPayloads map[string]string
 
-			err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{
-				ScheduleID:  "testuser",
-				Payload:     payloadHeartbeat,
-				IsRecurring: true,
+			BeforeEach(func() {
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token","activityName":"Track","status":"online"},{"username":"otheruser","token":"other-token"}]`, true)
+				pdk.PDKMock.On("GetConfig", activityNameKey).Return(activityNameAlbum, true)
+				pdk.PDKMock.On("GetConfig", presenceStatusKey).Return("idle", true)
+				setupConfigMocks()
+				setupImageMocks()
+				for _, username := range []string{"testuser", "otheruser"} {
+					host.WebSocketMock.On("Connect", mock.Anything, mock.Anything, username).Return(username, nil)
+					host.SchedulerMock.On("ScheduleRecurring", mock.Anything, payloadHeartbeat, username).Return(username, nil)
+				}
+				host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+					return req.URL == "https://discord.com/api/gateway"
+				})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"url":"wss://gateway.discord.gg"}`)}, nil)
+				sentPayloads = map[string]string{}
+				host.WebSocketMock.On("SendText", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+					if strings.Contains(args.String(1), `"op":3`) {
+						sentPayloads[args.String(0)] = args.String(1)
+					}
+				}).Return(nil)
 			})
-			Expect(err).ToNot(HaveOccurred())
+
+			It("uses the user's own activity name and status", func() {
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				Expect(sentPayloads["testuser"]).To(ContainSubstring(`"name":"Test Song"`))
+				Expect(sentPayloads["testuser"]).To(ContainSubstring(`"status":"online"`))
+			})
+
+			It("falls back to the global settings for users without overrides", func() {
+				req := baseRequest("playing")
+				req.Username = "otheruser"
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				Expect(sentPayloads["otheruser"]).To(ContainSubstring(`"name":"Test Album"`))
+				Expect(sentPayloads["otheruser"]).To(ContainSubstring(`"status":"idle"`))
+			})
+		})
+
+		Context("display suffixes", func() {
+			It("strips configured suffixes from the shown title and album", func() {
+				pdk.PDKMock.On("GetConfig", displayStripPatternsKey).Return("remaster", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				req := baseRequest("playing")
+				req.Track.Title = "Test Song - 2011 Remaster"
+				req.Track.Album = "Test Album (Remastered 2017)"
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				Expect(sentPayload).To(ContainSubstring(`"details":"Test Song"`))
+				Expect(sentPayload).To(ContainSubstring(`"large_text":"Test Album"`))
+				Expect(sentPayload).ToNot(ContainSubstring("Remaster"))
+			})
+		})
+
+		It("shows all artists of a multi-artist track when enabled, truncated to Discord's limit", func() {
+			pdk.PDKMock.On("GetConfig", joinArtistsKey).Return("true", true)
+			setupConfigMocks()
+			setupConnectMocks()
+			setupImageMocks()
+			var sentPayload string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sentPayload = args.String(1)
+			}).Return(nil)
+
+			req := baseRequest("playing")
+			for i := range 10 {
+				req.Track.Artists = append(req.Track.Artists, scrobbler.ArtistRef{Name: fmt.Sprintf("Orchestra Number %d", i+1)})
+			}
+			Expect(plugin.PlaybackReport(req)).To(Succeed())
+
+			var payload struct {
+				D presencePayload `json:"d"`
+			}
+			Expect(json.Unmarshal([]byte(sentPayload), &payload)).To(Succeed())
+			state := payload.D.Activities[0].State
+			Expect([]rune(state)).To(HaveLen(maxTextLength))
+			Expect(state).To(HavePrefix("Orchestra Number 1, Orchestra Number 2, "))
+			Expect(state).To(HaveSuffix("…"))
+		})
+
+		Context("details and state templates", func() {
+			var sentPayload string
+
+			BeforeEach(func() {
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.String(1)
+				}).Return(nil)
+			})
+
+			sentActivity := func() activity {
+				var payload struct {
+					D presencePayload `json:"d"`
+				}
+				Expect(json.Unmarshal([]byte(sentPayload), &payload)).To(Succeed())
+				return payload.D.Activities[0]
+			}
+
+			It("keeps the title and artist by default", func() {
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				Expect(sentActivity().Details).To(Equal("Test Song"))
+				Expect(sentActivity().State).To(Equal("Test Artist"))
+			})
+
+			It("renders the configured templates", func() {
+				pdk.PDKMock.On("GetConfig", detailsTemplateKey).Return("{title} ({album})", true)
+				pdk.PDKMock.On("GetConfig", stateTemplateKey).Return("{artist} · {year}", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").
+					Return(`{"subsonic-response":{"song":{"year":1997}}}`, nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				Expect(sentActivity().Details).To(Equal("Test Song (Test Album)"))
+				Expect(sentActivity().State).To(Equal("Test Artist · 1997"))
+			})
+
+			It("truncates rendered text to Discord's limit", func() {
+				pdk.PDKMock.On("GetConfig", detailsTemplateKey).Return("{title} — {album}", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				req := baseRequest("playing")
+				req.Track.Album = strings.Repeat("Long Album Name ", 10)
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				Expect([]rune(sentActivity().Details)).To(HaveLen(maxTextLength))
+				Expect(sentActivity().Details).To(HavePrefix("Test Song — Long Album Name"))
+			})
+		})
+
+		Context("scrobbled badge", func() {
+			var sentPayload string
+
+			BeforeEach(func() {
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.String(1)
+				}).Return(nil)
+			})
+
+			It("shows the badge on the next update after a scrobble", func() {
+				pdk.PDKMock.On("GetConfig", showScrobbledKey).Return("true", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.CacheMock.On("SetString", "discord.scrobbled.testuser", "track1", int64(180)).Return(nil)
+
+				req := baseRequest("playing")
+				host.CacheMock.On("GetString", "discord.scrobbled.testuser").Return("", false, nil).Once()
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				Expect(sentPayload).To(ContainSubstring(`"large_text":"Test Album"`))
+
+				Expect(plugin.Scrobble(scrobbler.ScrobbleRequest{Username: "testuser", Track: req.Track, Timestamp: req.Timestamp})).To(Succeed())
+				host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.scrobbled.testuser", "track1", int64(180))
+
+				host.CacheMock.On("GetString", "discord.scrobbled.testuser").Return("track1", true, nil)
+				req.PositionMs = 100000
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				Expect(sentPayload).To(ContainSubstring(`"large_text":"Test Album · Scrobbled"`))
+			})
+
+			It("doesn't show the badge for another track", func() {
+				pdk.PDKMock.On("GetConfig", showScrobbledKey).Return("true", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.CacheMock.On("GetString", "discord.scrobbled.testuser").Return("track0", true, nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				Expect(sentPayload).To(ContainSubstring(`"large_text":"Test Album"`))
+			})
+
+			It("ignores scrobbles when disabled", func() {
+				setupConfigMocks()
+
+				Expect(plugin.Scrobble(scrobbler.ScrobbleRequest{Username: "testuser", Track: baseRequest("playing").Track})).To(Succeed())
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.scrobbled.testuser", mock.Anything, mock.Anything)
+			})
+		})
+
+		Context("hidden artists and genres", func() {
+			var sentPayloads []string
+
+			setupDisconnectMocks := func() {
+				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+				host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+			}
+
+			BeforeEach(func() {
+				sentPayloads = nil
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayloads = append(sentPayloads, args.String(1))
+				}).Return(nil)
+			})
+
+			It("clears the activity instead of showing a hidden artist", func() {
+				pdk.PDKMock.On("GetConfig", hideArtistsKey).Return("Other Artist,  test   ARTIST ", true)
+				setupConfigMocks()
+				setupDisconnectMocks()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				Expect(sentPayloads).To(HaveLen(1))
+				Expect(sentPayloads[0]).To(ContainSubstring(`"activities":null`))
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, mock.Anything)
+				host.WebSocketMock.AssertCalled(GinkgoT(), "CloseConnection", "testuser", int32(1000), "Navidrome disconnect")
+			})
+
+			It("matches individual artists of a multi-artist track", func() {
+				pdk.PDKMock.On("GetConfig", hideArtistsKey).Return("Guest", true)
+				setupConfigMocks()
+				setupDisconnectMocks()
+
+				req := baseRequest("playing")
+				req.Track.Artist = "Test Artist • Guest"
+				req.Track.Artists = []scrobbler.ArtistRef{{Name: "Test Artist"}, {Name: "Guest"}}
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				Expect(sentPayloads).To(HaveLen(1))
+				Expect(sentPayloads[0]).To(ContainSubstring(`"activities":null`))
+			})
+
+			It("does nothing for a hidden track without a connection", func() {
+				pdk.PDKMock.On("GetConfig", hideArtistsKey).Return("test artist", true)
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.connected.testuser").Return(int64(0), false, nil)
+				registerCacheDefaults()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				Expect(sentPayloads).To(BeEmpty())
+			})
+
+			It("clears the activity for a hidden genre", func() {
+				pdk.PDKMock.On("GetConfig", hideGenresKey).Return("Comedy", true)
+				setupConfigMocks()
+				host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").
+					Return(`{"subsonic-response":{"song":{"genre":"Spoken Word","genres":[{"name":"Spoken Word"},{"name":"comedy"}]}}}`, nil)
+				setupDisconnectMocks()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				Expect(sentPayloads).To(HaveLen(1))
+				Expect(sentPayloads[0]).To(ContainSubstring(`"activities":null`))
+			})
+
+			It("shows tracks that don't match normally", func() {
+				pdk.PDKMock.On("GetConfig", hideArtistsKey).Return("Test", true)
+				pdk.PDKMock.On("GetConfig", hideGenresKey).Return("Comedy", true)
+				host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").
+					Return(`{"subsonic-response":{"song":{"genre":"Rock"}}}`, nil)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				Expect(sentPayloads).ToNot(BeEmpty())
+				Expect(sentPayloads[len(sentPayloads)-1]).To(ContainSubstring(`"details":"Test Song"`))
+			})
+		})
+
+		Context("paused state", func() {
+			It("switches to the paused status with the pause overlay", func() {
+				pdk.PDKMock.On("GetConfig", pausedStatusKey).Return("idle", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("paused"))).To(Succeed())
+				Expect(sentPayload).To(ContainSubstring(`"status":"idle"`))
+				Expect(sentPayload).To(ContainSubstring(`"small_text":"Paused"`))
+				Expect(sentPayload).ToNot(ContainSubstring(`"end"`))
+			})
+
+			It("sends activity with frozen timestamps and pause icon overlay", func() {
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				err := plugin.PlaybackReport(baseRequest("paused"))
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(sentPayload).To(ContainSubstring(`"small_text":"Paused"`))
+				Expect(sentPayload).ToNot(ContainSubstring(`"end":`))
+				// Paused start = Timestamp * 1000 = 1714600000000
+				Expect(sentPayload).To(ContainSubstring(`"start":1714600000000`))
+			})
+		})
+
+		Context("custom status fallback", func() {
+			// setupSendMocks lets the identify and the custom status through, while the
+			// rich presence activity fails to send.
+			setupSendMocks := func() {
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"op":2`)
+				})).Return(nil)
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, fmt.Sprintf(`"type":%d`, activityTypeListening))
+				})).Return(errors.New("send failed"))
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, fmt.Sprintf(`"type":%d`, activityTypeCustom))
+				})).Return(nil)
+				host.CacheMock.On("SetString", "discord.retry.testuser", mock.Anything, mock.Anything).Return(nil).Maybe()
+				host.SchedulerMock.On("ScheduleOneTime", mock.Anything, payloadRetry, "retry.testuser").Return("retry.testuser", nil).Maybe()
+			}
+
+			It("falls back to a custom status after repeated failures", func() {
+				pdk.PDKMock.On("GetConfig", customStatusFallbackKey).Return("true", true)
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.failures.testuser").Return(int64(customStatusFallbackThreshold-1), true, nil)
+				host.CacheMock.On("SetInt", "discord.failures.testuser", int64(customStatusFallbackThreshold), presenceFailuresTTL).Return(nil)
+				registerCacheDefaults()
+				setupConnectMocks()
+				setupImageMocks()
+				setupSendMocks()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"state":"Listening to Test Song by Test Artist"`) &&
+						strings.Contains(msg, `"status":"dnd"`)
+				}))
+				host.CacheMock.AssertExpectations(GinkgoT())
+				host.CacheMock.AssertCalled(GinkgoT(), "SetInt", "discord.customstatus.testuser", mock.Anything, customStatusTTL)
+				host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.lastpresence.testuser")
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			It("stays on the custom status while it works", func() {
+				pdk.PDKMock.On("GetConfig", customStatusFallbackKey).Return("true", true)
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.customstatus.testuser").Return(int64(1714600000), true, nil)
+				registerCacheDefaults()
+				setupConnectMocks()
+				setupImageMocks()
+				setupSendMocks()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"state":"Listening to Test Song by Test Artist"`)
+				}))
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, fmt.Sprintf(`"type":%d`, activityTypeListening))
+				}))
+				host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.statusfailures.testuser")
+				host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.lastpresence.testuser")
+			})
+
+			It("switches back to rich presence once the custom status fails repeatedly", func() {
+				pdk.PDKMock.On("GetConfig", customStatusFallbackKey).Return("true", true)
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.customstatus.testuser").Return(int64(1714600000), true, nil)
+				host.CacheMock.On("GetInt", "discord.statusfailures.testuser").Return(int64(customStatusFallbackThreshold-1), true, nil)
+				host.CacheMock.On("Remove", "discord.customstatus.testuser").Return(nil).Once()
+				registerCacheDefaults()
+				setupConnectMocks()
+				setupImageMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, fmt.Sprintf(`"type":%d`, activityTypeCustom))
+				})).Return(errors.New("send failed"))
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, fmt.Sprintf(`"type":%d`, activityTypeListening))
+				}))
+				host.CacheMock.AssertExpectations(GinkgoT())
+			})
+
+			It("keeps failing without a custom status below the threshold", func() {
+				pdk.PDKMock.On("GetConfig", customStatusFallbackKey).Return("true", true)
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("SetInt", "discord.failures.testuser", int64(1), presenceFailuresTTL).Return(nil)
+				registerCacheDefaults()
+				setupConnectMocks()
+				setupImageMocks()
+				setupSendMocks()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(MatchError(ContainSubstring("send failed")))
+				host.CacheMock.AssertExpectations(GinkgoT())
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"type":4`)
+				}))
+			})
+
+			It("does not count failures when disabled", func() {
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				setupSendMocks()
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(MatchError(ContainSubstring("send failed")))
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetInt", "discord.failures.testuser", mock.Anything, mock.Anything)
+			})
+
+			It("resets the failure count once the rich presence goes through", func() {
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.failures.testuser")
+			})
+		})
+
+		Context("transient failures", func() {
+			It("retries the presence once after a transient failure", func() {
+				setupConfigMocks()
+				host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+					return req.URL == "https://discord.com/api/gateway"
+				})).Return((*host.HTTPResponse)(nil), errors.New("connection reset")).Once()
+				var stored string
+				host.CacheMock.On("SetString", "discord.retry.testuser", mock.Anything, int64(retryDelay*6)).Run(func(args mock.Arguments) {
+					stored = args.String(1)
+				}).Return(nil)
+				host.SchedulerMock.On("ScheduleOneTime", int32(retryDelay), payloadRetry, "retry.testuser").Return("retry.testuser", nil)
+
+				err := plugin.PlaybackReport(baseRequest("playing"))
+				Expect(err).To(MatchError(ContainSubstring("connection reset")))
+				host.SchedulerMock.AssertExpectations(GinkgoT())
+				Expect(stored).To(ContainSubstring(`"title":"Test Song"`))
+
+				// The scheduled retry replays the stored report and succeeds
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.retry.testuser").Return(stored, true, nil)
+				host.CacheMock.On("Remove", "discord.retry.testuser").Return(nil)
+				registerCacheDefaults()
+				setupConnectMocks()
+				setupImageMocks()
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.String(1)
+				}).Return(nil)
+
+				err = plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "retry.testuser", Payload: payloadRetry})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sentPayload).To(ContainSubstring(`"details":"Test Song"`))
+				host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.retry.testuser")
+			})
+
+			It("does not retry more than once", func() {
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.retry.testuser").Return(`{"username":"testuser","state":"playing","track":{"id":"track1","title":"Test Song"}}`, true, nil)
+				host.CacheMock.On("Remove", "discord.retry.testuser").Return(nil)
+				registerCacheDefaults()
+				host.HTTPMock.On("Send", mock.Anything).Return((*host.HTTPResponse)(nil), errors.New("connection reset"))
+
+				err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "retry.testuser", Payload: payloadRetry})
+				Expect(err).To(MatchError(ContainSubstring("presence retry failed")))
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			It("does not retry configuration errors", func() {
+				pdk.PDKMock.On("GetConfig", transitionGraceKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", hideArtistsKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", hideGenresKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"otheruser","token":"token"}]`, true)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).ToNot(Succeed())
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			It("cancels a pending retry when a newer report arrives", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.retry.testuser").Return(`{}`, true, nil)
+				host.CacheMock.On("Remove", "discord.retry.testuser").Return(nil)
+				registerCacheDefaults()
+				host.SchedulerMock.On("CancelSchedule", "retry.testuser").Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("starting"))).To(Succeed())
+				host.SchedulerMock.AssertExpectations(GinkgoT())
+			})
+		})
+
+		Context("transition grace", func() {
+			It("sends a single presence for rapid track changes", func() {
+				pdk.PDKMock.On("GetConfig", transitionGraceKey).Return("2", true)
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.pending.testuser").Return("", false, nil).Once()
+				host.CacheMock.On("GetString", "discord.pending.testuser").Return("{}", true, nil).Once()
+				host.CacheMock.On("Remove", "discord.pending.testuser").Return(nil)
+				var stored string
+				host.CacheMock.On("SetString", "discord.pending.testuser", mock.Anything, int64(62)).Run(func(args mock.Arguments) {
+					stored = args.String(1)
+				}).Return(nil)
+				registerCacheDefaults()
+				host.SchedulerMock.On("ScheduleOneTime", int32(2), payloadPendingPresence, "pendingpresence.testuser").Return("pendingpresence.testuser", nil)
+				host.SchedulerMock.On("CancelSchedule", "pendingpresence.testuser").Return(nil)
+
+				first := baseRequest("playing")
+				second := baseRequest("playing")
+				second.Track.Title = "Interlude"
+				second.PositionMs = 0
+				Expect(plugin.PlaybackReport(first)).To(Succeed())
+				Expect(plugin.PlaybackReport(second)).To(Succeed())
+				host.SchedulerMock.AssertNumberOfCalls(GinkgoT(), "ScheduleOneTime", 2)
+				host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", "pendingpresence.testuser")
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+				Expect(stored).To(ContainSubstring(`"title":"Interlude"`))
+
+				// The schedule sends only the latest track
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.pending.testuser").Return(stored, true, nil)
+				host.CacheMock.On("Remove", "discord.pending.testuser").Return(nil)
+				registerCacheDefaults()
+				setupConnectMocks()
+				setupImageMocks()
+				var sent []string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					if strings.Contains(args.String(1), `"op":3`) {
+						sent = append(sent, args.String(1))
+					}
+				}).Return(nil)
+
+				err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "pendingpresence.testuser", Payload: payloadPendingPresence})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sent).To(HaveLen(1))
+				Expect(sent[0]).To(ContainSubstring(`"details":"Interlude"`))
+			})
+
+			It("sends further reports for the track already shown right away", func() {
+				pdk.PDKMock.On("GetConfig", transitionGraceKey).Return("2", true)
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.presentedtrack.testuser").Return("track1", true, nil)
+				registerCacheDefaults()
+				setupConnectMocks()
+				setupImageMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"op":3`)
+				}))
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, payloadPendingPresence, mock.Anything)
+			})
+
+			It("remembers the track it sent", func() {
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.presentedtrack.testuser", "track1", lastPresenceTTL)
+			})
+
+			It("sends paused reports right away", func() {
+				pdk.PDKMock.On("GetConfig", transitionGraceKey).Return("2", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("paused"))).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "SendText", "testuser", mock.Anything)
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, payloadPendingPresence, mock.Anything)
+			})
+		})
+
+		Context("presence refresh", func() {
+			lastPresence := `{"v":1,"presence":{"activities":[{"name":"Navidrome","type":2,"details":"Test Song","application_id":"1234567890123456789","status_display_type":0,"timestamps":{"start":1714599990000,"end":1714600170000},"assets":{"large_image":"mp:external/art"}}],"since":0,"status":"dnd","afk":false}}`
+
+			It("schedules a refresh after sending the presence", func() {
+				pdk.PDKMock.On("GetConfig", presenceRefreshKey).Return("60", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+				host.SchedulerMock.On("ScheduleRecurring", "@every 60s", payloadRefreshPresence, "refreshpresence.testuser").Return("refreshpresence.testuser", nil)
+				host.CacheMock.On("SetInt", "discord.refresh.testuser", int64(60), int64(connectionIDTTL)).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.SchedulerMock.AssertCalled(GinkgoT(), "ScheduleRecurring", "@every 60s", payloadRefreshPresence, "refreshpresence.testuser")
+				host.CacheMock.AssertCalled(GinkgoT(), "SetInt", "discord.refresh.testuser", int64(60), int64(connectionIDTTL))
+			})
+
+			It("keeps an existing refresh schedule", func() {
+				pdk.PDKMock.On("GetConfig", presenceRefreshKey).Return("60", true)
+				setupConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.refresh.testuser").Return(int64(60), true, nil)
+				registerCacheDefaults()
+				setupConnectMocks()
+				setupImageMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleRecurring", mock.Anything, payloadRefreshPresence, mock.Anything)
+			})
+
+			It("is disabled by default", func() {
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleRecurring", mock.Anything, payloadRefreshPresence, mock.Anything)
+			})
+
+			It("re-sends the last presence when the schedule fires", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.lastpresence.testuser").Return(lastPresence, true, nil)
+				registerCacheDefaults()
+				var sent string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sent = args.String(1)
+				}).Return(nil)
+
+				err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "refreshpresence.testuser", Payload: payloadRefreshPresence, IsRecurring: true})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sent).To(ContainSubstring(`"op":3`))
+				Expect(sent).To(ContainSubstring(`"timestamps":{"start":1714599990000,"end":1714600170000}`))
+			})
+
+			It("cancels the refresh once there is no presence left", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.refresh.testuser").Return(int64(60), true, nil)
+				host.CacheMock.On("Remove", "discord.refresh.testuser").Return(nil)
+				registerCacheDefaults()
+				host.SchedulerMock.On("CancelSchedule", "refreshpresence.testuser").Return(nil)
+
+				err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "refreshpresence.testuser", Payload: payloadRefreshPresence, IsRecurring: true})
+				Expect(err).ToNot(HaveOccurred())
+				host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", "refreshpresence.testuser")
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			})
+
+			It("doesn't restore the old activity over the custom status", func() {
+				pdk.PDKMock.On("GetConfig", customStatusFallbackKey).Return("true", true)
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.customstatus.testuser").Return(int64(1714600000), true, nil)
+				host.CacheMock.On("GetString", "discord.lastpresence.testuser").Return(lastPresence, true, nil)
+				host.CacheMock.On("GetInt", "discord.refresh.testuser").Return(int64(60), true, nil)
+				host.CacheMock.On("Remove", "discord.refresh.testuser").Return(nil)
+				registerCacheDefaults()
+				host.SchedulerMock.On("CancelSchedule", "refreshpresence.testuser").Return(nil)
+
+				err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{ScheduleID: "refreshpresence.testuser", Payload: payloadRefreshPresence, IsRecurring: true})
+				Expect(err).ToNot(HaveOccurred())
+				host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", "refreshpresence.testuser")
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			})
+
+			It("cancels the refresh when the presence is cleared", func() {
+				pdk.PDKMock.On("GetConfig", holdUntilScrobbleKey).Return("", false)
+				setupDefaultConfigMocks()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.refresh.testuser").Return(int64(60), true, nil)
+				host.CacheMock.On("Remove", "discord.refresh.testuser").Return(nil)
+				registerCacheDefaults()
+				host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+				host.SchedulerMock.On("CancelSchedule", mock.Anything).Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("stopped"))).To(Succeed())
+				host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", "refreshpresence.testuser")
+			})
+		})
+
+		Context("stopped state", func() {
+			It("clears activity and disconnects", func() {
+				pdk.PDKMock.On("GetConfig", holdUntilScrobbleKey).Return("", false)
+				setupDefaultConfigMocks()
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"op":3`) && strings.Contains(msg, `"activities":null`)
+				})).Return(nil)
+				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+				host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+
+				err := plugin.PlaybackReport(baseRequest("stopped"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("holding presence until the scrobble threshold", func() {
+			BeforeEach(func() {
+				pdk.PDKMock.On("GetConfig", holdUntilScrobbleKey).Return("true", true)
+				setupDefaultConfigMocks()
+			})
+
+			It("schedules the clear for when the track would be scrobbled", func() {
+				host.CacheMock.On("SetString", "discord.heldclear.testuser", "track1", int64(140)).Return(nil)
+				host.SchedulerMock.On("ScheduleOneTime", int32(80), payloadHeldClear, "heldclear.testuser").Return("heldclear.testuser", nil)
+
+				// Half of the 180s track is 90s; the report is at 10s
+				Expect(plugin.PlaybackReport(baseRequest("stopped"))).To(Succeed())
+				host.SchedulerMock.AssertExpectations(GinkgoT())
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			})
+
+			It("caps the threshold at 4 minutes for long tracks", func() {
+				host.CacheMock.On("SetString", "discord.heldclear.testuser", "track1", mock.Anything).Return(nil)
+				host.SchedulerMock.On("ScheduleOneTime", int32(230), payloadHeldClear, "heldclear.testuser").Return("heldclear.testuser", nil)
+
+				req := baseRequest("stopped")
+				req.Track.Duration = 1200
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				host.SchedulerMock.AssertExpectations(GinkgoT())
+			})
+
+			It("clears right away once the threshold has passed", func() {
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"activities":null`)
+				})).Return(nil)
+				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+				host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+
+				req := baseRequest("stopped")
+				req.PositionMs = 95000
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			It("clears the held presence from the callback", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.heldclear.testuser").Return("track1", true, nil)
+				host.CacheMock.On("Remove", "discord.heldclear.testuser").Return(nil)
+				host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+				registerCacheDefaults()
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"activities":null`)
+				})).Return(nil)
+				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+
+				Expect(plugin.OnCallback(scheduler.SchedulerCallbackRequest{
+					ScheduleID: "heldclear.testuser",
+					Payload:    payloadHeldClear,
+				})).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "CloseConnection", "testuser", int32(1000), "Navidrome disconnect")
+			})
+
+			It("cancels a held clear when playback resumes", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.heldclear.testuser").Return("track1", true, nil)
+				host.CacheMock.On("Remove", "discord.heldclear.testuser").Return(nil)
+				registerCacheDefaults()
+				host.SchedulerMock.On("CancelSchedule", "heldclear.testuser").Return(nil)
+
+				Expect(plugin.PlaybackReport(baseRequest("starting"))).To(Succeed())
+				host.SchedulerMock.AssertExpectations(GinkgoT())
+			})
+		})
+
+		Context("minimum display time and clear grace", func() {
+			It("keeps a short track's presence up for the minimum display time", func() {
+				pdk.PDKMock.On("GetConfig", minimumDisplaySecondsKey).Return("30", true)
+				pdk.PDKMock.On("GetConfig", clearGraceKey).Return("5", true)
+				setupDefaultConfigMocks()
+				host.CacheMock.On("SetString", "discord.heldclear.testuser", "track1", int64(70)).Return(nil)
+				host.SchedulerMock.On("ScheduleOneTime", int32(10), payloadHeldClear, "heldclear.testuser").Return("heldclear.testuser", nil)
+
+				req := baseRequest("stopped")
+				req.Track.Duration = 20
+				req.PositionMs = 20000
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				host.SchedulerMock.AssertExpectations(GinkgoT())
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			})
+
+			It("clears a finished track after the grace", func() {
+				pdk.PDKMock.On("GetConfig", minimumDisplaySecondsKey).Return("30", true)
+				pdk.PDKMock.On("GetConfig", clearGraceKey).Return("5", true)
+				setupDefaultConfigMocks()
+				host.CacheMock.On("SetString", "discord.heldclear.testuser", "track1", int64(65)).Return(nil)
+				host.SchedulerMock.On("ScheduleOneTime", int32(5), payloadHeldClear, "heldclear.testuser").Return("heldclear.testuser", nil)
+
+				req := baseRequest("stopped")
+				req.PositionMs = 180000
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				host.SchedulerMock.AssertExpectations(GinkgoT())
+			})
+
+			It("caps the grace", func() {
+				pdk.PDKMock.On("GetConfig", clearGraceKey).Return("600", true)
+				Expect(resolveClearGrace()).To(Equal(int64(maxClearGrace)))
+			})
+
+			It("ignores an invalid minimum display time", func() {
+				pdk.PDKMock.On("GetConfig", minimumDisplaySecondsKey).Return("soon", true)
+				Expect(resolveMinimumDisplay()).To(BeZero())
+			})
+		})
+
+		Context("live streams", func() {
+			It("clears right away when stopped, without a clear based on the duration", func() {
+				pdk.PDKMock.On("GetConfig", holdUntilScrobbleKey).Return("true", true)
+				setupDefaultConfigMocks()
+				host.CacheMock.On("Remove", "discord.anchor.testuser.track1").Return(nil)
+				host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"activities":null`)
+				})).Return(nil)
+				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+
+				req := baseRequest("stopped")
+				req.Track.Duration = 0
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+				host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.anchor.testuser.track1")
+				host.WebSocketMock.AssertCalled(GinkgoT(), "CloseConnection", "testuser", int32(1000), "Navidrome disconnect")
+			})
+		})
+
+		Context("expired state", func() {
+			It("clears activity and disconnects (same as stopped)", func() {
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"op":3`) && strings.Contains(msg, `"activities":null`)
+				})).Return(nil)
+				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+				host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+
+				err := plugin.PlaybackReport(baseRequest("expired"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		DescribeTable("activity name configuration",
+			func(configValue string, configExists bool, expectedName string, expectedDisplayType int) {
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+				pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", caaEnabledKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", activityNameKey).Return(configValue, configExists)
+				pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("", false)
+				setupDefaultConfigMocks()
+
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				err := plugin.PlaybackReport(baseRequest("playing"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"name":"%s"`, expectedName)))
+				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"status_display_type":%d`, expectedDisplayType)))
+			},
+			Entry("defaults to Navidrome when not configured", "", false, "Navidrome", 2),
+			Entry("defaults to Navidrome with explicit default value", "Default", true, "Navidrome", 2),
+			Entry("uses track title when configured", "Track", true, "Test Song", 0),
+			Entry("uses track album when configured", "Album", true, "Test Album", 0),
+			Entry("uses track artist when configured", "Artist", true, "Test Artist", 0),
+		)
+
+		DescribeTable("artist sources for display and Spotify links",
+			func(displaySource, linkSource, expectedState, expectedLinkArtist string) {
+				pdk.PDKMock.On("GetConfig", displayArtistKey).Return(displaySource, true)
+				pdk.PDKMock.On("GetConfig", linkArtistKey).Return(linkSource, true)
+				pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("true", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.CacheMock.On("GetString", spotifyURLKey).Return("https://open.spotify.com/track/cached", true, nil)
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				req := baseRequest("playing")
+				req.Track.Artists = []scrobbler.ArtistRef{{Name: "Test Artist"}}
+				req.Track.AlbumArtist = "Various Artists"
+				req.Track.AlbumArtists = []scrobbler.ArtistRef{{Name: "Various Artists"}}
+
+				err := plugin.PlaybackReport(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"state":"%s"`, expectedState)))
+				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"state_url":"%s"`, spotifySearchURL(expectedLinkArtist))))
+				host.CacheMock.AssertCalled(GinkgoT(), "GetString", spotifyCacheKey(expectedLinkArtist, "Test Song", "Test Album"))
+			},
+			Entry("track artist for both", artistSourceTrack, artistSourceTrack, "Test Artist", "Test Artist"),
+			Entry("track artist displayed, album artist for links", artistSourceTrack, artistSourceAlbum, "Test Artist", "Various Artists"),
+			Entry("album artist displayed, track artist for links", artistSourceAlbum, artistSourceTrack, "Various Artists", "Test Artist"),
+			Entry("album artist for both", artistSourceAlbum, artistSourceAlbum, "Various Artists", "Various Artists"),
+		)
+
+		DescribeTable("title and artist links",
+			func(linksEnabled, searchFallback string, artistMBID, cachedTrackURL, expectedDetailsURL, expectedStateURL string) {
+				pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return(linksEnabled, true)
+				pdk.PDKMock.On("GetConfig", spotifySearchFallbackKey).Return(searchFallback, true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+				host.CacheMock.On("GetString", spotifyURLKey).Return(cachedTrackURL, true, nil)
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				req := baseRequest("playing")
+				req.Track.Artists = []scrobbler.ArtistRef{{Name: "Test Artist", MBID: artistMBID}}
+
+				Expect(plugin.PlaybackReport(req)).To(Succeed())
+				if expectedDetailsURL == "" {
+					Expect(sentPayload).ToNot(ContainSubstring(`"details_url"`))
+				} else {
+					Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"details_url":"%s"`, expectedDetailsURL)))
+				}
+				if expectedStateURL == "" {
+					Expect(sentPayload).ToNot(ContainSubstring(`"state_url"`))
+				} else {
+					Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"state_url":"%s"`, expectedStateURL)))
+				}
+			},
+			Entry("links the artist's MusicBrainz page when its MBID is known",
+				"true", "", "artist-mbid", "https://open.spotify.com/track/cached",
+				"https://open.spotify.com/track/cached", "https://musicbrainz.org/artist/artist-mbid"),
+			Entry("falls back to a Spotify search for the artist",
+				"true", "", "", "https://open.spotify.com/track/cached",
+				"https://open.spotify.com/track/cached", "https://open.spotify.com/search/Test%20Artist"),
+			Entry("omits both links when nothing resolves and search is disabled",
+				"true", "false", "", "", "", ""),
+			Entry("keeps the artist page when the track doesn't resolve",
+				"true", "false", "artist-mbid", "", "", "https://musicbrainz.org/artist/artist-mbid"),
+			Entry("omits both links when link-through is disabled",
+				"false", "", "artist-mbid", "https://open.spotify.com/track/cached", "", ""),
+		)
+
+		It("shows the text but not the cover of hidden albums", func() {
+			pdk.PDKMock.On("GetConfig", hiddenAlbumsKey).Return("album-mbid", true)
+			setupConfigMocks()
+			setupConnectMocks()
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return strings.Contains(req.URL, "external-assets") && strings.Contains(string(req.Body), navidromeLogoURL)
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/logo"}]`)}, nil)
+
+			var sentPayload string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sentPayload = args.Get(1).(string)
+			}).Return(nil)
+
+			req := baseRequest("playing")
+			req.Track.MBZAlbumID = "album-mbid"
+			Expect(plugin.PlaybackReport(req)).To(Succeed())
+			Expect(sentPayload).To(ContainSubstring(`"details":"Test Song"`))
+			Expect(sentPayload).To(ContainSubstring(`"large_text":"Test Album"`))
+			Expect(sentPayload).To(ContainSubstring(`"large_image":"mp:external/logo"`))
+			host.ArtworkMock.AssertNotCalled(GinkgoT(), "GetTrackUrl", mock.Anything, mock.Anything)
+		})
+
+		It("adds a button linking to the resolved Spotify track", func() {
+			pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("true", true)
+			setupConfigMocks()
+			setupConnectMocks()
+			setupImageMocks()
+			host.CacheMock.On("GetString", spotifyURLKey).Return("https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", true, nil)
+
+			var sentPayload string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sentPayload = args.Get(1).(string)
+			}).Return(nil)
+
+			Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+			Expect(sentPayload).To(ContainSubstring(`"buttons":[{"label":"Listen on Spotify","url":"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC"}]`))
+		})
+
+		It("links the button to YouTube Music when chosen", func() {
+			pdk.PDKMock.On("GetConfig", linkServiceKey).Return(linkServiceYouTubeMusic, true)
+			setupConfigMocks()
+			setupConnectMocks()
+			setupImageMocks()
+			host.CacheMock.On("GetString", mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "ytm.url.") })).Return("", false, nil)
+			host.CacheMock.On("SetString", mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "ytm.url.") }), mock.Anything, ytmCacheTTL).Return(nil)
+
+			var sentPayload string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sentPayload = args.Get(1).(string)
+			}).Return(nil)
+
+			Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+			Expect(sentPayload).To(ContainSubstring(`"buttons":[{"label":"Listen on YouTube Music","url":"https://music.youtube.com/search?q=Test+Artist+Test+Song"}]`))
+		})
+
+		It("falls back to the Spotify button when song.link can't resolve the track", func() {
+			pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", linkServiceKey).Return(linkServiceSongLink, true)
+			setupConfigMocks()
+			setupConnectMocks()
+			setupImageMocks()
+			host.CacheMock.On("GetString", spotifyURLKey).Return("https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", true, nil)
+			host.CacheMock.On("GetString", odesliCacheKey("https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC")).Return("", true, nil)
+
+			var sentPayload string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sentPayload = args.Get(1).(string)
+			}).Return(nil)
+
+			Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+			Expect(sentPayload).To(ContainSubstring(`"buttons":[{"label":"Listen on Spotify","url":"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC"}]`))
+		})
+
+		Context("title-only tracks", func() {
+			titleOnlyRequest := func() scrobbler.PlaybackReportRequest {
+				req := baseRequest("playing")
+				req.Track = scrobbler.TrackInfo{ID: "track1", Title: "Track 01", Duration: 180}
+				return req
+			}
+
+			It("sends a minimal presence without links", func() {
+				pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("true", true)
+				pdk.PDKMock.On("GetConfig", activityNameKey).Return(activityNameArtist, true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				Expect(plugin.PlaybackReport(titleOnlyRequest())).To(Succeed())
+				Expect(sentPayload).To(ContainSubstring(`"name":"Navidrome"`))
+				Expect(sentPayload).To(ContainSubstring(`"details":"Track 01"`))
+				Expect(sentPayload).To(ContainSubstring(`"status_display_type":2`))
+				Expect(sentPayload).ToNot(ContainSubstring(`"state"`))
+				Expect(sentPayload).ToNot(ContainSubstring(`"buttons"`))
+				Expect(sentPayload).ToNot(ContainSubstring("spotify"))
+				host.CacheMock.AssertNotCalled(GinkgoT(), "GetString", spotifyURLKey)
+			})
+
+			It("shows the configured text in place of the artist", func() {
+				pdk.PDKMock.On("GetConfig", untaggedArtistKey).Return("Unknown Artist", true)
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				Expect(plugin.PlaybackReport(titleOnlyRequest())).To(Succeed())
+				Expect(sentPayload).To(ContainSubstring(`"state":"Unknown Artist"`))
+			})
+		})
+
+		It("omits buttons when no link resolves", func() {
+			setupConfigMocks()
+			setupConnectMocks()
+			setupImageMocks()
+
+			var sentPayload string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sentPayload = args.Get(1).(string)
+			}).Return(nil)
+
+			Expect(plugin.PlaybackReport(baseRequest("playing"))).To(Succeed())
+			Expect(sentPayload).ToNot(ContainSubstring(`"buttons"`))
+		})
+
+		DescribeTable("name verb compatibility mode",
+			func(nameVerb, activityName, expectedName string, expectedDisplayType int) {
+				pdk.PDKMock.On("GetConfig", nameVerbKey).Return(nameVerb, nameVerb != "")
+				pdk.PDKMock.On("GetConfig", activityNameKey).Return(activityName, activityName != "")
+				setupConfigMocks()
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				err := plugin.PlaybackReport(baseRequest("playing"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"name":"%s"`, expectedName)))
+				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"status_display_type":%d`, expectedDisplayType)))
+				Expect(sentPayload).To(ContainSubstring(`"type":2`))
+			},
+			Entry("status_display_type mode (default)", "", "", "Navidrome", statusDisplayDetails),
+			Entry("verb baked into the default name", "true", "", "Listening to Navidrome", statusDisplayName),
+			Entry("verb baked into the track name", "true", "Track", "Listening to Test Song", statusDisplayName),
+			Entry("disabled explicitly", "false", "Track", "Test Song", statusDisplayName),
+		)
+
+		DescribeTable("custom activity name template",
+			func(template string, templateExists bool, expectedName string) {
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+				pdk.PDKMock.On("GetConfig", uguuEnabledKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", caaEnabledKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", activityNameKey).Return("Custom", true)
+				pdk.PDKMock.On("GetConfig", activityNameTemplateKey).Return(template, templateExists)
+				pdk.PDKMock.On("GetConfig", spotifyLinksKey).Return("", false)
+				setupDefaultConfigMocks()
+				host.CacheMock.On("GetString", "discord.displayname.testuser").Return("Test User", true, nil).Maybe()
+
+				setupConnectMocks()
+				setupImageMocks()
+
+				var sentPayload string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sentPayload = args.Get(1).(string)
+				}).Return(nil)
+
+				err := plugin.PlaybackReport(baseRequest("playing"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sentPayload).To(ContainSubstring(fmt.Sprintf(`"name":"%s"`, expectedName)))
+			},
+			Entry("uses custom template with all placeholders", "{artist} - {track} ({album})", true, "Test Artist - Test Song (Test Album)"),
+			Entry("uses custom template with only track", "{track}", true, "Test Song"),
+			Entry("uses custom template with only artist", "{artist}", true, "Test Artist"),
+			Entry("uses custom template with only album", "{album}", true, "Test Album"),
+			Entry("uses custom template with plain text", "Now Playing", true, "Now Playing"),
+			Entry("uses custom template with the user's display name", "Listening on {user}'s server", true, "Listening on Test User's server"),
+			Entry("falls back to Navidrome when template is empty", "", false, "Navidrome"),
+		)
+	})
+
+	Describe("userDisplayName", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.UsersMock.ExpectedCalls = nil
+			host.UsersMock.Calls = nil
+		})
+
+		It("returns the cached display name", func() {
+			host.CacheMock.On("GetString", "discord.displayname.testuser").Return("Test User", true, nil)
+			Expect(userDisplayName("testuser")).To(Equal("Test User"))
+			host.UsersMock.AssertNotCalled(GinkgoT(), "GetUsers")
+		})
+
+		It("looks up and caches the Navidrome display name", func() {
+			host.CacheMock.On("GetString", "discord.displayname.testuser").Return("", false, nil)
+			host.CacheMock.On("SetString", "discord.displayname.testuser", "Test User", displayNameTTL).Return(nil)
+			host.UsersMock.On("GetUsers").Return([]host.User{
+				{UserName: "otheruser", Name: "Other User"},
+				{UserName: "testuser", Name: " Test User "},
+			}, nil)
+
+			Expect(userDisplayName("testuser")).To(Equal("Test User"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.displayname.testuser", "Test User", displayNameTTL)
+		})
+
+		It("falls back to the username for users without a display name", func() {
+			host.CacheMock.On("GetString", "discord.displayname.testuser").Return("", false, nil)
+			host.CacheMock.On("SetString", "discord.displayname.testuser", "testuser", displayNameTTL).Return(nil)
+			host.UsersMock.On("GetUsers").Return([]host.User{{UserName: "testuser"}}, nil)
+
+			Expect(userDisplayName("testuser")).To(Equal("testuser"))
+		})
+
+		It("falls back to the username without caching when the lookup fails", func() {
+			host.CacheMock.On("GetString", "discord.displayname.testuser").Return("", false, nil)
+			host.UsersMock.On("GetUsers").Return([]host.User(nil), errors.New("not allowed"))
+
+			Expect(userDisplayName("testuser")).To(Equal("testuser"))
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.displayname.testuser", mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("resolveAlbumText", func() {
+		DescribeTable("decorates multi-disc albums when enabled",
+			func(enabled string, discNumber int32, expected string) {
+				pdk.PDKMock.On("GetConfig", showDiscNumberKey).Return(enabled, enabled != "")
+				Expect(resolveAlbumText(scrobbler.TrackInfo{Album: "The Wall", DiscNumber: discNumber})).To(Equal(expected))
+			},
+			Entry("single-disc album", "true", int32(1), "The Wall"),
+			Entry("untagged disc number", "true", int32(0), "The Wall"),
+			Entry("second disc", "true", int32(2), "The Wall (Disc 2)"),
+			Entry("second disc with option disabled", "", int32(2), "The Wall"),
+		)
+	})
+
+	Describe("displayArtist", func() {
+		track := scrobbler.TrackInfo{Artist: "Alice feat. Bob", Artists: []scrobbler.ArtistRef{{Name: "Alice"}, {Name: "Bob"}}}
+
+		BeforeEach(func() {
+			pdk.ResetMock()
+		})
+
+		It("keeps the tagged artist by default", func() {
+			pdk.PDKMock.On("GetConfig", joinArtistsKey).Return("", false)
+			Expect(displayArtist(track)).To(Equal("Alice feat. Bob"))
+		})
+
+		It("joins all artists with the separator when enabled", func() {
+			pdk.PDKMock.On("GetConfig", joinArtistsKey).Return("true", true)
+			pdk.PDKMock.On("GetConfig", artistSeparatorKey).Return(" / ", true)
+			Expect(displayArtist(track)).To(Equal("Alice / Bob"))
+		})
+	})
+
+	DescribeTable("artistCredit",
+		func(names []string, separator, expected string) {
+			track := scrobbler.TrackInfo{Artist: "Tagged Artist"}
+			for _, name := range names {
+				track.Artists = append(track.Artists, scrobbler.ArtistRef{Name: name})
+			}
+			Expect(artistCredit(track, separator)).To(Equal(expected))
+		},
+		Entry("one artist keeps the tagged artist", []string{"Alice"}, "", "Tagged Artist"),
+		Entry("no artists keep the tagged artist", nil, "", "Tagged Artist"),
+		Entry("two artists", []string{"Alice", "Bob"}, "", "Alice & Bob"),
+		Entry("three artists", []string{"Alice", "Bob", "Carol"}, "", "Alice, Bob & Carol"),
+		Entry("empty names are skipped", []string{"Alice", " ", "Bob", ""}, "", "Alice & Bob"),
+		Entry("only one non-empty name", []string{"Alice", ""}, "", "Tagged Artist"),
+		Entry("a configured separator joins all artists", []string{"Alice", "Bob", "Carol"}, " / ", "Alice / Bob / Carol"),
+	)
+
+	Describe("withDisplaySuffixesStripped", func() {
+		DescribeTable("strips matching suffixes from the shown title and album",
+			func(patterns, title, album, expectedTitle, expectedAlbum string) {
+				pdk.PDKMock.On("GetConfig", displayStripPatternsKey).Return(patterns, patterns != "")
+				track := withDisplaySuffixesStripped(scrobbler.TrackInfo{ID: "track1", Title: title, Album: album})
+				Expect(track.Title).To(Equal(expectedTitle))
+				Expect(track.Album).To(Equal(expectedAlbum))
+				Expect(track.ID).To(Equal("track1"))
+			},
+			Entry("unset", "", "Airbag (Remastered)", "OK Computer (Remastered 2017)", "Airbag (Remastered)", "OK Computer (Remastered 2017)"),
+			Entry("remastered album", "remaster", "Airbag", "OK Computer (Remastered 2017)", "Airbag", "OK Computer"),
+			Entry("several suffixes", "remaster, deluxe", "Song - 2011 Remaster", "Album [Deluxe Edition] (Remastered)", "Song", "Album"),
+			Entry("year-only suffix", "19, 20", "Song", "Album (2017)", "Song", "Album"),
+			Entry("non-matching suffix", "remaster", "Song (Live)", "Album (Live at Wembley)", "Song (Live)", "Album (Live at Wembley)"),
+			Entry("suffix-only name", "remaster", "(Remastered)", "Album", "(Remastered)", "Album"),
+		)
+	})
+
+	Describe("resolvePresenceStatus", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		DescribeTable("normalizes the configured status",
+			func(value, expected string) {
+				pdk.PDKMock.On("GetConfig", presenceStatusKey).Return(value, value != "")
+				Expect(resolvePresenceStatus()).To(Equal(expected))
+			},
+			Entry("unset defaults to dnd", "", presenceStatusDND),
+			Entry("online", "online", presenceStatusOnline),
+			Entry("capitalized", "Online", presenceStatusOnline),
+			Entry("idle", "idle", presenceStatusIdle),
+			Entry("away", "Away", presenceStatusIdle),
+			Entry("uppercase DND", "DND", presenceStatusDND),
+			Entry("do_not_disturb", "do_not_disturb", presenceStatusDND),
+			Entry("spelled out with spaces", " Do Not Disturb ", presenceStatusDND),
+			Entry("hyphenated", "do-not-disturb", presenceStatusDND),
+			Entry("invisible", "invisible", presenceStatusInvisible),
+			Entry("offline", "OFFLINE", presenceStatusInvisible),
+			Entry("unknown falls back to dnd", "sleeping", presenceStatusDND),
+		)
+
+		It("warns about unknown values", func() {
+			pdk.PDKMock.On("GetConfig", presenceStatusKey).Return("sleeping", true)
+			resolvePresenceStatus()
+			pdk.PDKMock.AssertCalled(GinkgoT(), "Log", pdk.LogWarn, mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, "sleeping")
+			}))
+		})
+	})
+
+	Describe("resolveStatus", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", presenceStatusKey).Return("online", true).Maybe()
+		})
+
+		DescribeTable("picks the status for the playback state",
+			func(pausedStatus string, paused bool, expected string) {
+				pdk.PDKMock.On("GetConfig", pausedStatusKey).Return(pausedStatus, pausedStatus != "").Maybe()
+				Expect(resolveStatus(paused, userConfig{})).To(Equal(expected))
+			},
+			Entry("playing uses the presence status", "idle", false, presenceStatusOnline),
+			Entry("paused uses the paused status", "idle", true, presenceStatusIdle),
+			Entry("paused accepts aliases", "Away", true, presenceStatusIdle),
+			Entry("paused without a paused status", "", true, presenceStatusOnline),
+			Entry("paused with an unknown paused status", "napping", true, presenceStatusOnline),
+		)
+
+		DescribeTable("prefers the user's own status",
+			func(userStatus string, paused bool, expected string) {
+				pdk.PDKMock.On("GetConfig", pausedStatusKey).Return("", false).Maybe()
+				Expect(resolveStatus(paused, userConfig{Username: "user1", Status: userStatus})).To(Equal(expected))
+			},
+			Entry("overrides the presence status", "invisible", false, presenceStatusInvisible),
+			Entry("accepts aliases", "Away", false, presenceStatusIdle),
+			Entry("applies while paused without a paused status", "idle", true, presenceStatusIdle),
+			Entry("inherits when unset", "", false, presenceStatusOnline),
+			Entry("inherits when unknown", "napping", false, presenceStatusOnline),
+		)
+	})
+
+	Describe("resolveTruncation", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		DescribeTable("returns the configured strategy",
+			func(value, expected string) {
+				pdk.PDKMock.On("GetConfig", truncationKey).Return(value, value != "")
+				Expect(resolveTruncation()).To(Equal(expected))
+			},
+			Entry("unset defaults to ellipsis", "", truncateEllipsis),
+			Entry("cut", "cut", truncateCut),
+			Entry("ellipsis", "ellipsis", truncateEllipsis),
+			Entry("word", " Word ", truncateWord),
+			Entry("unknown falls back to ellipsis", "fancy", truncateEllipsis),
+		)
+	})
+
+	Describe("resolveParty", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		It("returns nil when no party ID is configured", func() {
+			pdk.PDKMock.On("GetConfig", partyIDKey).Return("", false)
+			Expect(resolveParty()).To(BeNil())
+		})
+
+		DescribeTable("parses the party size",
+			func(size string, expected []int) {
+				pdk.PDKMock.On("GetConfig", partyIDKey).Return("party-1", true)
+				pdk.PDKMock.On("GetConfig", partySizeKey).Return(size, size != "")
+				party := resolveParty()
+				Expect(party).ToNot(BeNil())
+				Expect(party.ID).To(Equal("party-1"))
+				Expect(party.Size).To(Equal(expected))
+			},
+			Entry("no size", "", nil),
+			Entry("maximum only", "4", []int{1, 4}),
+			Entry("current and maximum", "2 / 4", []int{2, 4}),
+			Entry("current above maximum is ignored", "5/4", nil),
+			Entry("non-numeric is ignored", "many", nil),
+		)
+	})
+
+	Describe("resolveDefaultImage", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("GetConfig", defaultImageListeningKey).Return("https://example.com/listening.png", true)
+			pdk.PDKMock.On("GetConfig", defaultImagePlayingKey).Return("https://example.com/playing.png", true)
+			pdk.PDKMock.On("GetConfig", defaultImageWatchingKey).Return("", false)
+		})
+
+		DescribeTable("selects the default image configured for the activity type",
+			func(activityType int, expected string) {
+				pdk.PDKMock.On("GetConfig", noDefaultImageKey).Return("", false)
+				Expect(resolveDefaultImage(activityType)).To(Equal(expected))
+			},
+			Entry("listening", activityTypeListening, "https://example.com/listening.png"),
+			Entry("playing", activityTypePlaying, "https://example.com/playing.png"),
+			Entry("watching falls back to the Navidrome logo", activityTypeWatching, navidromeLogoURL),
+		)
+
+		It("returns no image when the default image is disabled", func() {
+			pdk.PDKMock.On("GetConfig", noDefaultImageKey).Return("true", true)
+			Expect(resolveDefaultImage(activityTypeListening)).To(BeEmpty())
+		})
+	})
+
+	Describe("deterministic clock and random source", func() {
+		It("can be pinned for a single spec", func() {
+			pinClock(time.Unix(1714600000, 0))
+			pinRand(3)
+			Expect(now().Unix()).To(Equal(int64(1714600000)))
+			Expect(randIntn(5)).To(Equal(3))
+		})
+
+		It("is restored after the spec", func() {
+			Expect(now().Unix()).ToNot(Equal(int64(1714600000)))
+		})
+	})
+
+	Describe("configBaseURL", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		DescribeTable("validates the configured base URL",
+			func(value, expected string) {
+				pdk.PDKMock.On("GetConfig", listenBrainzBaseURLKey).Return(value, value != "")
+				Expect(configBaseURL(listenBrainzBaseURLKey, listenBrainzDefaultBaseURL)).To(Equal(expected))
+			},
+			Entry("unset uses the default", "", listenBrainzDefaultBaseURL),
+			Entry("https URL", "https://lb.example.com", "https://lb.example.com"),
+			Entry("https URL with path and trailing slash", " https://lb.example.com/labs/ ", "https://lb.example.com/labs"),
+			Entry("plain http is rejected", "http://lb.example.com", listenBrainzDefaultBaseURL),
+			Entry("missing scheme is rejected", "lb.example.com", listenBrainzDefaultBaseURL),
+			Entry("query string is rejected", "https://lb.example.com?x=1", listenBrainzDefaultBaseURL),
+		)
+	})
+
+	DescribeTable("cleanLinkURL",
+		func(link, expected string) {
+			Expect(cleanLinkURL(link)).To(Equal(expected))
+		},
+		Entry("strips si from a Spotify URL",
+			"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=a1b2c3d4e5f64789",
+			"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC"),
+		Entry("strips utm parameters and keeps the rest",
+			"https://music.example.com/watch?v=abc&utm_source=share&UTM_Medium=app&list=xyz",
+			"https://music.example.com/watch?v=abc&list=xyz"),
+		Entry("leaves URLs without tracking untouched",
+			"https://open.spotify.com/search/Test%20Artist",
+			"https://open.spotify.com/search/Test%20Artist"),
+		Entry("leaves empty links empty", "", ""),
+	)
+
+	Describe("OnCallback", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		It("handles heartbeat callback", func() {
+			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(42), true, nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+			err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{
+				ScheduleID:  "testuser",
+				Payload:     payloadHeartbeat,
+				IsRecurring: true,
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("routes reconnect callbacks to the user's connection", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"otheruser","token":"token"}]`, true)
+
+			err := plugin.OnCallback(scheduler.SchedulerCallbackRequest{
+				ScheduleID: "reconnect.testuser",
+				Payload:    payloadReconnect,
+			})
+			Expect(err).To(MatchError(ContainSubstring("user 'testuser' not authorized")))
 		})
 
 		It("logs warning for unknown payload", func() {
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// Cache TTLs for release label lookups
+const (
+	labelCacheTTLHit  int64 = 30 * 24 * 60 * 60 // 30 days for resolved labels
+	labelCacheTTLMiss int64 = 24 * 60 * 60      // 1 day for releases without a label
+)
+
+// musicBrainzTimeOut keeps a slow MusicBrainz response from blocking the presence update.
+const musicBrainzTimeOut = 4000
+
+// musicBrainzUserAgent identifies the plugin, as the MusicBrainz API requires.
+const musicBrainzUserAgent = "NavidromeDiscordRichPresence ( https://github.com/navidrome/discord-rich-presence-plugin )"
+
+// musicBrainzRelease captures the label information of a MusicBrainz release.
+type musicBrainzRelease struct {
+	LabelInfo []struct {
+		CatalogNumber string `json:"catalog-number"`
+		Label         *struct {
+			Name string `json:"name"`
+		} `json:"label"`
+	} `json:"label-info"`
+}
+
+// releaseLabel formats the first label of a release with its catalog number, e.g.
+// "Warp Records (WARPCD123)". Placeholder values MusicBrainz uses for releases
+// without a label or catalog number are skipped. Returns "" when nothing is known.
+func (r musicBrainzRelease) releaseLabel() string {
+	for _, info := range r.LabelInfo {
+		var name string
+		if info.Label != nil && !strings.EqualFold(info.Label.Name, "[no label]") {
+			name = info.Label.Name
+		}
+		catalog := info.CatalogNumber
+		if strings.EqualFold(catalog, "[none]") {
+			catalog = ""
+		}
+		switch {
+		case name != "" && catalog != "":
+			return fmt.Sprintf("%s (%s)", name, catalog)
+		case name != "":
+			return name
+		case catalog != "":
+			return catalog
+		}
+	}
+	return ""
+}
+
+// resolveReleaseLabel returns the label and catalog number of the track's release
+// from MusicBrainz, or "" when the release is unknown or has none. Results are
+// cached per release MBID.
+func resolveReleaseLabel(track scrobbler.TrackInfo) string {
+	if track.MBZAlbumID == "" {
+		return ""
+	}
+	cacheKey := "musicbrainz.label." + track.MBZAlbumID
+	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		return cached
+	}
+
+	label, err := fetchReleaseLabel(track.MBZAlbumID)
+	if err != nil {
+		// Not cached, so the next track of the release tries again
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("MusicBrainz label lookup failed for release %s: %v", track.MBZAlbumID, err))
+		return ""
+	}
+	ttl := labelCacheTTLHit
+	if label == "" {
+		ttl = labelCacheTTLMiss
+	}
+	_ = host.CacheSetString(cacheKey, label, ttl)
+	return label
+}
+
+// fetchReleaseLabel looks up a release's label information on MusicBrainz.
+func fetchReleaseLabel(mbid string) (string, error) {
+	resp, err := host.HTTPSend(host.HTTPRequest{
+		Method:    "GET",
+		URL:       fmt.Sprintf("https://musicbrainz.org/ws/2/release/%s?inc=labels&fmt=json", mbid),
+		Headers:   map[string]string{"User-Agent": musicBrainzUserAgent, "Accept": "application/json"},
+		TimeoutMs: musicBrainzTimeOut,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == 404 {
+		return "", nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var release musicBrainzRelease
+	if err := json.Unmarshal(resp.Body, &release); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return release.releaseLabel(), nil
+}
+
+// withReleaseLabel appends the release label to the album text when enabled and known.
+func withReleaseLabel(albumText string, track scrobbler.TrackInfo) string {
+	enabled, _ := pdk.GetConfig(showLabelKey)
+	if enabled != "true" || albumText == "" {
+		return albumText
+	}
+	if label := resolveReleaseLabel(track); label != "" {
+		return albumText + " · " + label
+	}
+	return albumText
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("release label", func() {
+	track := scrobbler.TrackInfo{Album: "Selected Ambient Works 85-92", MBZAlbumID: "release-1"}
+	isReleaseRequest := mock.MatchedBy(func(req host.HTTPRequest) bool {
+		return req.URL == "https://musicbrainz.org/ws/2/release/release-1?inc=labels&fmt=json" &&
+			req.Headers["User-Agent"] == musicBrainzUserAgent
+	})
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	DescribeTable("releaseLabel",
+		func(body, expected string) {
+			var release musicBrainzRelease
+			Expect(json.Unmarshal([]byte(body), &release)).To(Succeed())
+			Expect(release.releaseLabel()).To(Equal(expected))
+		},
+		Entry("label and catalog number", `{"label-info":[{"catalog-number":"AMB 3922 CD","label":{"name":"Apollo"}}]}`, "Apollo (AMB 3922 CD)"),
+		Entry("label only", `{"label-info":[{"catalog-number":null,"label":{"name":"Apollo"}}]}`, "Apollo"),
+		Entry("catalog number only", `{"label-info":[{"catalog-number":"AMB 3922 CD","label":null}]}`, "AMB 3922 CD"),
+		Entry("skips placeholders", `{"label-info":[{"catalog-number":"[none]","label":{"name":"[no label]"}}]}`, ""),
+		Entry("no label info", `{"label-info":[]}`, ""),
+	)
+
+	Describe("withReleaseLabel", func() {
+		It("leaves the album text alone when disabled", func() {
+			pdk.PDKMock.On("GetConfig", showLabelKey).Return("", false)
+
+			Expect(withReleaseLabel("Selected Ambient Works 85-92", track)).To(Equal("Selected Ambient Works 85-92"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		Context("when enabled", func() {
+			BeforeEach(func() {
+				pdk.PDKMock.On("GetConfig", showLabelKey).Return("true", true)
+			})
+
+			It("appends the label from MusicBrainz and caches it", func() {
+				host.CacheMock.On("GetString", "musicbrainz.label.release-1").Return("", false, nil)
+				host.CacheMock.On("SetString", "musicbrainz.label.release-1", "Apollo (AMB 3922 CD)", labelCacheTTLHit).Return(nil)
+				host.HTTPMock.On("Send", isReleaseRequest).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"id":"release-1","label-info":[{"catalog-number":"AMB 3922 CD","label":{"name":"Apollo"}}]}`)}, nil)
+
+				Expect(withReleaseLabel("Selected Ambient Works 85-92", track)).To(Equal("Selected Ambient Works 85-92 · Apollo (AMB 3922 CD)"))
+				host.CacheMock.AssertExpectations(GinkgoT())
+			})
+
+			It("uses the cached label", func() {
+				host.CacheMock.On("GetString", "musicbrainz.label.release-1").Return("Apollo (AMB 3922 CD)", true, nil)
+
+				Expect(withReleaseLabel("Selected Ambient Works 85-92", track)).To(Equal("Selected Ambient Works 85-92 · Apollo (AMB 3922 CD)"))
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+			})
+
+			It("caches releases without a label for a shorter time", func() {
+				host.CacheMock.On("GetString", "musicbrainz.label.release-1").Return("", false, nil)
+				host.CacheMock.On("SetString", "musicbrainz.label.release-1", "", labelCacheTTLMiss).Return(nil)
+				host.HTTPMock.On("Send", isReleaseRequest).Return(&host.HTTPResponse{StatusCode: 404, Body: []byte(`{"error":"Not Found"}`)}, nil)
+
+				Expect(withReleaseLabel("Selected Ambient Works 85-92", track)).To(Equal("Selected Ambient Works 85-92"))
+				host.CacheMock.AssertExpectations(GinkgoT())
+			})
+
+			It("doesn't cache failed lookups", func() {
+				host.CacheMock.On("GetString", "musicbrainz.label.release-1").Return("", false, nil)
+				host.HTTPMock.On("Send", isReleaseRequest).Return((*host.HTTPResponse)(nil), errors.New("timeout"))
+
+				Expect(withReleaseLabel("Selected Ambient Works 85-92", track)).To(Equal("Selected Ambient Works 85-92"))
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			It("skips tracks without a release MBID", func() {
+				Expect(withReleaseLabel("Untagged Album", scrobbler.TrackInfo{Album: "Untagged Album"})).To(Equal("Untagged Album"))
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+			})
+		})
+	})
+})
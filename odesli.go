@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// odesliAPIURL is the Odesli (song.link) endpoint resolving a streaming link to a
+// universal page listing the track on every service.
+const odesliAPIURL = "https://api.song.link/v1-alpha.1/links"
+
+// odesliResponse captures the relevant field from Odesli JSON responses.
+type odesliResponse struct {
+	PageURL string `json:"pageUrl"`
+}
+
+// odesliCacheKey returns the cache key for the universal link of a Spotify track URL.
+func odesliCacheKey(spotifyURL string) string {
+	return "odesli.url." + hashKey(spotifyURL)
+}
+
+// resolveOdesliURL returns the song.link page for a Spotify track URL, or "" when
+// Odesli can't resolve it. Search URLs aren't tracks, so they are never sent.
+// Results are cached, misses for a shorter time as Odesli is rate limited.
+func resolveOdesliURL(spotifyURL string) string {
+	if spotifyURL == "" || isSpotifySearchURL(spotifyURL) {
+		return ""
+	}
+	cacheKey := odesliCacheKey(spotifyURL)
+	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Odesli URL cache hit for %s → %q", spotifyURL, cached))
+		return cached
+	}
+
+	pageURL, err := fetchOdesliURL(spotifyURL)
+	if err != nil {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Odesli lookup failed for %s: %v", spotifyURL, err))
+		_ = host.CacheSetString(cacheKey, "", spotifyCacheTTLMiss)
+		return ""
+	}
+	_ = host.CacheSetString(cacheKey, pageURL, spotifyCacheTTLHit)
+	return pageURL
+}
+
+// fetchOdesliURL calls the Odesli API for a Spotify track URL.
+func fetchOdesliURL(spotifyURL string) (string, error) {
+	resp, err := host.HTTPSend(host.HTTPRequest{
+		Method: "GET",
+		URL:    odesliAPIURL + "?url=" + url.QueryEscape(spotifyURL),
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var result odesliResponse
+	if err := json.Unmarshal(resp.Body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !strings.HasPrefix(result.PageURL, "https://") {
+		return "", fmt.Errorf("response has no page URL")
+	}
+	return result.PageURL, nil
+}
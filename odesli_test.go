@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("resolveOdesliURL", func() {
+	const spotifyURL = "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"
+	cacheKey := odesliCacheKey(spotifyURL)
+	isOdesliRequest := mock.MatchedBy(func(req host.HTTPRequest) bool {
+		return req.Method == "GET" && req.URL == "https://api.song.link/v1-alpha.1/links?url=https%3A%2F%2Fopen.spotify.com%2Ftrack%2F63OQupATfueTdZMWIV7nzz"
+	})
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.HTTPMock.ExpectedCalls = nil
+		host.HTTPMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	It("returns the universal page URL and caches it", func() {
+		host.CacheMock.On("GetString", cacheKey).Return("", false, nil)
+		host.CacheMock.On("SetString", cacheKey, mock.Anything, mock.Anything).Return(nil)
+		host.HTTPMock.On("Send", isOdesliRequest).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"entityUniqueId":"SPOTIFY_SONG::63OQupATfueTdZMWIV7nzz","pageUrl":"https://song.link/s/63OQupATfueTdZMWIV7nzz"}`)}, nil)
+
+		Expect(resolveOdesliURL(spotifyURL)).To(Equal("https://song.link/s/63OQupATfueTdZMWIV7nzz"))
+		host.CacheMock.AssertCalled(GinkgoT(), "SetString", cacheKey, "https://song.link/s/63OQupATfueTdZMWIV7nzz", spotifyCacheTTLHit)
+	})
+
+	It("caches a miss when Odesli doesn't know the track", func() {
+		host.CacheMock.On("GetString", cacheKey).Return("", false, nil)
+		host.CacheMock.On("SetString", cacheKey, mock.Anything, mock.Anything).Return(nil)
+		host.HTTPMock.On("Send", isOdesliRequest).Return(&host.HTTPResponse{StatusCode: 404, Body: []byte(`{"statusCode":404,"code":"could_not_resolve_entity"}`)}, nil)
+
+		Expect(resolveOdesliURL(spotifyURL)).To(BeEmpty())
+		host.CacheMock.AssertCalled(GinkgoT(), "SetString", cacheKey, "", spotifyCacheTTLMiss)
+	})
+
+	It("returns the cached result without calling Odesli", func() {
+		host.CacheMock.On("GetString", cacheKey).Return("https://song.link/s/cached", true, nil)
+
+		Expect(resolveOdesliURL(spotifyURL)).To(Equal("https://song.link/s/cached"))
+		host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+	})
+
+	It("never sends search URLs", func() {
+		Expect(resolveOdesliURL(spotifySearchURL("Radiohead", "Karma Police"))).To(BeEmpty())
+		host.CacheMock.AssertNotCalled(GinkgoT(), "GetString", mock.Anything)
+		host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+	})
+})
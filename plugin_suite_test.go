@@ -3,6 +3,7 @@ package main
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	. "github.com/onsi/ginkgo/v2"
@@ -17,7 +18,136 @@ func TestDiscordPlugin(t *testing.T) {
 
 // Shared matchers for tighter mock expectations across all test files.
 var (
-	discordImageKey   = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "discord.image.") })
-	externalAssetsReq = mock.MatchedBy(func(req host.HTTPRequest) bool { return strings.Contains(req.URL, "external-assets") })
-	spotifyURLKey     = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "spotify.url.") })
+	discordImageKey    = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "discord.image.") })
+	externalAssetsReq  = mock.MatchedBy(func(req host.HTTPRequest) bool { return strings.Contains(req.URL, "external-assets") })
+	spotifyURLKey      = keyWithPrefix("spotify.url.", "spotify.mbid.")
+	rateLimitKey       = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "discord.ratelimit.") })
+	connectionIDKeys   = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "discord.connid.") })
+	lastErrorKeys      = mock.MatchedBy(func(key string) bool { return strings.HasPrefix(key, "discord.lasterror.") })
+	authFailedKeys     = keyWithPrefix("discord.authfailed.")
+	connectionUserKeys = keyWithPrefix("discord.connuser.")
+	sessionKeys        = keyWithPrefix("discord.session.")
+	heartbeatKeys      = keyWithPrefix("discord.heartbeat.")
+	heartbeatAckKeys   = keyWithPrefix("discord.ack.")
+	retryKeys          = keyWithPrefix("discord.retry.")
+	connectedKeys      = keyWithPrefix("discord.connected.")
+	reconnectKeys      = keyWithPrefix("discord.reconnects.")
+	failureKeys        = keyWithPrefix("discord.failures.")
+	heldClearKeys      = keyWithPrefix("discord.heldclear.")
+	pendingKeys        = keyWithPrefix("discord.pending.")
+	lastActivityKeys   = keyWithPrefix("discord.lastactivity.")
+	assetUploadedKeys  = keyWithPrefix("discord.imagetime.")
+	lastPresenceKeys   = keyWithPrefix("discord.lastpresence.")
+	listeningKeys      = keyWithPrefix("discord.album.")
+	gatewayRateLimit   = keyWithPrefix("discord.ratelimit." + gatewayRoute)
+	connStateKeys      = keyWithPrefix("discord.conn.")
+	refreshKeys        = keyWithPrefix("discord.refresh.")
+	heartbeatFailKeys  = keyWithPrefix("discord.heartbeatfailures.")
+	customStatusKeys   = keyWithPrefix("discord.customstatus.", "discord.statusfailures.")
+	connTokenKeys      = keyWithPrefix("discord.token.")
+	presentedTrackKeys = keyWithPrefix("discord.presentedtrack.")
+	imageUploadKeys    = keyWithPrefix("discord.imageupload.")
+	songKeys           = keyWithPrefix("discord.song.")
 )
+
+// keyWithPrefix matches cache keys starting with any of the given prefixes.
+func keyWithPrefix(prefixes ...string) any {
+	return mock.MatchedBy(func(key string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Specs never really sleep, so retry backoffs and rate limit waits return at once.
+var _ = BeforeEach(func() {
+	original := sleep
+	sleep = func(time.Duration) {}
+	DeferCleanup(func() { sleep = original })
+})
+
+// Cache health and the in-memory cache fallback are per plugin instance, so each spec
+// starts with a healthy cache and nothing kept in memory.
+var _ = BeforeEach(func() {
+	cacheHealth.failures, cacheHealth.degraded = 0, false
+	localCache.entries = map[string]localEntry{}
+})
+
+// pinClock makes now() return t for the rest of the current spec.
+func pinClock(t time.Time) {
+	original := now
+	now = func() time.Time { return t }
+	DeferCleanup(func() { now = original })
+}
+
+// pinRand makes randIntn return v for the rest of the current spec.
+func pinRand(v int) {
+	original := randIntn
+	randIntn = func(int) int { return v }
+	DeferCleanup(func() { randIntn = original })
+}
+
+// registerCacheDefaults treats per-user connection state as absent, apart from the
+// registry of live connections, so heartbeat callbacks proceed. Specs that need
+// specific values clear host.CacheMock.ExpectedCalls, register their expectations,
+// then call this, since the first matching expectation wins.
+func registerCacheDefaults() {
+	host.CacheMock.On("GetString", connectionIDKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("GetString", authFailedKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("GetString", sessionKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("GetString", connectionUserKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("Remove", sessionKeys).Return(nil).Maybe()
+	host.CacheMock.On("GetString", lastErrorKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("GetInt", heartbeatKeys).Return(int64(0), false, nil).Maybe()
+	host.CacheMock.On("GetInt", heartbeatAckKeys).Return(int64(0), false, nil).Maybe()
+	host.CacheMock.On("SetInt", heartbeatAckKeys, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("GetString", retryKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("GetString", heldClearKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("GetString", pendingKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("GetInt", connectedKeys).Return(int64(1714600000), true, nil).Maybe()
+	host.CacheMock.On("SetInt", connectedKeys, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("Remove", connectedKeys).Return(nil).Maybe()
+	host.CacheMock.On("GetInt", reconnectKeys).Return(int64(0), false, nil).Maybe()
+	host.CacheMock.On("SetInt", reconnectKeys, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("Remove", reconnectKeys).Return(nil).Maybe()
+	host.CacheMock.On("GetInt", failureKeys).Return(int64(0), false, nil).Maybe()
+	host.CacheMock.On("SetInt", failureKeys, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("Remove", failureKeys).Return(nil).Maybe()
+	host.CacheMock.On("SetString", lastErrorKeys, mock.Anything, lastErrorTTL).Return(nil).Maybe()
+	host.CacheMock.On("Remove", listeningKeys).Return(nil).Maybe()
+	host.CacheMock.On("GetString", lastActivityKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("SetString", lastActivityKeys, mock.Anything, lastActivityTTL).Return(nil).Maybe()
+	host.CacheMock.On("Remove", lastActivityKeys).Return(nil).Maybe()
+	host.CacheMock.On("SetInt", assetUploadedKeys, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("GetString", lastPresenceKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("SetString", lastPresenceKeys, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("Remove", lastPresenceKeys).Return(nil).Maybe()
+	host.CacheMock.On("GetInt", gatewayRateLimit).Return(int64(0), false, nil).Maybe()
+	host.CacheMock.On("GetString", gatewayURLKey).Return("", false, nil).Maybe()
+	host.CacheMock.On("SetString", gatewayURLKey, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("Remove", gatewayURLKey).Return(nil).Maybe()
+	host.CacheMock.On("GetString", publicInstanceCacheKey).Return("", false, nil).Maybe()
+	host.CacheMock.On("SetString", publicInstanceCacheKey, mock.Anything, publicInstanceTTL).Return(nil).Maybe()
+	host.CacheMock.On("GetString", connStateKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("SetString", connStateKeys, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("GetInt", refreshKeys).Return(int64(0), false, nil).Maybe()
+	host.CacheMock.On("GetInt", heartbeatFailKeys).Return(int64(0), false, nil).Maybe()
+	host.CacheMock.On("SetInt", heartbeatFailKeys, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("Remove", heartbeatFailKeys).Return(nil).Maybe()
+	host.CacheMock.On("GetInt", customStatusKeys).Return(int64(0), false, nil).Maybe()
+	host.CacheMock.On("SetInt", customStatusKeys, mock.Anything, mock.Anything).Return(nil).Maybe()
+	host.CacheMock.On("Remove", customStatusKeys).Return(nil).Maybe()
+	host.CacheMock.On("GetString", connTokenKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("SetString", connTokenKeys, mock.Anything, connTokenTTL).Return(nil).Maybe()
+	host.CacheMock.On("Remove", connTokenKeys).Return(nil).Maybe()
+	host.CacheMock.On("GetString", presentedTrackKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("SetString", presentedTrackKeys, mock.Anything, lastPresenceTTL).Return(nil).Maybe()
+	host.CacheMock.On("GetString", imageUploadKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("SetString", imageUploadKeys, mock.Anything, imageUploadTTL).Return(nil).Maybe()
+	host.CacheMock.On("Remove", imageUploadKeys).Return(nil).Maybe()
+	host.CacheMock.On("GetString", songKeys).Return("", false, nil).Maybe()
+	host.CacheMock.On("SetString", songKeys, mock.Anything, songCacheTTL).Return(nil).Maybe()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// losslessBadge is appended to the album text for lossless tracks.
+const losslessBadge = "Lossless"
+
+// losslessSuffixes are the file types that always hold lossless audio.
+var losslessSuffixes = map[string]bool{
+	"flac": true,
+	"alac": true,
+	"wav":  true,
+	"aif":  true,
+	"aiff": true,
+	"ape":  true,
+	"wv":   true,
+	"dsf":  true,
+	"dff":  true,
+}
+
+// isLossless reports whether a file suffix holds lossless audio. MP4 containers
+// (m4a) hold either AAC or ALAC; only ALAC reports a bit depth.
+func isLossless(suffix string, bitDepth int) bool {
+	suffix = strings.ToLower(suffix)
+	if suffix == "m4a" || suffix == "mp4" {
+		return bitDepth > 0
+	}
+	return losslessSuffixes[suffix]
+}
+
+// trackIsLossless reports whether the track is lossless. The file suffix comes
+// from the track path when the plugin can see it, and from Subsonic otherwise.
+func trackIsLossless(username string, track scrobbler.TrackInfo) bool {
+	if ext := strings.ToLower(strings.TrimPrefix(path.Ext(track.Path), ".")); ext != "" && ext != "m4a" && ext != "mp4" {
+		return isLossless(ext, 0)
+	}
+
+	song, ok := trackSong(username, track.ID)
+	return ok && isLossless(song.Suffix, song.BitDepth)
+}
+
+// withQualityBadge appends the lossless badge to the album text when enabled and
+// the track is lossless. Lossy tracks are left undecorated.
+func withQualityBadge(albumText, username string, track scrobbler.TrackInfo) string {
+	enabled, _ := pdk.GetConfig(losslessBadgeKey)
+	if enabled != "true" || albumText == "" || !trackIsLossless(username, track) {
+		return albumText
+	}
+	return albumText + " · " + losslessBadge
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("lossless badge", func() {
+	track := scrobbler.TrackInfo{ID: "track1", Album: "Test Album"}
+
+	songResponse := func(suffix string, bitDepth int) string {
+		return fmt.Sprintf(`{"subsonic-response":{"status":"ok","song":{"id":"track1","suffix":%q,"bitDepth":%d}}}`, suffix, bitDepth)
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.SubsonicAPIMock.ExpectedCalls = nil
+		host.SubsonicAPIMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	DescribeTable("isLossless",
+		func(suffix string, bitDepth int, expected bool) {
+			Expect(isLossless(suffix, bitDepth)).To(Equal(expected))
+		},
+		Entry("FLAC", "flac", 16, true),
+		Entry("uppercase FLAC", "FLAC", 0, true),
+		Entry("WAV", "wav", 24, true),
+		Entry("ALAC in m4a", "m4a", 16, true),
+		Entry("AAC in m4a", "m4a", 0, false),
+		Entry("MP3", "mp3", 0, false),
+		Entry("Opus", "opus", 0, false),
+		Entry("unknown", "", 0, false),
+	)
+
+	Describe("withQualityBadge", func() {
+		Context("when enabled", func() {
+			BeforeEach(func() {
+				pdk.PDKMock.On("GetConfig", losslessBadgeKey).Return("true", true)
+				host.CacheMock.On("GetString", "discord.song.track1").Return("", false, nil)
+			})
+
+			It("shows the badge for lossless tracks", func() {
+				host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").Return(songResponse("flac", 16), nil)
+				host.CacheMock.On("SetString", "discord.song.track1", `{"suffix":"flac","bitDepth":16}`, songCacheTTL).Return(nil)
+
+				Expect(withQualityBadge("Test Album", "testuser", track)).To(Equal("Test Album · Lossless"))
+				host.CacheMock.AssertExpectations(GinkgoT())
+			})
+
+			It("shows no badge for lossy tracks", func() {
+				host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").Return(songResponse("mp3", 0), nil)
+				host.CacheMock.On("SetString", "discord.song.track1", `{"suffix":"mp3"}`, songCacheTTL).Return(nil)
+
+				Expect(withQualityBadge("Test Album", "testuser", track)).To(Equal("Test Album"))
+			})
+
+			It("shows no badge when the song details are unavailable", func() {
+				host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").Return("", errors.New("not found"))
+
+				Expect(withQualityBadge("Test Album", "testuser", track)).To(Equal("Test Album"))
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			It("uses the track path when available", func() {
+				withPath := track
+				withPath.Path = "Artist/Album/01 - Track.flac"
+
+				Expect(withQualityBadge("Test Album", "testuser", withPath)).To(Equal("Test Album · Lossless"))
+				host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+			})
+		})
+
+		It("uses the cached result", func() {
+			pdk.PDKMock.On("GetConfig", losslessBadgeKey).Return("true", true)
+			host.CacheMock.On("GetString", "discord.song.track1").Return(`{"suffix":"flac","bitDepth":16}`, true, nil)
+
+			Expect(withQualityBadge("Test Album", "testuser", track)).To(Equal("Test Album · Lossless"))
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+
+		It("does nothing when disabled", func() {
+			pdk.PDKMock.On("GetConfig", losslessBadgeKey).Return("", false)
+
+			Expect(withQualityBadge("Test Album", "testuser", track)).To(Equal("Test Album"))
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+	})
+})
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// Scheduler callback payload for periodic presence refreshes
+const payloadRefreshPresence = "refreshpresence"
+
+// refreshPresenceScheduleIDPrefix prefixes the username in presence refresh schedule IDs.
+const refreshPresenceScheduleIDPrefix = "refreshpresence."
+
+// minPresenceRefresh is the shortest refresh interval, in seconds. Discord allows only
+// a few presence updates per minute, which track changes need as well.
+const minPresenceRefresh = 30
+
+// refreshScheduledKey returns the cache key holding the interval a user's refresh is
+// scheduled with, so it isn't scheduled again on every report.
+func refreshScheduledKey(username string) string {
+	return fmt.Sprintf("discord.refresh.%s", username)
+}
+
+// resolvePresenceRefresh returns how often the presence is sent again, in seconds.
+// 0 disables refreshes.
+func resolvePresenceRefresh() int64 {
+	option, _ := pdk.GetConfig(presenceRefreshKey)
+	option = strings.TrimSpace(option)
+	if option == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(option)
+	if err != nil || seconds < 0 {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid presence refresh interval %q, not refreshing", option))
+		return 0
+	}
+	if seconds == 0 {
+		return 0
+	}
+	return int64(max(seconds, minPresenceRefresh))
+}
+
+// schedulePresenceRefresh starts sending the user's presence again periodically, so
+// Discord clients re-sync the elapsed time on long tracks. An existing schedule with
+// the same interval is kept.
+func schedulePresenceRefresh(username string) {
+	interval := resolvePresenceRefresh()
+	scheduled, exists, err := host.CacheGetInt(refreshScheduledKey(username))
+	if err == nil && exists {
+		if scheduled == interval {
+			return
+		}
+		cancelPresenceRefresh(username)
+	}
+	if interval == 0 {
+		return
+	}
+
+	cronExpr := fmt.Sprintf("@every %ds", interval)
+	if _, err := host.SchedulerScheduleRecurring(cronExpr, payloadRefreshPresence, refreshPresenceScheduleIDPrefix+username); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule presence refresh for user %s: %v", username, err))
+		return
+	}
+	_ = host.CacheSetInt(refreshScheduledKey(username), interval, connectionIDTTL)
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Refreshing presence for user %s every %ds", username, interval))
+}
+
+// cancelPresenceRefresh stops the periodic refresh of a user's presence.
+func cancelPresenceRefresh(username string) {
+	if _, exists, err := host.CacheGetInt(refreshScheduledKey(username)); err != nil || !exists {
+		return
+	}
+	_ = host.CacheRemove(refreshScheduledKey(username))
+	_ = host.SchedulerCancelSchedule(refreshPresenceScheduleIDPrefix + username)
+}
+
+// handleRefreshPresenceCallback sends the user's last presence again. Once there is
+// none left, e.g. after the track ended, or the user was switched to the custom status,
+// the refresh is cancelled.
+func (r *discordRPC) handleRefreshPresenceCallback(scheduleID string) error {
+	username := strings.TrimPrefix(scheduleID, refreshPresenceScheduleIDPrefix)
+	if usingCustomStatus(username) && customStatusFallbackEnabled() {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Not refreshing presence for user %s: showing a custom status", username))
+		cancelPresenceRefresh(username)
+		return nil
+	}
+	presence, ok, err := loadLastPresence(username)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("No presence to refresh for user %s", username))
+		cancelPresenceRefresh(username)
+		return nil
+	}
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Refreshing presence for user %s", username))
+	return r.sendMessage(username, presenceOpCode, presence)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// Scheduler callback payload for presence retries
+const payloadRetry = "retry"
+
+// retryDelay is how long to wait before retrying a failed presence update, in seconds.
+const retryDelay = 10
+
+// retryScheduleIDPrefix prefixes the username in retry schedule IDs.
+const retryScheduleIDPrefix = "retry."
+
+// retryKey returns the cache key holding the report to retry for a user.
+func retryKey(username string) string {
+	return fmt.Sprintf("discord.retry.%s", username)
+}
+
+// isTransientError reports whether a failed presence update may succeed if retried.
+// Configuration and authorization problems won't fix themselves in a few seconds.
+func isTransientError(err error) bool {
+	return !errors.Is(err, scrobbler.ScrobblerErrorNotAuthorized) &&
+		!errors.Is(err, errMissingClientID) &&
+		!errors.Is(err, errInvalidClientID) &&
+		!errors.Is(err, errAuthFailed)
+}
+
+// scheduleRetry schedules a single, delayed retry of a failed playing/paused report,
+// so a transient blip doesn't skip a track's presence.
+func scheduleRetry(input scrobbler.PlaybackReportRequest) {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return
+	}
+	if err := host.CacheSetString(retryKey(input.Username), string(b), retryDelay*6); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to store presence retry for user %s: %v", input.Username, err))
+		return
+	}
+	if _, err := host.SchedulerScheduleOneTime(retryDelay, payloadRetry, retryScheduleIDPrefix+input.Username); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule presence retry for user %s: %v", input.Username, err))
+		_ = host.CacheRemove(retryKey(input.Username))
+		return
+	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Retrying presence for user %s in %ds", input.Username, retryDelay))
+}
+
+// cancelRetry drops a pending retry for a user, as a newer report supersedes it.
+func cancelRetry(username string) {
+	if _, exists, err := host.CacheGetString(retryKey(username)); err != nil || !exists {
+		return
+	}
+	_ = host.CacheRemove(retryKey(username))
+	_ = host.SchedulerCancelSchedule(retryScheduleIDPrefix + username)
+}
+
+// handleRetryCallback retries the stored report once. A second failure is recorded
+// but not retried again, and a track image deferred again falls back to the default image.
+func (p *discordPlugin) handleRetryCallback(scheduleID string) error {
+	username := strings.TrimPrefix(scheduleID, retryScheduleIDPrefix)
+	value, exists, err := host.CacheGetString(retryKey(username))
+	if err != nil || !exists {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("No pending presence retry for user %s", username))
+		return nil
+	}
+	_ = host.CacheRemove(retryKey(username))
+
+	var input scrobbler.PlaybackReportRequest
+	if err := json.Unmarshal([]byte(value), &input); err != nil {
+		return fmt.Errorf("failed to parse presence retry: %w", err)
+	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Retrying presence for user %s, track: %s", username, input.Track.Title))
+	if err := p.handlePlayingOrPaused(input, true); err != nil {
+		recordLastError(username, err)
+		return fmt.Errorf("presence retry failed: %w", err)
+	}
+	return nil
+}
@@ -11,9 +11,17 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
@@ -24,6 +32,8 @@ import (
 const (
 	imageCacheTTL        int64 = 4 * 60 * 60  // 4 hours for track artwork
 	defaultImageCacheTTL int64 = 48 * 60 * 60 // 48 hours for default Navidrome logo
+
+	assetCheckTimeout = 3000 // 3 seconds timeout for verifying stale external assets
 )
 
 // Scheduler callback payload for routing
@@ -38,9 +48,22 @@ type discordRPC struct{}
 
 // Discord WebSocket Gateway constants
 const (
-	heartbeatOpCode = 1 // Heartbeat operation code
-	gateOpCode      = 2 // Identify operation code
-	presenceOpCode  = 3 // Presence update operation code
+	heartbeatOpCode    = 1  // Heartbeat operation code
+	gateOpCode         = 2  // Identify operation code
+	presenceOpCode     = 3  // Presence update operation code
+	resumeOpCode       = 6  // Resume operation code
+	reconnectOpCode    = 7  // Reconnect requested operation code
+	invalidOpCode      = 9  // Invalid session operation code
+	helloOpCode        = 10 // Hello operation code, carries the heartbeat interval
+	heartbeatAckOpCode = 11 // Heartbeat ACK operation code
+)
+
+// Discord activity types
+const (
+	activityTypePlaying   = 0
+	activityTypeListening = 2
+	activityTypeWatching  = 3
+	activityTypeCustom    = 4
 )
 
 // Discord status_display_type values control how the activity is shown in the member list.
@@ -50,21 +73,119 @@ const (
 	statusDisplayDetails = 2 // Show details field in member list
 )
 
-const heartbeatInterval = 41 // Heartbeat interval in seconds
+// Discord presence status values
+const (
+	presenceStatusOnline    = "online"
+	presenceStatusIdle      = "idle"
+	presenceStatusDND       = "dnd"
+	presenceStatusInvisible = "invisible"
+)
+
+const heartbeatInterval = 41 // Default heartbeat interval in seconds, until Discord sends HELLO
+
+// closeCodeAuthenticationFailed is the gateway close code Discord sends for an invalid token.
+const closeCodeAuthenticationFailed = 4004
+
+// fatalCloseCodes are the gateway close codes after which reconnecting with the same
+// configuration cannot succeed, with the reason Discord gives for each.
+var fatalCloseCodes = map[int]string{
+	closeCodeAuthenticationFailed: "authentication failed",
+	4010:                          "invalid shard",
+	4011:                          "sharding required",
+	4012:                          "invalid API version",
+	4013:                          "invalid intents",
+	4014:                          "disallowed intents",
+}
+
+// isFatalCloseCode reports whether a gateway close code rules out reconnecting.
+func isFatalCloseCode(code int) bool {
+	_, fatal := fatalCloseCodes[code]
+	return fatal
+}
+
+// isRecoverableCloseCode reports whether Discord closed the connection for a reason
+// a new connection can recover from. Standard WebSocket codes, such as our own
+// closes, are left alone.
+func isRecoverableCloseCode(code int) bool {
+	return code >= 4000 && !isFatalCloseCode(code)
+}
+
+// deadSocketErrors are fragments of WebSocket errors after which the underlying
+// connection can't carry any more traffic, even when no close frame arrives. Other
+// errors, such as a single malformed frame, may leave the connection usable.
+var deadSocketErrors = []string{
+	"eof",
+	"broken pipe",
+	"connection reset",
+	"use of closed network connection",
+}
+
+// isDeadSocketError reports whether a WebSocket error means the connection is dead.
+func isDeadSocketError(message string) bool {
+	message = strings.ToLower(message)
+	for _, fragment := range deadSocketErrors {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
 
 // Discord API field length limits
 const (
 	maxTextLength = 128 // Max characters for text fields (details, state, name, large_text)
 	maxURLLength  = 256 // Max characters for URL fields (details_url, state_url, etc.)
+
+	maxButtons           = 2  // Max buttons on an activity
+	maxButtonLabelLength = 32 // Max characters for a button label
+
+	// Discord closes the gateway connection when a payload exceeds 4096 bytes.
+	maxPayloadSize = 4096
 )
 
-// truncateText truncates s to maxTextLength runes, appending "…" if truncated.
-func truncateText(s string) string {
+// truncateField truncates s to at most max runes, replacing the last one with "…"
+// if truncated. Lengths are counted in runes, so multibyte characters are never
+// cut mid-codepoint.
+func truncateField(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// Text truncation strategies
+const (
+	truncateCut      = "cut"      // Cut at the limit
+	truncateEllipsis = "ellipsis" // Cut at the limit, ending with "…"
+	truncateWord     = "word"     // Cut at the last word boundary, ending with "…"
+)
+
+// truncateText truncates s to maxTextLength runes using the given strategy.
+// An empty or unknown strategy is treated as truncateEllipsis.
+func truncateText(s, strategy string) string {
 	runes := []rune(s)
 	if len(runes) <= maxTextLength {
 		return s
 	}
-	return string(runes[:maxTextLength-1]) + "…"
+	switch strategy {
+	case truncateCut:
+		return string(runes[:maxTextLength])
+	case truncateWord:
+		// Only back off to a space in the second half, so a long unbroken word
+		// doesn't leave almost nothing behind.
+		for i := maxTextLength - 1; i >= maxTextLength/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				return strings.TrimRightFunc(string(runes[:i]), unicode.IsSpace) + "…"
+			}
+		}
+		return truncateField(s, maxTextLength)
+	default:
+		return truncateField(s, maxTextLength)
+	}
 }
 
 // truncateURL returns s unchanged if within maxURLLength, otherwise returns ""
@@ -76,18 +197,81 @@ func truncateURL(s string) string {
 	return ""
 }
 
+// fitButtons applies Discord's button limits: at most maxButtons, labels of up to
+// maxButtonLabelLength characters, and URLs within maxURLLength. Buttons without a
+// label or usable URL are dropped.
+func fitButtons(buttons []activityButton) []activityButton {
+	var fitted []activityButton
+	for _, button := range buttons {
+		button.URL = truncateURL(button.URL)
+		if button.Label == "" || button.URL == "" {
+			continue
+		}
+		button.Label = truncateField(button.Label, maxButtonLabelLength)
+		fitted = append(fitted, button)
+		if len(fitted) == maxButtons {
+			break
+		}
+	}
+	return fitted
+}
+
+// optionalFields lists the activity fields that can be dropped to keep a presence
+// update under maxPayloadSize, in the order they are sacrificed.
+var optionalFields = []struct {
+	name string
+	drop func(*activity)
+}{
+	{"small image", func(a *activity) { a.Assets.SmallImage, a.Assets.SmallText, a.Assets.SmallURL = "", "", "" }},
+	{"buttons", func(a *activity) { a.Buttons = nil }},
+	{"large image URL", func(a *activity) { a.Assets.LargeURL = "" }},
+	{"state URL", func(a *activity) { a.StateURL = "" }},
+	{"details URL", func(a *activity) { a.DetailsURL = "" }},
+}
+
+// fitPresence drops optional activity fields until the presence update fits in
+// maxPayloadSize, returning the names of the dropped fields.
+func fitPresence(presence *presencePayload) []string {
+	var dropped []string
+	for _, field := range optionalFields {
+		if payloadSize(presenceOpCode, presence) <= maxPayloadSize {
+			break
+		}
+		field.drop(&presence.Activities[0])
+		dropped = append(dropped, field.name)
+	}
+	return dropped
+}
+
+// payloadSize returns the encoded size of a gateway message, as built by sendMessage.
+func payloadSize(opCode int, payload any) int {
+	b, err := json.Marshal(map[string]any{"op": opCode, "d": payload})
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
 // activity represents a Discord activity sent via Gateway opcode 3.
 type activity struct {
 	Name              string             `json:"name"`
 	Type              int                `json:"type"`
 	Details           string             `json:"details"`
 	DetailsURL        string             `json:"details_url,omitempty"`
-	State             string             `json:"state"`
+	State             string             `json:"state,omitempty"`
 	StateURL          string             `json:"state_url,omitempty"`
 	Application       string             `json:"application_id"`
 	StatusDisplayType int                `json:"status_display_type"`
 	Timestamps        activityTimestamps `json:"timestamps"`
 	Assets            activityAssets     `json:"assets"`
+	Party             *activityParty     `json:"party,omitempty"`
+	Buttons           []activityButton   `json:"buttons,omitempty"`
+}
+
+// activityButton is a clickable link shown below the activity.
+type activityButton struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
 }
 
 type activityTimestamps struct {
@@ -104,6 +288,22 @@ type activityAssets struct {
 	SmallURL   string `json:"small_url,omitempty"`
 }
 
+// activityParty groups listeners into a party, shown by Discord as "X of Y".
+type activityParty struct {
+	ID   string `json:"id"`
+	Size []int  `json:"size,omitempty"` // [current, max]
+}
+
+// activityOptions holds per-send presence settings resolved from the plugin configuration.
+type activityOptions struct {
+	DefaultImage string // Large image used when the track artwork can't be processed; empty disables the fallback
+	Status       string // Presence status (online, idle, dnd, invisible); empty means dnd
+	Truncation   string // Text truncation strategy (cut, ellipsis, word); empty means ellipsis
+	Attribution  string // Appended to the large image text when the track artwork is used
+	AssetMode    string // How image URLs become Discord assets (externalassets, direct); empty means externalassets
+	FinalAttempt bool   // No retry follows, so a deferred track image falls back to the default image
+}
+
 // presencePayload represents a Discord presence update.
 type presencePayload struct {
 	Activities []activity `json:"activities"`
@@ -143,12 +343,40 @@ func (r *discordRPC) OnBinaryMessage(input websocket.OnBinaryMessageRequest) err
 // OnError handles WebSocket errors.
 func (r *discordRPC) OnError(input websocket.OnErrorRequest) error {
 	pdk.Log(pdk.LogWarn, fmt.Sprintf("WebSocket error for connection '%s': %s", input.ConnectionID, input.Error))
+	if !isDeadSocketError(input.Error) {
+		return nil
+	}
+	if enabled, _ := pdk.GetConfig(reconnectOnErrorKey); enabled != "true" {
+		return nil
+	}
+	username := r.connectionUser(input.ConnectionID)
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("WebSocket for user %s is no longer usable, reconnecting", username))
+	r.cleanupFailedConnection(username)
+	if err := r.scheduleBackoffReconnect(username); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule reconnect for user %s: %v", username, err))
+	}
 	return nil
 }
 
 // OnClose handles WebSocket connection closure.
 func (r *discordRPC) OnClose(input websocket.OnCloseRequest) error {
 	pdk.Log(pdk.LogInfo, fmt.Sprintf("WebSocket connection '%s' closed with code %d: %s", input.ConnectionID, input.Code, input.Reason))
+	code := int(input.Code)
+	// Normal closures are initiated by the plugin, which already updated the phase.
+	// Closes of a replaced connection must not mark its successor dead.
+	if username := r.connectionUser(input.ConnectionID); code != 1000 && r.connectionID(username) == input.ConnectionID {
+		r.setConnState(username, connStateDead)
+	}
+	switch {
+	case isFatalCloseCode(code):
+		username := r.connectionUser(input.ConnectionID)
+		r.markAuthFailed(username, connTokenFingerprint(username), fmt.Errorf("%w: %s (%d)", errAuthFailed, fatalCloseCodes[code], code))
+	case isRecoverableCloseCode(code):
+		username := r.connectionUser(input.ConnectionID)
+		if err := r.scheduleBackoffReconnect(username); err != nil {
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule reconnect for user %s: %v", username, err))
+		}
+	}
 	return nil
 }
 
@@ -156,6 +384,35 @@ func (r *discordRPC) OnClose(input websocket.OnCloseRequest) error {
 // Image Processing
 // ============================================================================
 
+// Asset modes: how image URLs are turned into something Discord shows
+const (
+	assetModeExternalAssets = "externalassets" // Proxied through Discord's external-assets endpoint (mp: URLs)
+	assetModeDirect         = "direct"         // Sent as is, in case external-assets stops working
+)
+
+// assetResolver turns an image URL into a Discord asset for the activity.
+type assetResolver func(imageURL, clientID, token string, ttl int64) (string, error)
+
+// resolverFor returns the resolver for an asset mode, defaulting to external-assets.
+func (r *discordRPC) resolverFor(mode string) assetResolver {
+	if mode == assetModeDirect {
+		return directAsset
+	}
+	return r.processImage
+}
+
+// directAsset uses an image URL as the asset without going through Discord. Discord
+// fetches https URLs itself for some asset types; anything else can't be shown.
+func directAsset(imageURL, _, _ string, _ int64) (string, error) {
+	if imageURL == "" {
+		return "", fmt.Errorf("image URL is empty")
+	}
+	if !strings.HasPrefix(imageURL, "mp:") && !strings.HasPrefix(imageURL, "https://") {
+		return "", fmt.Errorf("image URL must be https to be used directly")
+	}
+	return imageURL, nil
+}
+
 // processImage processes an image URL for Discord. Returns the processed image
 // string (mp:prefixed) or an error. No fallback logic — the caller handles retries.
 func (r *discordRPC) processImage(imageURL, clientID, token string, ttl int64) (string, error) {
@@ -168,11 +425,92 @@ func (r *discordRPC) processImage(imageURL, clientID, token string, ttl int64) (
 	}
 
 	// Check cache first
-	cacheKey := "discord.image." + hashKey(imageURL)
-	cachedValue, exists, err := host.CacheGetString(cacheKey)
-	if err == nil && exists {
-		pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for image URL: %s", imageURL))
+	hash := hashKey(imageURL)
+	cacheKey := "discord.image." + hash
+	cachedValue, exists, err := cacheGetString(cacheKey)
+	if err == nil && exists && isAssetFresh(hash, cachedValue) {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Cache hit for image URL: %s", redactURL(imageURL)))
 		return cachedValue, nil
+	} else if err == nil && exists {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Cached asset for image URL %s is no longer valid, uploading it again", redactURL(imageURL)))
+	}
+
+	previous := ""
+	if err == nil && exists {
+		previous = cachedValue
+	}
+	return shareImageUpload(hash, previous, func() (string, error) {
+		return uploadExternalAsset(imageURL, clientID, token, cacheKey, ttl)
+	})
+}
+
+// imageUploadTTL bounds how long an image upload is marked as in progress, in seconds,
+// so a marker left behind by a failed call doesn't hold the image back for long.
+const imageUploadTTL int64 = 10
+
+// imageUploadKey returns the cache key marking an upload of an image URL hash as in progress.
+func imageUploadKey(hash string) string {
+	return "discord.imageupload." + hash
+}
+
+// Waiting for another call's upload of the same image: its result is polled for this
+// many times, this far apart, before the image is deferred. Discord usually answers
+// within a second, so racing plays reuse the asset without waiting for a retry.
+const (
+	imageUploadPolls        = 4
+	imageUploadPollInterval = 500 * time.Millisecond
+)
+
+// shareImageUpload runs upload for the image with the given URL hash, unless another
+// call marked an upload of it as in progress. Then it waits a little for that upload
+// to cache an asset other than previous, the stale one being replaced, and reuses it;
+// if none turns up, the image is deferred and the retry of the presence update picks
+// it up. Plugin instances share nothing but the cache, so the marker lives there. The
+// cache has no atomic set-if-absent, so ownership is best-effort: the marker holds a
+// token that is read back after being set, and only the call whose token is found
+// there uploads and later removes it. Two calls racing in between may both upload.
+func shareImageUpload(hash, previous string, upload func() (string, error)) (string, error) {
+	key := imageUploadKey(hash)
+	if _, inProgress, err := host.CacheGetString(key); err == nil && inProgress {
+		return waitForImageUpload(hash, previous)
+	}
+	owner := strconv.Itoa(randIntn(math.MaxInt32))
+	_ = host.CacheSetString(key, owner, imageUploadTTL)
+	if current, exists, err := host.CacheGetString(key); err == nil && exists && current != owner {
+		return waitForImageUpload(hash, previous)
+	}
+	defer func() {
+		if current, exists, err := host.CacheGetString(key); err == nil && exists && current == owner {
+			_ = host.CacheRemove(key)
+		}
+	}()
+	return upload()
+}
+
+// waitForImageUpload polls for the asset another call is uploading for the image with
+// the given URL hash. It gives up early once that call removed its marker without
+// caching a new asset, which means its upload failed.
+func waitForImageUpload(hash, previous string) (string, error) {
+	for range imageUploadPolls {
+		sleep(imageUploadPollInterval)
+		if asset, exists, err := host.CacheGetString("discord.image." + hash); err == nil && exists && asset != "" && asset != previous {
+			return asset, nil
+		}
+		if _, inProgress, err := host.CacheGetString(imageUploadKey(hash)); err == nil && !inProgress {
+			break
+		}
+	}
+	return "", fmt.Errorf("image upload already in progress: %w", errImageDeferred)
+}
+
+// uploadExternalAsset registers an image URL with Discord's external-assets endpoint
+// and caches the resulting asset under cacheKey.
+func uploadExternalAsset(imageURL, clientID, token, cacheKey string, ttl int64) (string, error) {
+	// Defer the upload while the route's rate limit budget is exhausted
+	route := externalAssetsRoute + "." + clientID
+	if isRateLimited(route) {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Rate limit exhausted for %s, deferring image processing", route))
+		return "", fmt.Errorf("failed to process image: %w", errRateLimited)
 	}
 
 	// Process via Discord API
@@ -187,6 +525,13 @@ func (r *discordRPC) processImage(imageURL, clientID, token string, ttl int64) (
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("HTTP request failed for image processing: %v", err))
 		return "", fmt.Errorf("failed to process image: %w", err)
 	}
+	recordRateLimit(route, resp)
+	if resp.StatusCode == 429 {
+		return "", fmt.Errorf("failed to process image: %w", errRateLimited)
+	}
+	if resp.StatusCode == 401 || resp.StatusCode == 403 {
+		return "", fmt.Errorf("%w: external assets returned HTTP %d", errAuthFailed, resp.StatusCode)
+	}
 	if resp.StatusCode >= 400 {
 		return "", fmt.Errorf("failed to process image: HTTP %d", resp.StatusCode)
 	}
@@ -207,37 +552,203 @@ func (r *discordRPC) processImage(imageURL, clientID, token string, ttl int64) (
 
 	processedImage := fmt.Sprintf("mp:%s", image)
 
-	_ = host.CacheSetString(cacheKey, processedImage, ttl)
-	pdk.Log(pdk.LogDebug, fmt.Sprintf("Cached processed image URL for %s (TTL: %ds)", imageURL, ttl))
+	_ = cacheSetString(cacheKey, processedImage, ttl)
+	_ = host.CacheSetInt(assetUploadedKey(strings.TrimPrefix(cacheKey, "discord.image.")), now().Unix(), ttl)
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Cached processed image URL for %s (TTL: %ds)", redactURL(imageURL), ttl))
 
 	return processedImage, nil
 }
 
+// assetUploadedKey returns the cache key holding when the external asset for an
+// image URL hash was uploaded or last verified.
+func assetUploadedKey(hash string) string {
+	return "discord.imagetime." + hash
+}
+
+// resolveAssetFreshness returns how long a cached external asset is trusted before
+// it is verified again, in seconds. 0 trusts cached assets until they expire.
+func resolveAssetFreshness() int64 {
+	option, _ := pdk.GetConfig(assetFreshnessKey)
+	option = strings.TrimSpace(option)
+	if option == "" {
+		return 0
+	}
+	hours, err := strconv.Atoi(option)
+	if err != nil || hours < 0 {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid asset freshness %q, trusting cached assets", option))
+		return 0
+	}
+	return int64(hours) * 60 * 60
+}
+
+// isAssetFresh reports whether a cached external asset can be used as is. Assets
+// older than the freshness window are checked on Discord's media proxy: a missing
+// asset is dropped from the cache so it gets uploaded again, while an asset that
+// can't be checked right now is still used.
+func isAssetFresh(hash, asset string) bool {
+	window := resolveAssetFreshness()
+	if window == 0 {
+		return true
+	}
+	uploaded, exists, err := host.CacheGetInt(assetUploadedKey(hash))
+	if err == nil && exists && now().Unix()-uploaded < window {
+		return true
+	}
+
+	resp, err := host.HTTPSend(host.HTTPRequest{
+		Method:    "HEAD",
+		URL:       assetProxyURL(asset),
+		TimeoutMs: assetCheckTimeout,
+	})
+	if err != nil || resp.StatusCode >= 500 || resp.StatusCode == 429 {
+		return true
+	}
+	if resp.StatusCode >= 400 {
+		cacheRemove("discord.image." + hash)
+		_ = host.CacheRemove(assetUploadedKey(hash))
+		return false
+	}
+	_ = host.CacheSetInt(assetUploadedKey(hash), now().Unix(), imageCacheTTL)
+	return true
+}
+
+// assetProxyURL returns the media proxy URL Discord serves an external asset from.
+func assetProxyURL(asset string) string {
+	return "https://media.discordapp.net/" + strings.TrimPrefix(asset, "mp:")
+}
+
+// ============================================================================
+// Rate Limiting
+// ============================================================================
+
+// Discord communicates per-route rate limits through response headers. The remaining
+// budget is tracked in the cache with a TTL matching the reset window, so an exhausted
+// route stays blocked only until Discord replenishes it.
+const externalAssetsRoute = "external-assets"
+
+// rateLimitReserve is the remaining budget at which a route counts as exhausted. The
+// last request is kept in reserve, as concurrent reports would otherwise overrun it.
+const rateLimitReserve int64 = 1
+
+// errRateLimited is returned for requests deferred until a route's rate limit resets.
+var errRateLimited = errors.New("rate limited")
+
+// errImageDeferred is returned by sendActivity when the presence was sent without its
+// track image, because uploading it was rate limited or already in progress in another
+// call. Retrying the update later fills the image in.
+var errImageDeferred = errors.New("track image deferred until the rate limit resets")
+
+// headerValue returns the value of the named header, matching the name case-insensitively.
+func headerValue(headers map[string]string, name string) string {
+	if v, ok := headers[name]; ok {
+		return v
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseRateLimit extracts the remaining request budget and the seconds until it resets
+// from a Discord response. A 429 response always reports an exhausted budget, using
+// Retry-After when the reset header is missing. Returns ok=false when the headers are absent.
+func parseRateLimit(resp *host.HTTPResponse) (remaining int64, resetAfter int64, ok bool) {
+	remainingHeader := headerValue(resp.Headers, "X-RateLimit-Remaining")
+	resetHeader := headerValue(resp.Headers, "X-RateLimit-Reset-After")
+	if resp.StatusCode == 429 {
+		remainingHeader = "0"
+		if resetHeader == "" {
+			resetHeader = headerValue(resp.Headers, "Retry-After")
+		}
+	}
+	if remainingHeader == "" || resetHeader == "" {
+		return 0, 0, false
+	}
+
+	n, err := strconv.ParseInt(remainingHeader, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	secs, err := strconv.ParseFloat(resetHeader, 64)
+	if err != nil || secs < 0 {
+		return 0, 0, false
+	}
+	return n, max(int64(math.Ceil(secs)), 1), true
+}
+
+// recordRateLimit stores the remaining budget for a route until its reset window expires.
+func recordRateLimit(route string, resp *host.HTTPResponse) {
+	remaining, resetAfter, ok := parseRateLimit(resp)
+	if !ok {
+		return
+	}
+	if remaining <= 0 {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Discord rate limit reached for %s, resets in %ds", route, resetAfter))
+	}
+	_ = host.CacheSetInt("discord.ratelimit."+route, remaining, resetAfter)
+}
+
+// isRateLimited reports whether the route's budget is down to its reserve and not yet reset.
+func isRateLimited(route string) bool {
+	remaining, exists, err := host.CacheGetInt("discord.ratelimit." + route)
+	return err == nil && exists && remaining <= rateLimitReserve
+}
+
 // ============================================================================
 // Activity Management
 // ============================================================================
 
 // sendActivity sends an activity update to Discord.
-func (r *discordRPC) sendActivity(clientID, username, token string, data activity) error {
+func (r *discordRPC) sendActivity(clientID, username, token string, data activity, opts activityOptions) error {
+	fingerprint := activityFingerprint(data, opts)
+	if last, exists, err := host.CacheGetString(lastActivityKey(username)); err == nil && exists && last == fingerprint {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Activity for user %s is unchanged, not sending it again", username))
+		return nil
+	}
 	pdk.Log(pdk.LogInfo, fmt.Sprintf("Sending activity for user %s: %s - %s", username, data.Details, data.State))
 
-	// Truncate text fields to Discord's 128-character limit
-	data.Name = truncateText(data.Name)
-	data.Details = truncateText(data.Details)
-	data.State = truncateText(data.State)
-	data.Assets.LargeText = truncateText(data.Assets.LargeText)
+	// Clean up tag text, then truncate it to Discord's 128-character limit
+	data.Name = truncateText(sanitizeText(data.Name), opts.Truncation)
+	data.Details = truncateText(sanitizeText(data.Details), opts.Truncation)
+	data.State = truncateText(sanitizeText(data.State), opts.Truncation)
+	data.Assets.LargeText = truncateText(sanitizeText(data.Assets.LargeText), opts.Truncation)
 
 	// Omit URLs that exceed Discord's 256-character limit
 	data.DetailsURL = truncateURL(data.DetailsURL)
 	data.StateURL = truncateURL(data.StateURL)
 	data.Assets.LargeURL = truncateURL(data.Assets.LargeURL)
 	data.Assets.SmallURL = truncateURL(data.Assets.SmallURL)
-
-	// Try track artwork first, fall back to Navidrome logo
-	processedImage, err := r.processImage(data.Assets.LargeImage, clientID, token, imageCacheTTL)
-	if err != nil {
+	data.Buttons = fitButtons(data.Buttons)
+
+	// Try track artwork first, fall back to the configured default image
+	resolveAsset := r.resolverFor(opts.AssetMode)
+	processedImage, err := resolveAsset(data.Assets.LargeImage, clientID, token, imageCacheTTL)
+	imageDeferred := (errors.Is(err, errRateLimited) || errors.Is(err, errImageDeferred)) && !opts.FinalAttempt
+	if err != nil && !imageDeferred {
+		recordLastError(username, fmt.Errorf("track image: %w", err))
+	}
+	if imageDeferred {
+		// The default image would wait on the same rate limit, so the presence goes out
+		// without an image now and the caller retries once the limit resets or the
+		// upload in progress is cached
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Track image for user %s is deferred (%v), sending the presence without it for now", username, err))
+		data.Assets.LargeImage = ""
+	} else if errors.Is(err, errAuthFailed) {
+		// The default image would be rejected just the same
+		if resolveAssetAuthFailure() == assetAuthFailureDisable {
+			r.markAuthFailed(username, tokenFingerprint(token), err)
+			return err
+		}
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Discord rejected the image upload for user %s: %v, continuing without image", username, err))
+		data.Assets.LargeImage = ""
+	} else if err != nil && opts.DefaultImage == "" {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process track image for user %s: %v, continuing without image", username, err))
+		data.Assets.LargeImage = ""
+	} else if err != nil {
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process track image for user %s: %v, falling back to default", username, err))
-		processedImage, err = r.processImage(navidromeLogoURL, clientID, token, defaultImageCacheTTL)
+		processedImage, err = resolveAsset(opts.DefaultImage, clientID, token, defaultImageCacheTTL)
 		if err != nil {
 			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process default image for user %s: %v, continuing without image", username, err))
 			data.Assets.LargeImage = ""
@@ -246,13 +757,18 @@ func (r *discordRPC) sendActivity(clientID, username, token string, data activit
 		}
 	} else {
 		data.Assets.LargeImage = processedImage
+		if opts.Attribution != "" && data.Assets.LargeText != "" {
+			data.Assets.LargeText = truncateText(data.Assets.LargeText+" · "+opts.Attribution, opts.Truncation)
+		} else if opts.Attribution != "" {
+			data.Assets.LargeText = truncateText(opts.Attribution, opts.Truncation)
+		}
 	}
 
 	if data.Assets.LargeImage == "" {
 		data.Assets.SmallImage = ""
 		data.Assets.SmallText = ""
 	} else if data.Assets.SmallImage != "" {
-		processedSmall, err := r.processImage(data.Assets.SmallImage, clientID, token, defaultImageCacheTTL)
+		processedSmall, err := resolveAsset(data.Assets.SmallImage, clientID, token, defaultImageCacheTTL)
 		if err != nil {
 			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to process small image for user %s: %v", username, err))
 			data.Assets.SmallImage = ""
@@ -262,24 +778,328 @@ func (r *discordRPC) sendActivity(clientID, username, token string, data activit
 		}
 	}
 
+	status := opts.Status
+	if status == "" {
+		status = presenceStatusDND
+	}
 	presence := presencePayload{
 		Activities: []activity{data},
-		Status:     "dnd",
+		Status:     status,
 		Afk:        false,
 	}
+	if dropped := fitPresence(&presence); len(dropped) > 0 {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Presence for user %s exceeded %d bytes, dropped: %s", username, maxPayloadSize, strings.Join(dropped, ", ")))
+	}
+	if size := payloadSize(presenceOpCode, presence); size > maxPayloadSize {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Presence for user %s is still %d bytes after trimming optional fields", username, size))
+	}
+	// Discord drops invalid images without an error, so log what was sent to correlate
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Presence images for user %s: large=%q small=%q",
+		username, redactAsset(presence.Activities[0].Assets.LargeImage), redactAsset(presence.Activities[0].Assets.SmallImage)))
+	if err := r.sendMessage(username, presenceOpCode, presence); err != nil {
+		return err
+	}
+	storeLastPresence(username, presence)
+	if imageDeferred {
+		// Not remembered as sent, so the retry isn't skipped as unchanged
+		return errImageDeferred
+	}
+	_ = host.CacheSetString(lastActivityKey(username), fingerprint, lastActivityTTL)
+	return nil
+}
+
+// lastPresenceTTL bounds how long the last presence sent to a user is kept for
+// re-sending, in seconds. Presences with an end time expire when the track ends.
+const lastPresenceTTL int64 = 60 * 60
+
+// lastPresenceKey returns the cache key holding the last presence sent to a user.
+func lastPresenceKey(username string) string {
+	return fmt.Sprintf("discord.lastpresence.%s", username)
+}
+
+// lastPresenceVersion is bumped whenever the stored presence format changes, so a
+// presence stored by an older build of the plugin is dropped instead of misread.
+const lastPresenceVersion = 1
+
+// storedPresence is how the last presence is kept in the cache: the gateway payload
+// exactly as sent, tagged with its format version. It is written with a single cache
+// call, so a concurrent reader sees either the previous presence or the new one.
+type storedPresence struct {
+	Version  int             `json:"v"`
+	Presence presencePayload `json:"presence"`
+}
+
+// storeLastPresence keeps the presence just sent to a user, with its images already
+// processed, so it can be restored on a new connection without redoing any lookups.
+// It lives in the cache rather than in memory, so it survives the plugin reloading.
+func storeLastPresence(username string, presence presencePayload) {
+	ttl := lastPresenceTTL
+	if end := presence.Activities[0].Timestamps.End; end > 0 {
+		ttl = min(ttl, (end-now().UnixMilli())/1000)
+	}
+	if ttl <= 0 {
+		return
+	}
+	b, err := json.Marshal(storedPresence{Version: lastPresenceVersion, Presence: presence})
+	if err != nil {
+		return
+	}
+	_ = host.CacheSetString(lastPresenceKey(username), string(b), ttl)
+}
+
+// loadLastPresence returns the last presence stored for a user. It returns false when
+// there is none, e.g. once the track has ended, or when it was stored in another format.
+func loadLastPresence(username string) (presencePayload, bool, error) {
+	var stored storedPresence
+	value, exists, err := host.CacheGetString(lastPresenceKey(username))
+	if err != nil || !exists {
+		return presencePayload{}, false, nil
+	}
+	if err := json.Unmarshal([]byte(value), &stored); err != nil {
+		return presencePayload{}, false, fmt.Errorf("failed to parse last presence: %w", err)
+	}
+	if stored.Version != lastPresenceVersion || len(stored.Presence.Activities) == 0 {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Ignoring last presence of user %s stored in format %d", username, stored.Version))
+		return presencePayload{}, false, nil
+	}
+	return stored.Presence, true, nil
+}
+
+// resendLastPresence sends the last presence stored for a user again, if any.
+func (r *discordRPC) resendLastPresence(username string) error {
+	presence, ok, err := loadLastPresence(username)
+	if !ok {
+		return err
+	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Restoring last presence for user %s", username))
 	return r.sendMessage(username, presenceOpCode, presence)
 }
 
+// lastActivityTTL is how long a sent activity suppresses identical ones, in seconds.
+const lastActivityTTL int64 = 60
+
+// fingerprintTimestampMs is the granularity timestamps are rounded to when comparing
+// activities, so jitter in reported positions doesn't count as a change.
+const fingerprintTimestampMs = 5000
+
+// lastActivityKey returns the cache key holding the fingerprint of the activity last sent to a user.
+func lastActivityKey(username string) string {
+	return fmt.Sprintf("discord.lastactivity.%s", username)
+}
+
+// activityFingerprint identifies an activity as it is handed to sendActivity, before its
+// images are processed, so a repeated report for the same track can be skipped cheaply.
+func activityFingerprint(data activity, opts activityOptions) string {
+	data.Timestamps.Start = (data.Timestamps.Start + fingerprintTimestampMs/2) / fingerprintTimestampMs
+	data.Timestamps.End = (data.Timestamps.End + fingerprintTimestampMs/2) / fingerprintTimestampMs
+	b, _ := json.Marshal(struct {
+		Activity activity
+		Options  activityOptions
+	}{data, opts})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16])
+}
+
+// customStatusActivity is a Discord custom status, which shows a single line of text.
+type customStatusActivity struct {
+	Name  string `json:"name"`
+	Type  int    `json:"type"`
+	State string `json:"state"`
+}
+
+// customStatusPayload represents a Discord presence update carrying a custom status.
+type customStatusPayload struct {
+	Activities []customStatusActivity `json:"activities"`
+	Since      int64                  `json:"since"`
+	Status     string                 `json:"status"`
+	Afk        bool                   `json:"afk"`
+}
+
+// sendCustomStatus sets a custom status for a user in place of the rich presence activity.
+func (r *discordRPC) sendCustomStatus(username, text, status string) error {
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Sending custom status for user %s: %s", username, text))
+	if status == "" {
+		status = presenceStatusDND
+	}
+	return r.sendMessage(username, presenceOpCode, customStatusPayload{
+		Activities: []customStatusActivity{{Name: "Custom Status", Type: activityTypeCustom, State: truncateField(text, maxTextLength)}},
+		Status:     status,
+	})
+}
+
 // clearActivity clears the Discord activity for a user.
 func (r *discordRPC) clearActivity(username string) error {
 	pdk.Log(pdk.LogInfo, fmt.Sprintf("Clearing activity for user %s", username))
+	_ = host.CacheRemove(lastActivityKey(username))
+	_ = host.CacheRemove(lastPresenceKey(username))
 	return r.sendMessage(username, presenceOpCode, presencePayload{})
 }
 
+// ============================================================================
+// Authentication Failures
+// ============================================================================
+
+// authFailedTTL bounds how long a rejected token is remembered: 30 days
+const authFailedTTL int64 = 30 * 24 * 60 * 60
+
+// errAuthFailed is returned for users whose token was rejected by Discord.
+var errAuthFailed = errors.New("discord token was rejected")
+
+// authFailedKey returns the cache key holding the fingerprint of a user's rejected token.
+func authFailedKey(username string) string {
+	return fmt.Sprintf("discord.authfailed.%s", username)
+}
+
+// tokenFingerprint identifies a token without keeping the token itself in the cache.
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Behaviors when Discord rejects a user's token on the external-assets endpoint
+const (
+	assetAuthFailureDisable   = "disable"   // Treat the token as rejected, like a fatal close code
+	assetAuthFailureNoArtwork = "noartwork" // Keep updating the presence, without artwork
+)
+
+// resolveAssetAuthFailure returns the configured behavior for rejected image uploads,
+// defaulting to disabling the presence until the token is changed.
+func resolveAssetAuthFailure() string {
+	option, _ := pdk.GetConfig(assetAuthFailureKey)
+	switch option = strings.ToLower(strings.TrimSpace(option)); option {
+	case assetAuthFailureNoArtwork:
+		return option
+	case "", assetAuthFailureDisable:
+		return assetAuthFailureDisable
+	}
+	pdk.Log(pdk.LogWarn, fmt.Sprintf("Unknown asset auth failure behavior %q, using %s", option, assetAuthFailureDisable))
+	return assetAuthFailureDisable
+}
+
+// markAuthFailed remembers that Discord rejected a user's token, either with a fatal
+// close code or on an API call, so connects are skipped until the token is changed.
+// fingerprint identifies the rejected token: the one the connection or call was made
+// with, not whatever the config holds by now. Without it, nothing is remembered and
+// the next connect finds out again. The cause, wrapping errAuthFailed, is recorded as
+// the user's last error.
+func (r *discordRPC) markAuthFailed(username, fingerprint string, cause error) {
+	forgetConnToken(username)
+	recordLastError(username, cause)
+	if fingerprint == "" {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Discord rejected the token of user %s: %v", username, cause))
+		return
+	}
+	pdk.Log(pdk.LogWarn, fmt.Sprintf("Presence is disabled for user %s until the token is changed: %v", username, cause))
+	if err := host.CacheSetString(authFailedKey(username), fingerprint, authFailedTTL); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to remember rejected token for user %s: %v", username, err))
+	}
+}
+
+// isAuthFailed reports whether token is the one Discord rejected for the user.
+// A different token means the configuration changed, which clears the sentinel.
+func (r *discordRPC) isAuthFailed(username, token string) bool {
+	fingerprint, exists, err := host.CacheGetString(authFailedKey(username))
+	if err != nil || !exists {
+		return false
+	}
+	if fingerprint == tokenFingerprint(token) {
+		return true
+	}
+	_ = host.CacheRemove(authFailedKey(username))
+	return false
+}
+
 // ============================================================================
 // Low-level Communication
 // ============================================================================
 
+// connectionIDTTL bounds how long a username → connection ID mapping is kept.
+// It is refreshed on every heartbeat, so it only expires for dead connections.
+const connectionIDTTL = 24 * 60 * 60
+
+// connectionIDKey returns the cache key mapping a username to its WebSocket connection ID.
+func connectionIDKey(username string) string {
+	return fmt.Sprintf("discord.connid.%s", username)
+}
+
+// connectionID returns the WebSocket connection ID for a user. The host may
+// return a connection ID different from the one requested in WebSocketConnect,
+// in which case it is stored by connect; otherwise the username is the ID.
+func (r *discordRPC) connectionID(username string) string {
+	connID, exists, err := host.CacheGetString(connectionIDKey(username))
+	if err != nil || !exists || connID == "" {
+		return username
+	}
+	return connID
+}
+
+// connectedKey returns the cache key registering a user's live connection.
+func connectedKey(username string) string {
+	return fmt.Sprintf("discord.connected.%s", username)
+}
+
+// markConnected registers a live connection for a user. Like the connection ID
+// mapping, it is refreshed on every heartbeat.
+func (r *discordRPC) markConnected(username string) {
+	_ = host.CacheSetInt(connectedKey(username), now().Unix(), connectionIDTTL)
+}
+
+// hasLiveConnection reports whether a user has a registered connection. Schedules
+// can outlive it, e.g. across a Navidrome restart, which clears the cache.
+func (r *discordRPC) hasLiveConnection(username string) bool {
+	_, exists, err := host.CacheGetInt(connectedKey(username))
+	return err == nil && exists
+}
+
+// Connection phases, stored per user so connects can tell a usable connection from
+// a dead one without probing it.
+const (
+	connStateConnecting = "connecting"
+	connStateReady      = "ready"
+	connStateDead       = "dead"
+)
+
+// connectingTTL bounds how long a connection may take to become ready. A connection
+// still connecting after that is treated as absent, so the next report reconnects.
+const connectingTTL int64 = 60
+
+// connStateKey returns the cache key holding the phase of a user's connection.
+func connStateKey(username string) string {
+	return fmt.Sprintf("discord.conn.%s", username)
+}
+
+// setConnState records the phase of a user's connection.
+func (r *discordRPC) setConnState(username, state string) {
+	ttl := int64(connectionIDTTL)
+	if state == connStateConnecting {
+		ttl = connectingTTL
+	}
+	_ = host.CacheSetString(connStateKey(username), state, ttl)
+}
+
+// connState returns the phase of a user's connection, or "" when there is none.
+func (r *discordRPC) connState(username string) string {
+	state, exists, err := host.CacheGetString(connStateKey(username))
+	if err != nil || !exists {
+		return ""
+	}
+	return state
+}
+
+// connectionUserKey returns the cache key mapping a host-assigned connection ID back to its username.
+func connectionUserKey(connID string) string {
+	return fmt.Sprintf("discord.connuser.%s", connID)
+}
+
+// connectionUser returns the username for a WebSocket connection ID, the inverse of connectionID.
+func (r *discordRPC) connectionUser(connID string) string {
+	username, exists, err := host.CacheGetString(connectionUserKey(connID))
+	if err != nil || !exists || username == "" {
+		return connID
+	}
+	return username
+}
+
 // sendMessage sends a message over the WebSocket connection.
 func (r *discordRPC) sendMessage(username string, opCode int, payload any) error {
 	message := map[string]any{
@@ -290,41 +1110,169 @@ func (r *discordRPC) sendMessage(username string, opCode int, payload any) error
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
+	pdk.Log(pdk.LogTrace, fmt.Sprintf("Sending WebSocket message for user %s: %s", username, redactPayload(b)))
 
-	err = host.WebSocketSendText(username, string(b))
+	err = host.WebSocketSendText(r.connectionID(username), string(b))
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
 	return nil
 }
 
-// getDiscordGateway retrieves the Discord gateway URL.
+// redactPayload returns a marshaled gateway message with credentials replaced, for
+// use in log messages: tokens are masked, and image URLs and assets are redacted.
+func redactPayload(b []byte) string {
+	var message any
+	if err := json.Unmarshal(b, &message); err != nil {
+		return "[unparseable payload]"
+	}
+	redacted, _ := json.Marshal(redactValue("", message))
+	return string(redacted)
+}
+
+// redactValue redacts a decoded JSON value found under the given key.
+func redactValue(key string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, field := range v {
+			v[k] = redactValue(k, field)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(key, item)
+		}
+	case string:
+		switch {
+		case strings.EqualFold(key, "token"):
+			return "REDACTED"
+		case strings.HasPrefix(v, "mp:"):
+			return redactAsset(v)
+		case strings.HasPrefix(v, "http://"), strings.HasPrefix(v, "https://"):
+			return redactURL(v)
+		}
+	}
+	return value
+}
+
+// gatewayRoute identifies the gateway discovery endpoint in rate limit tracking.
+const gatewayRoute = "gateway"
+
+// defaultGatewayURL is used when the gateway can't be discovered and none is cached.
+const defaultGatewayURL = "wss://gateway.discord.gg"
+
+// maxGatewayRetryWait is the longest Retry-After, in seconds, waited out before asking
+// for the gateway again. Longer waits fall back to Discord's default gateway instead.
+const maxGatewayRetryWait = 5
+
+// gatewayURLKey is the cache key holding the last discovered gateway URL.
+const gatewayURLKey = "discord.gateway.url"
+
+// gatewayURLTTL is how long a discovered gateway URL is reused, in seconds. Discord
+// rarely moves it, so most connections skip the discovery request.
+const gatewayURLTTL int64 = 6 * 60 * 60
+
+// getDiscordGateway returns the gateway URL to connect to: the cached one while it is
+// fresh, otherwise a newly discovered one. When Discord rate limits the discovery
+// endpoint, a short Retry-After is waited out once; otherwise the default gateway is
+// used until the limit resets.
 func (r *discordRPC) getDiscordGateway() (string, error) {
-	resp, err := host.HTTPSend(host.HTTPRequest{
-		Method: "GET",
-		URL:    "https://discord.com/api/gateway",
-	})
-	if err != nil {
-		pdk.Log(pdk.LogWarn, fmt.Sprintf("HTTP request failed for Discord gateway: %v", err))
-		return "", fmt.Errorf("failed to get Discord gateway: %w", err)
+	if cached, exists, err := host.CacheGetString(gatewayURLKey); err == nil && exists && cached != "" {
+		return cached, nil
+	}
+	if isRateLimited(gatewayRoute) {
+		return defaultGatewayURL, nil
 	}
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("failed to get Discord gateway: HTTP %d", resp.StatusCode)
+	for attempt := 0; ; attempt++ {
+		resp, err := host.HTTPSend(host.HTTPRequest{
+			Method: "GET",
+			URL:    "https://discord.com/api/gateway",
+		})
+		if err != nil {
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("HTTP request failed for Discord gateway: %v", err))
+			return "", fmt.Errorf("failed to get Discord gateway: %w", err)
+		}
+		recordRateLimit(gatewayRoute, resp)
+		if resp.StatusCode == 429 {
+			_, wait, ok := parseRateLimit(resp)
+			if !ok {
+				wait = 1
+			}
+			if attempt == 0 && wait <= maxGatewayRetryWait {
+				pdk.Log(pdk.LogInfo, fmt.Sprintf("Discord gateway lookup rate limited, retrying in %ds", wait))
+				sleep(time.Duration(wait) * time.Second)
+				continue
+			}
+			pdk.Log(pdk.LogWarn, "Discord gateway lookup rate limited, using the default gateway")
+			return defaultGatewayURL, nil
+		}
+		if resp.StatusCode != 200 {
+			return "", fmt.Errorf("failed to get Discord gateway: HTTP %d", resp.StatusCode)
+		}
+
+		var result map[string]string
+		if err := json.Unmarshal(resp.Body, &result); err != nil {
+			return "", fmt.Errorf("failed to parse Discord gateway response: %w", err)
+		}
+		if result["url"] != "" {
+			_ = host.CacheSetString(gatewayURLKey, result["url"], gatewayURLTTL)
+		}
+		return result["url"], nil
+	}
+}
+
+// forgetGatewayURL drops the cached gateway URL after a connection to it failed, so
+// the next connection discovers the gateway again.
+func forgetGatewayURL() {
+	_ = host.CacheRemove(gatewayURLKey)
+}
+
+// gatewayVersion is the Discord gateway API version the plugin speaks.
+const gatewayVersion = 10
+
+// gatewayParams are the query parameters set on the gateway URL when connecting.
+// Pinning them keeps Discord from applying its own, changeable, defaults.
+var gatewayParams = [][2]string{
+	{"v", strconv.Itoa(gatewayVersion)},
+	{"encoding", "json"},
+}
+
+// gatewayConnectURL returns the discovered gateway URL with gatewayParams set. The URL
+// is parsed rather than concatenated, so any path or query parameters Discord includes
+// are preserved; parameters we set replace existing ones with the same name.
+func gatewayConnectURL(gateway string) (string, error) {
+	u, err := url.Parse(gateway)
+	if err != nil || u.Host == "" || (u.Scheme != "wss" && u.Scheme != "ws") {
+		return "", fmt.Errorf("invalid gateway URL %q", gateway)
 	}
 
-	var result map[string]string
-	if err := json.Unmarshal(resp.Body, &result); err != nil {
-		return "", fmt.Errorf("failed to parse Discord gateway response: %w", err)
+	query := make([]string, 0, len(gatewayParams))
+	overridden := make(map[string]bool, len(gatewayParams))
+	for _, param := range gatewayParams {
+		query = append(query, url.QueryEscape(param[0])+"="+url.QueryEscape(param[1]))
+		overridden[param[0]] = true
 	}
-	return result["url"], nil
+	for _, part := range strings.Split(u.RawQuery, "&") {
+		name, _, _ := strings.Cut(part, "=")
+		if part == "" || overridden[name] {
+			continue
+		}
+		query = append(query, part)
+	}
+	u.RawQuery = strings.Join(query, "&")
+	return u.String(), nil
 }
 
 // sendHeartbeat sends a heartbeat to Discord.
 func (r *discordRPC) sendHeartbeat(username string) error {
-	seqNum, _, err := host.CacheGetInt(fmt.Sprintf("discord.seq.%s", username))
+	connID := r.connectionID(username)
+	seqNum, err := loadSeq(connID)
 	if err != nil {
 		return fmt.Errorf("failed to get sequence number: %w", err)
 	}
+	if connID != username {
+		_ = host.CacheSetString(connectionIDKey(username), connID, connectionIDTTL)
+	}
+	r.markConnected(username)
 
 	pdk.Log(pdk.LogDebug, fmt.Sprintf("Sending heartbeat for user %s: %d", username, seqNum))
 	return r.sendMessage(username, heartbeatOpCode, seqNum)
@@ -340,48 +1288,160 @@ func (r *discordRPC) cleanupFailedConnection(username string) {
 	}
 
 	// Close the WebSocket connection
-	if err := host.WebSocketCloseConnection(username, 1000, "Connection lost"); err != nil {
+	connID := r.connectionID(username)
+	if err := host.WebSocketCloseConnection(connID, 1000, "Connection lost"); err != nil {
 		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to close WebSocket connection for user %s: %v", username, err))
 	}
 
 	// Clean up cache entries
-	_ = host.CacheRemove(fmt.Sprintf("discord.seq.%s", username))
+	removeSeq(connID)
+	if connID != username {
+		_ = host.CacheRemove(connectionIDKey(username))
+	}
+	_ = host.CacheRemove(connectedKey(username))
+	_ = host.CacheRemove(lastActivityKey(username))
+	_ = host.CacheRemove(heartbeatFailuresKey(username))
+	r.setConnState(username, connStateDead)
 
 	pdk.Log(pdk.LogInfo, fmt.Sprintf("Cleaned up connection for user %s", username))
 }
 
-// isConnected checks if a user is connected to Discord by testing the heartbeat.
-func (r *discordRPC) isConnected(username string) bool {
-	err := r.sendHeartbeat(username)
-	if err != nil {
-		pdk.Log(pdk.LogDebug, fmt.Sprintf("Heartbeat test failed for user %s: %v", username, err))
-		return false
-	}
-	return true
-}
-
 // connect establishes a connection to Discord for a user.
-func (r *discordRPC) connect(username, token string) error {
-	if r.isConnected(username) {
-		pdk.Log(pdk.LogInfo, fmt.Sprintf("Reusing existing connection for user %s", username))
+func (r *discordRPC) connect(username, token string) (err error) {
+	if state := r.connState(username); state == connStateReady || state == connStateConnecting {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Reusing existing connection for user %s (%s)", username, state))
 		return nil
 	}
 	pdk.Log(pdk.LogInfo, fmt.Sprintf("Creating new connection for user %s", username))
+	// A new connection starts without a presence, so the last activity must be sent again
+	_ = host.CacheRemove(lastActivityKey(username))
+	r.setConnState(username, connStateConnecting)
+	defer func() {
+		if err != nil {
+			r.setConnState(username, connStateDead)
+		}
+	}()
+
+	// Resume the previous session when there is one, on the gateway Discord asked for.
+	// A resume gateway that can't be reached is given up on along with its session,
+	// so the next attempts don't keep trying it.
+	session, resuming := r.getSession(username)
+	var connID string
+	connected := false
+	if resuming && session.ResumeURL != "" {
+		connID, err = dialGateway(username, session.ResumeURL)
+		if err != nil {
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to connect to the resume gateway for user %s, identifying on a new session instead: %v", username, err))
+			r.clearSession(username)
+			resuming = false
+		}
+		connected = err == nil
+	}
+	if !connected {
+		gateway, err := r.getDiscordGateway()
+		if err != nil {
+			return fmt.Errorf("failed to get Discord gateway: %w", err)
+		}
+		if connID, err = dialGateway(username, gateway); err != nil {
+			forgetGatewayURL()
+			return err
+		}
+	}
+	if connID != "" && connID != username {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Host assigned connection ID %s to user %s", connID, username))
+		if err := host.CacheSetString(connectionIDKey(username), connID, connectionIDTTL); err != nil {
+			return fmt.Errorf("failed to store connection ID: %w", err)
+		}
+		_ = host.CacheSetString(connectionUserKey(connID), username, connectionIDTTL)
+	} else if r.connectionID(username) != username {
+		_ = host.CacheRemove(connectionIDKey(username))
+	}
 
-	// Get Discord Gateway URL
-	gateway, err := r.getDiscordGateway()
+	if resuming {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Resuming gateway session for user %s", username))
+		payload := resumePayload{Token: token, SessionID: session.SessionID, Seq: session.Seq}
+		if err := r.sendMessage(username, resumeOpCode, payload); err != nil {
+			return fmt.Errorf("failed to send resume payload: %w", err)
+		}
+	} else if err := r.identify(username, token); err != nil {
+		return err
+	}
+
+	// Schedule heartbeats for this user/connection
+	r.markConnected(username)
+	r.setHeartbeatAcked(username, true)
+	if err := r.scheduleHeartbeat(username, r.heartbeatSeconds(username)); err != nil {
+		return err
+	}
+
+	storeConnToken(username, token)
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Successfully authenticated user %s", username))
+	return nil
+}
+
+// dialGateway opens a WebSocket connection to a gateway for a user, returning the
+// connection ID assigned by the host.
+func dialGateway(username, gateway string) (string, error) {
+	connectURL, err := gatewayConnectURL(gateway)
 	if err != nil {
-		return fmt.Errorf("failed to get Discord gateway: %w", err)
+		return "", fmt.Errorf("failed to get Discord gateway: %w", err)
 	}
-	pdk.Log(pdk.LogDebug, fmt.Sprintf("Using gateway: %s", gateway))
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Using gateway: %s", connectURL))
+	connID, err := host.WebSocketConnect(connectURL, nil, username)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to WebSocket: %w", err)
+	}
+	return connID, nil
+}
+
+// heartbeatIntervalKey returns the cache key holding the heartbeat interval Discord asked a user's connection for.
+func heartbeatIntervalKey(username string) string {
+	return fmt.Sprintf("discord.heartbeat.%s", username)
+}
 
-	// Connect to Discord Gateway
-	_, err = host.WebSocketConnect(gateway, nil, username)
+// heartbeatSeconds returns the heartbeat interval for a user, from the last HELLO
+// frame when known and heartbeatInterval otherwise.
+func (r *discordRPC) heartbeatSeconds(username string) int64 {
+	seconds, exists, err := host.CacheGetInt(heartbeatIntervalKey(username))
+	if err != nil || !exists || seconds <= 0 {
+		return heartbeatInterval
+	}
+	return seconds
+}
+
+// scheduleHeartbeat schedules the recurring heartbeat for a user.
+func (r *discordRPC) scheduleHeartbeat(username string, seconds int64) error {
+	cronExpr := fmt.Sprintf("@every %ds", seconds)
+	scheduleID, err := host.SchedulerScheduleRecurring(cronExpr, payloadHeartbeat, username)
 	if err != nil {
-		return fmt.Errorf("failed to connect to WebSocket: %w", err)
+		return fmt.Errorf("failed to schedule heartbeat: %w", err)
 	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Scheduled heartbeat for user %s every %ds with ID %s", username, seconds, scheduleID))
+	return nil
+}
 
-	// Send identify payload
+// handleHello stores the heartbeat interval from a HELLO frame, rescheduling the
+// heartbeat when it differs from the one in use.
+func (r *discordRPC) handleHello(username string, data map[string]any) error {
+	intervalMs, _ := data["heartbeat_interval"].(float64)
+	seconds := int64(intervalMs / 1000)
+	if seconds <= 0 {
+		return nil
+	}
+	current := r.heartbeatSeconds(username)
+	if err := host.CacheSetInt(heartbeatIntervalKey(username), seconds, connectionIDTTL); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to store heartbeat interval for user %s: %v", username, err))
+	}
+	if seconds == current {
+		return nil
+	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Discord asked user %s for a %ds heartbeat, rescheduling", username, seconds))
+	_ = host.SchedulerCancelSchedule(username)
+	return r.scheduleHeartbeat(username, seconds)
+}
+
+// identify sends the identify payload, starting a new gateway session.
+func (r *discordRPC) identify(username, token string) error {
 	payload := identifyPayload{
 		Token:   token,
 		Intents: 0,
@@ -394,29 +1454,31 @@ func (r *discordRPC) connect(username, token string) error {
 	if err := r.sendMessage(username, gateOpCode, payload); err != nil {
 		return fmt.Errorf("failed to send identify payload: %w", err)
 	}
-
-	// Schedule heartbeats for this user/connection
-	cronExpr := fmt.Sprintf("@every %ds", heartbeatInterval)
-	scheduleID, err := host.SchedulerScheduleRecurring(cronExpr, payloadHeartbeat, username)
-	if err != nil {
-		return fmt.Errorf("failed to schedule heartbeat: %w", err)
-	}
-	pdk.Log(pdk.LogInfo, fmt.Sprintf("Scheduled heartbeat for user %s with ID %s", username, scheduleID))
-
-	pdk.Log(pdk.LogInfo, fmt.Sprintf("Successfully authenticated user %s", username))
 	return nil
 }
 
-// disconnect closes the Discord connection for a user.
+// disconnect closes the Discord connection for a user. Every step is attempted even
+// if an earlier one fails, so a partial failure never leaves the user half-connected
+// and the next connect always starts from a clean state. Calling it again is harmless.
 func (r *discordRPC) disconnect(username string) error {
+	var errs []error
 	if err := host.SchedulerCancelSchedule(username); err != nil {
-		return fmt.Errorf("failed to cancel schedule: %w", err)
+		errs = append(errs, fmt.Errorf("failed to cancel schedule: %w", err))
 	}
 
-	if err := host.WebSocketCloseConnection(username, 1000, "Navidrome disconnect"); err != nil {
-		return fmt.Errorf("failed to close WebSocket connection: %w", err)
+	connID := r.connectionID(username)
+	if err := host.WebSocketCloseConnection(connID, 1000, "Navidrome disconnect"); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close WebSocket connection: %w", err))
 	}
-	return nil
+
+	removeSeq(connID)
+	if connID != username {
+		_ = host.CacheRemove(connectionIDKey(username))
+	}
+	_ = host.CacheRemove(connectedKey(username))
+	r.setConnState(username, connStateDead)
+	r.clearSession(username)
+	return errors.Join(errs...)
 }
 
 // handleWebSocketMessage processes incoming WebSocket messages from Discord.
@@ -434,24 +1496,137 @@ func (r *discordRPC) handleWebSocketMessage(connectionID, message string) error
 	}
 
 	// Store sequence number if present
+	var seq int64
 	if v := msg["s"]; v != nil {
-		seq := int64(v.(float64))
+		seq = int64(v.(float64))
 		pdk.Log(pdk.LogTrace, fmt.Sprintf("Received sequence number for connection '%s': %d", connectionID, seq))
-		if err := host.CacheSetInt(fmt.Sprintf("discord.seq.%s", connectionID), seq, int64(heartbeatInterval*2)); err != nil {
-			return fmt.Errorf("failed to store sequence number for user %s: %w", connectionID, err)
+		storeSeq(connectionID, seq)
+	}
+
+	op, _ := msg["op"].(float64)
+	switch {
+	case int(op) == heartbeatAckOpCode:
+		username := r.connectionUser(connectionID)
+		r.setHeartbeatAcked(username, true)
+		if r.connState(username) == connStateReady {
+			// Keeps the phase of long-lived connections from expiring
+			r.setConnState(username, connStateReady)
+		}
+	case int(op) == helloOpCode:
+		data, _ := msg["d"].(map[string]any)
+		return r.handleHello(r.connectionUser(connectionID), data)
+	case msg["t"] == "READY":
+		data, _ := msg["d"].(map[string]any)
+		username := r.connectionUser(connectionID)
+		r.handleReady(username, data, seq)
+		r.setConnState(username, connStateReady)
+		// A new session starts without a presence, e.g. after a reconnect or a reload
+		if err := r.resendLastPresence(username); err != nil {
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to restore presence for user %s: %v", username, err))
 		}
+	case msg["t"] == "RESUMED":
+		r.setConnState(r.connectionUser(connectionID), connStateReady)
+		r.updateSessionSeq(r.connectionUser(connectionID), seq)
+	case int(op) == reconnectOpCode:
+		return r.handleReconnectRequest(r.connectionUser(connectionID))
+	case int(op) == invalidOpCode:
+		return r.handleInvalidSession(r.connectionUser(connectionID))
+	case seq > 0:
+		r.updateSessionSeq(r.connectionUser(connectionID), seq)
 	}
 	return nil
 }
 
-// handleHeartbeatCallback processes heartbeat scheduler callbacks.
+// heartbeatAckKey returns the cache key recording whether the last heartbeat was acknowledged.
+func heartbeatAckKey(username string) string {
+	return fmt.Sprintf("discord.ack.%s", username)
+}
+
+// setHeartbeatAcked records whether Discord acknowledged the last scheduled heartbeat.
+func (r *discordRPC) setHeartbeatAcked(username string, acked bool) {
+	var value int64
+	if acked {
+		value = 1
+	}
+	_ = host.CacheSetInt(heartbeatAckKey(username), value, int64(heartbeatInterval*3))
+}
+
+// heartbeatAcked reports whether the last scheduled heartbeat was acknowledged.
+// An unknown state counts as acknowledged, so connections are only dropped on
+// evidence of a missed ACK.
+func (r *discordRPC) heartbeatAcked(username string) bool {
+	value, exists, err := host.CacheGetInt(heartbeatAckKey(username))
+	return err != nil || !exists || value != 0
+}
+
+// Bounds for the number of consecutive heartbeat failures tolerated before the
+// connection is dropped.
+const (
+	defaultHeartbeatFailureTolerance = 2
+	maxHeartbeatFailureTolerance     = 10
+)
+
+// heartbeatFailuresKey returns the cache key counting a user's consecutive heartbeat failures.
+func heartbeatFailuresKey(username string) string {
+	return fmt.Sprintf("discord.heartbeatfailures.%s", username)
+}
+
+// resolveHeartbeatFailureTolerance returns how many heartbeats in a row may fail to be
+// sent before the connection is cleaned up. A single failure, like a cache miss for
+// the sequence number, is usually transient and not worth a reconnect.
+func resolveHeartbeatFailureTolerance() int64 {
+	option, _ := pdk.GetConfig(heartbeatToleranceKey)
+	option = strings.TrimSpace(option)
+	if option == "" {
+		return defaultHeartbeatFailureTolerance
+	}
+	tolerance, err := strconv.Atoi(option)
+	if err != nil || tolerance < 1 {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid heartbeat failure tolerance %q, using %d", option, defaultHeartbeatFailureTolerance))
+		return defaultHeartbeatFailureTolerance
+	}
+	return int64(min(tolerance, maxHeartbeatFailureTolerance))
+}
+
+// recordHeartbeatFailure counts a heartbeat that couldn't be sent, returning the number
+// of consecutive failures for the user.
+func recordHeartbeatFailure(username string) int64 {
+	failures, _, _ := host.CacheGetInt(heartbeatFailuresKey(username))
+	failures++
+	_ = host.CacheSetInt(heartbeatFailuresKey(username), failures, int64(heartbeatInterval*(maxHeartbeatFailureTolerance+1)))
+	return failures
+}
+
+// handleHeartbeatCallback processes heartbeat scheduler callbacks. Discord treats
+// a connection that doesn't acknowledge heartbeats as dead, even while the socket
+// stays open, so a missing ACK for the previous heartbeat drops the connection.
+// Heartbeats that fail to be sent only drop it once the failure tolerance is reached.
 func (r *discordRPC) handleHeartbeatCallback(username string) error {
+	if !r.hasLiveConnection(username) {
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("No live connection for user %s, cancelling orphaned heartbeat schedule", username))
+		if err := host.SchedulerCancelSchedule(username); err != nil {
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to cancel heartbeat schedule for user %s: %v", username, err))
+		}
+		return nil
+	}
+	if !r.heartbeatAcked(username) {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("No heartbeat ACK from Discord for user %s, cleaning up zombied connection", username))
+		r.cleanupFailedConnection(username)
+		return errors.New("heartbeat not acknowledged, connection cleaned up")
+	}
+	r.setHeartbeatAcked(username, false)
 	if err := r.sendHeartbeat(username); err != nil {
-		// On first heartbeat failure, immediately clean up the connection
-		pdk.Log(pdk.LogWarn, fmt.Sprintf("Heartbeat failed for user %s, cleaning up connection: %v", username, err))
+		failures, tolerance := recordHeartbeatFailure(username), resolveHeartbeatFailureTolerance()
+		if failures < tolerance {
+			// Nothing was sent, so there is no ACK to wait for
+			r.setHeartbeatAcked(username, true)
+			pdk.Log(pdk.LogWarn, fmt.Sprintf("Heartbeat failed for user %s (%d of %d tolerated failures): %v", username, failures, tolerance, err))
+			return fmt.Errorf("heartbeat failed: %w", err)
+		}
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Heartbeat failed %d times in a row for user %s, cleaning up connection: %v", failures, username, err))
 		r.cleanupFailedConnection(username)
 		return fmt.Errorf("heartbeat failed, connection cleaned up: %w", err)
 	}
+	_ = host.CacheRemove(heartbeatFailuresKey(username))
 	return nil
 }
-
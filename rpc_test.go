@@ -3,10 +3,14 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
 	"github.com/navidrome/navidrome/plugins/pdk/go/websocket"
 	"github.com/stretchr/testify/mock"
 
@@ -28,6 +32,7 @@ var _ = Describe("discordRPC", func() {
 		host.SchedulerMock.Calls = nil
 		host.HTTPMock.ExpectedCalls = nil
 		host.HTTPMock.Calls = nil
+		registerCacheDefaults()
 	})
 
 	Describe("sendMessage", func() {
@@ -51,8 +56,42 @@ var _ = Describe("discordRPC", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("connection closed"))
 		})
+
+		It("logs the payload at trace level with credentials redacted", func() {
+			var traced []string
+			pdk.PDKMock.On("Log", pdk.LogTrace, mock.Anything).Run(func(args mock.Arguments) {
+				traced = append(traced, args.String(1))
+			})
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+			err := r.sendMessage("testuser", gateOpCode, identifyPayload{
+				Token:      "secret-token",
+				Intents:    0,
+				Properties: identifyProperties{OS: "Windows 10", Browser: "Discord Client", Device: "Discord Client"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(traced).To(HaveLen(1))
+			Expect(traced[0]).To(ContainSubstring(`"token":"REDACTED"`))
+			Expect(traced[0]).To(ContainSubstring(`"op":2`))
+			Expect(traced[0]).ToNot(ContainSubstring("secret-token"))
+			pdk.PDKMock.AssertNotCalled(GinkgoT(), "Log", pdk.LogDebug, mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"op":2`)
+			}))
+		})
 	})
 
+	DescribeTable("redactPayload",
+		func(payload, expected string) {
+			Expect(redactPayload([]byte(payload))).To(Equal(expected))
+		},
+		Entry("resume tokens", `{"d":{"seq":7,"session_id":"abc","token":"secret"},"op":6}`, `{"d":{"seq":7,"session_id":"abc","token":"REDACTED"},"op":6}`),
+		Entry("share links in image URLs", `{"d":{"large_image":"https://music.example.com/share/img/eyJhbGciOi.sig"},"op":3}`, `{"d":{"large_image":"https://music.example.com/share/img/REDACTED"},"op":3}`),
+		Entry("share links in external assets", `{"d":{"large_image":"mp:external/abc/https/music.example.com/share/img/eyJhbGciOi.sig"},"op":3}`, `{"d":{"large_image":"mp:external/abc/https/music.example.com/share/img/REDACTED"},"op":3}`),
+		Entry("text is left alone", `{"d":{"details":"Test Song","state":"Test Artist"},"op":3}`, `{"d":{"details":"Test Song","state":"Test Artist"},"op":3}`),
+		Entry("unparseable payloads", `not json`, `[unparseable payload]`),
+	)
+
 	Describe("sendHeartbeat", func() {
 		It("retrieves sequence number from cache and sends heartbeat", func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
@@ -77,10 +116,81 @@ var _ = Describe("discordRPC", func() {
 		})
 	})
 
+	Describe("getDiscordGateway", func() {
+		var slept []time.Duration
+
+		isGatewayLookup := mock.MatchedBy(func(req host.HTTPRequest) bool {
+			return req.URL == "https://discord.com/api/gateway"
+		})
+
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			slept = nil
+			original := sleep
+			sleep = func(d time.Duration) { slept = append(slept, d) }
+			DeferCleanup(func() { sleep = original })
+		})
+
+		It("waits out a short rate limit and asks again", func() {
+			host.CacheMock.On("SetInt", "discord.ratelimit.gateway", int64(0), int64(2)).Return(nil)
+			host.HTTPMock.On("Send", isGatewayLookup).Return(&host.HTTPResponse{
+				StatusCode: 429,
+				Headers:    map[string]string{"Retry-After": "1.5"},
+			}, nil).Once()
+			host.HTTPMock.On("Send", isGatewayLookup).Return(&host.HTTPResponse{
+				StatusCode: 200,
+				Body:       []byte(`{"url":"wss://gateway.discord.gg"}`),
+			}, nil).Once()
+
+			Expect(r.getDiscordGateway()).To(Equal("wss://gateway.discord.gg"))
+			Expect(slept).To(Equal([]time.Duration{2 * time.Second}))
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 2)
+		})
+
+		It("uses the default gateway when the rate limit is long", func() {
+			host.CacheMock.On("SetInt", "discord.ratelimit.gateway", int64(0), int64(30)).Return(nil)
+			host.HTTPMock.On("Send", isGatewayLookup).Return(&host.HTTPResponse{
+				StatusCode: 429,
+				Headers:    map[string]string{"Retry-After": "30"},
+			}, nil)
+
+			Expect(r.getDiscordGateway()).To(Equal(defaultGatewayURL))
+			Expect(slept).To(BeEmpty())
+		})
+
+		It("reuses the cached gateway without asking Discord", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", gatewayURLKey).Return("wss://gateway-us-east1-b.discord.gg", true, nil)
+			registerCacheDefaults()
+
+			Expect(r.getDiscordGateway()).To(Equal("wss://gateway-us-east1-b.discord.gg"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("skips the lookup while rate limited", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetInt", "discord.ratelimit.gateway").Return(int64(0), true, nil)
+			host.CacheMock.On("GetString", gatewayURLKey).Return("", false, nil)
+			registerCacheDefaults()
+
+			Expect(r.getDiscordGateway()).To(Equal(defaultGatewayURL))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("remembers the discovered gateway", func() {
+			host.HTTPMock.On("Send", isGatewayLookup).Return(&host.HTTPResponse{
+				StatusCode: 200,
+				Body:       []byte(`{"url":"wss://gateway.discord.gg"}`),
+			}, nil)
+
+			Expect(r.getDiscordGateway()).To(Equal("wss://gateway.discord.gg"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", gatewayURLKey, "wss://gateway.discord.gg", gatewayURLTTL)
+		})
+	})
+
 	Describe("connect", func() {
 		It("establishes WebSocket connection and sends identify payload", func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
-			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(0), false, errors.New("not found"))
 
 			// Mock HTTP GET request for gateway discovery
 			gatewayResp := []byte(`{"url":"wss://gateway.discord.gg"}`)
@@ -102,27 +212,493 @@ var _ = Describe("discordRPC", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
-		It("reuses existing connection if connected", func() {
+		It("pins the gateway version and encoding when the discovered URL has a query", func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
-			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(42), true, nil)
+			gatewayResp := []byte(`{"url":"wss://gateway.discord.gg/?compress=zlib-stream"}`)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: gatewayResp}, nil)
+			host.WebSocketMock.On("Connect", "wss://gateway.discord.gg/?v=10&encoding=json&compress=zlib-stream", mock.Anything, "testuser").
+				Return("testuser", nil)
 			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").
+				Return("testuser", nil)
 
 			err := r.connect("testuser", "test-token")
 			Expect(err).ToNot(HaveOccurred())
-			host.WebSocketMock.AssertNotCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, mock.Anything)
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+		})
+
+		It("routes messages to the connection ID assigned by the host", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("SetString", "discord.connid.testuser", "conn-42", int64(connectionIDTTL)).Return(nil)
+			host.CacheMock.On("SetString", "discord.connuser.conn-42", "testuser", int64(connectionIDTTL)).Return(nil)
+			host.CacheMock.On("GetString", "discord.connid.testuser").Return("conn-42", true, nil)
+			registerCacheDefaults()
+
+			gatewayResp := []byte(`{"url":"wss://gateway.discord.gg"}`)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: gatewayResp}, nil)
+			host.WebSocketMock.On("Connect", mock.Anything, mock.Anything, "testuser").Return("conn-42", nil)
+			host.WebSocketMock.On("SendText", "conn-42", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"op":2`)
+			})).Return(nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").
+				Return("testuser", nil)
+
+			err := r.connect("testuser", "test-token")
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertExpectations(GinkgoT())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", "testuser", mock.Anything)
+		})
+
+		DescribeTable("reuses a connection that is ready or still connecting",
+			func(state string) {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.conn.testuser").Return(state, true, nil)
+				registerCacheDefaults()
+
+				err := r.connect("testuser", "test-token")
+				Expect(err).ToNot(HaveOccurred())
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, mock.Anything)
+				// Checking the phase doesn't cost a heartbeat
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			},
+			Entry("ready", connStateReady),
+			Entry("connecting", connStateConnecting),
+		)
+
+		DescribeTable("opens a new connection when the previous one is dead or unknown",
+			func(state string, exists bool) {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.conn.testuser").Return(state, exists, nil)
+				host.CacheMock.On("SetString", "discord.conn.testuser", connStateConnecting, connectingTTL).Return(nil)
+				registerCacheDefaults()
+				host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"url":"wss://gateway.discord.gg"}`)}, nil)
+				host.WebSocketMock.On("Connect", mock.Anything, mock.Anything, "testuser").Return("testuser", nil)
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+				host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").Return("testuser", nil)
+
+				Expect(r.connect("testuser", "test-token")).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, "testuser")
+				host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.conn.testuser", connStateConnecting, connectingTTL)
+			},
+			Entry("dead", connStateDead, true),
+			Entry("absent", "", false),
+		)
+
+		It("marks the connection dead when connecting fails", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("SetString", "discord.conn.testuser", connStateDead, int64(connectionIDTTL)).Return(nil)
+			registerCacheDefaults()
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"url":"wss://gateway.discord.gg"}`)}, nil)
+			host.WebSocketMock.On("Connect", mock.Anything, mock.Anything, "testuser").Return("", errors.New("refused"))
+
+			Expect(r.connect("testuser", "test-token")).ToNot(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.conn.testuser", connStateDead, int64(connectionIDTTL))
+		})
+
+		It("discovers the gateway again after failing to connect to the cached one", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", gatewayURLKey).Return("wss://gateway-us-east1-b.discord.gg", true, nil).Once()
+			host.CacheMock.On("Remove", gatewayURLKey).Return(nil)
+			registerCacheDefaults()
+			host.WebSocketMock.On("Connect", mock.MatchedBy(func(url string) bool {
+				return strings.HasPrefix(url, "wss://gateway-us-east1-b.discord.gg")
+			}), mock.Anything, "testuser").Return("", errors.New("refused"))
+
+			Expect(r.connect("testuser", "test-token")).ToNot(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", gatewayURLKey)
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"url":"wss://gateway.discord.gg"}`)}, nil)
+			Expect(r.getDiscordGateway()).To(Equal("wss://gateway.discord.gg"))
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+		})
+	})
+
+	Describe("connection phase", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.On("SetInt", "discord.seq.testuser", mock.Anything, mock.Anything).Return(nil).Maybe()
+			host.CacheMock.On("SetString", "discord.session.testuser", mock.Anything, mock.Anything).Return(nil).Maybe()
+			host.CacheMock.On("Remove", "discord.reconnects.testuser").Return(nil).Maybe()
+		})
+
+		It("becomes ready on READY", func() {
+			Expect(r.handleWebSocketMessage("testuser", `{"op":0,"t":"READY","s":1,"d":{"session_id":"abc123"}}`)).To(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.conn.testuser", connStateReady, int64(connectionIDTTL))
+		})
+
+		It("restores the last presence on a new session", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.lastpresence.testuser").
+				Return(`{"v":1,"presence":{"activities":[{"name":"Navidrome","type":2,"details":"Test Song","application_id":"1234567890123456789","status_display_type":0,"timestamps":{"start":1714600000000},"assets":{}}],"since":0,"status":"dnd","afk":false}}`, true, nil)
+			host.CacheMock.On("SetInt", "discord.seq.testuser", mock.Anything, mock.Anything).Return(nil).Maybe()
+			host.CacheMock.On("SetString", "discord.session.testuser", mock.Anything, mock.Anything).Return(nil).Maybe()
+			host.CacheMock.On("Remove", "discord.reconnects.testuser").Return(nil).Maybe()
+			registerCacheDefaults()
+			var sent string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sent = args.String(1)
+			}).Return(nil)
+
+			Expect(r.handleWebSocketMessage("testuser", `{"op":0,"t":"READY","s":1,"d":{"session_id":"abc123"}}`)).To(Succeed())
+			Expect(sent).To(ContainSubstring(`"op":3`))
+			Expect(sent).To(ContainSubstring(`"details":"Test Song"`))
+		})
+
+		It("becomes ready on RESUMED", func() {
+			Expect(r.handleWebSocketMessage("testuser", `{"op":0,"t":"RESUMED","s":5,"d":null}`)).To(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.conn.testuser", connStateReady, int64(connectionIDTTL))
+		})
+
+		It("becomes dead when Discord closes the connection", func() {
+			host.SchedulerMock.On("ScheduleOneTime", mock.Anything, payloadReconnect, mock.Anything).Return("", nil).Maybe()
+			Expect(r.OnClose(websocket.OnCloseRequest{ConnectionID: "testuser", Code: 4000, Reason: "Unknown error"})).To(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.conn.testuser", connStateDead, int64(connectionIDTTL))
+		})
+
+		It("is left alone when the plugin closed the connection", func() {
+			Expect(r.OnClose(websocket.OnCloseRequest{ConnectionID: "testuser", Code: 1000, Reason: "Navidrome disconnect"})).To(Succeed())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.conn.testuser", mock.Anything, mock.Anything)
+		})
+
+		It("is left alone when a replaced connection closes", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.connuser.conn-1").Return("testuser", true, nil)
+			host.CacheMock.On("GetString", "discord.connid.testuser").Return("conn-2", true, nil)
+			registerCacheDefaults()
+
+			Expect(r.OnClose(websocket.OnCloseRequest{ConnectionID: "conn-1", Code: 1006, Reason: "Abnormal closure"})).To(Succeed())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.conn.testuser", mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("gateway session resume", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		It("stores the session from the READY dispatch", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("SetInt", "discord.seq.testuser", int64(1), mock.Anything).Return(nil)
+			host.CacheMock.On("SetString", "discord.session.testuser", mock.MatchedBy(func(value string) bool {
+				var session gatewaySession
+				_ = json.Unmarshal([]byte(value), &session)
+				return session == gatewaySession{SessionID: "abc123", ResumeURL: "wss://resume.discord.gg", Seq: 1}
+			}), sessionTTL).Return(nil)
+			host.CacheMock.On("Remove", "discord.reconnects.testuser").Return(nil)
+			registerCacheDefaults()
+
+			err := r.OnTextMessage(websocket.OnTextMessageRequest{
+				ConnectionID: "testuser",
+				Message:      `{"op":0,"t":"READY","s":1,"d":{"session_id":"abc123","resume_gateway_url":"wss://resume.discord.gg"}}`,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			// A new session resets the reconnect backoff
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		It("resumes a stored session instead of identifying", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.session.testuser").
+				Return(`{"session_id":"abc123","resume_gateway_url":"wss://resume.discord.gg","seq":7}`, true, nil)
+			registerCacheDefaults()
+			host.WebSocketMock.On("Connect", mock.MatchedBy(func(url string) bool {
+				return strings.HasPrefix(url, "wss://resume.discord.gg")
+			}), mock.Anything, "testuser").Return("testuser", nil)
+			var sent []string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sent = append(sent, args.Get(1).(string))
+			}).Return(nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").Return("testuser", nil)
+
+			Expect(r.connect("testuser", "test-token")).To(Succeed())
+
+			resume := sent[len(sent)-1]
+			Expect(resume).To(ContainSubstring(`"op":6`))
+			Expect(resume).To(ContainSubstring(`"session_id":"abc123"`))
+			Expect(resume).To(ContainSubstring(`"seq":7`))
+			for _, msg := range sent {
+				Expect(msg).ToNot(ContainSubstring(`"op":2`))
+			}
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("drops the session and identifies on a discovered gateway when the resume gateway fails", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.session.testuser").
+				Return(`{"session_id":"abc123","resume_gateway_url":"wss://resume.discord.gg","seq":7}`, true, nil)
+			host.CacheMock.On("Remove", "discord.session.testuser").Return(nil)
+			registerCacheDefaults()
+			host.WebSocketMock.On("Connect", mock.MatchedBy(func(url string) bool {
+				return strings.HasPrefix(url, "wss://resume.discord.gg")
+			}), mock.Anything, "testuser").Return("", errors.New("connection refused"))
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"url":"wss://gateway.discord.gg"}`)}, nil)
+			host.WebSocketMock.On("Connect", mock.MatchedBy(func(url string) bool {
+				return strings.HasPrefix(url, "wss://gateway.discord.gg")
+			}), mock.Anything, "testuser").Return("testuser", nil)
+			var sent []string
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				sent = append(sent, args.Get(1).(string))
+			}).Return(nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").Return("testuser", nil)
+
+			Expect(r.connect("testuser", "test-token")).To(Succeed())
+
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.session.testuser")
+			Expect(sent[len(sent)-1]).To(ContainSubstring(`"op":2`))
+			for _, msg := range sent {
+				Expect(msg).ToNot(ContainSubstring(`"op":6`))
+			}
+		})
+
+		It("schedules a fresh identify after a random delay when Discord invalidates the session", func() {
+			pinRand(2)
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("Remove", "discord.session.testuser").Return(nil)
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+			registerCacheDefaults()
+			var closed bool
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Connection lost").Run(func(mock.Arguments) {
+				closed = true
+			}).Return(nil)
+			host.SchedulerMock.On("ScheduleOneTime", int32(3), payloadReconnect, "reconnect.testuser").Run(func(mock.Arguments) {
+				Expect(closed).To(BeTrue(), "the connection is closed before the reconnect is scheduled")
+			}).Return("reconnect.testuser", nil)
+
+			err := r.OnTextMessage(websocket.OnTextMessageRequest{
+				ConnectionID: "testuser",
+				Message:      `{"op":9,"d":false}`,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertExpectations(GinkgoT())
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.conn.testuser", connStateDead, mock.Anything)
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+		})
+
+		It("keeps the invalid session delay within Discord's 1-5 seconds", func() {
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Connection lost").Return(nil)
+			for _, v := range []int{0, 4} {
+				pinRand(v)
+				host.SchedulerMock.ExpectedCalls = nil
+				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+				host.SchedulerMock.On("ScheduleOneTime", mock.Anything, payloadReconnect, "reconnect.testuser").Return("reconnect.testuser", nil)
+
+				Expect(r.handleInvalidSession("testuser")).To(Succeed())
+				delay := host.SchedulerMock.Calls[len(host.SchedulerMock.Calls)-1].Arguments.Get(0).(int32)
+				Expect(delay).To(BeNumerically(">=", 1))
+				Expect(delay).To(BeNumerically("<=", 5))
+			}
+		})
+
+		It("identifies on a new connection when the scheduled reconnect runs", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Connection lost").Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"url":"wss://gateway.discord.gg"}`)}, nil)
+			host.WebSocketMock.On("Connect", mock.Anything, mock.Anything, "testuser").Return("testuser", nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"op":2`) && strings.Contains(msg, "test-token")
+			})).Return(nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").Return("testuser", nil)
+
+			Expect(r.handleReconnectCallback("reconnect.testuser")).To(Succeed())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+		})
+
+		It("tears down and resumes when Discord requests a reconnect", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"test-token"}]`, true)
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.session.testuser").
+				Return(`{"session_id":"abc123","resume_gateway_url":"wss://resume.discord.gg","seq":7}`, true, nil)
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+			registerCacheDefaults()
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Connection lost").Return(nil)
+			host.WebSocketMock.On("Connect", mock.MatchedBy(func(url string) bool {
+				return strings.HasPrefix(url, "wss://resume.discord.gg")
+			}), mock.Anything, "testuser").Return("testuser", nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"op":6`) && strings.Contains(msg, `"session_id":"abc123"`)
+			})).Return(nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").Return("testuser", nil)
+
+			err := r.OnTextMessage(websocket.OnTextMessageRequest{
+				ConnectionID: "testuser",
+				Message:      `{"op":7,"d":null}`,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "Remove", "discord.session.testuser")
+		})
+
+		It("forgets the session on an explicit disconnect", func() {
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+
+			Expect(r.disconnect("testuser")).To(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.session.testuser")
 		})
 	})
 
+	Describe("HELLO heartbeat interval", func() {
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		It("reschedules the heartbeat with the interval from HELLO", func() {
+			host.CacheMock.On("SetInt", "discord.heartbeat.testuser", int64(30), int64(connectionIDTTL)).Return(nil)
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 30s", payloadHeartbeat, "testuser").Return("testuser", nil)
+
+			err := r.OnTextMessage(websocket.OnTextMessageRequest{
+				ConnectionID: "testuser",
+				Message:      `{"op":10,"s":null,"d":{"heartbeat_interval":30000}}`,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertExpectations(GinkgoT())
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+		})
+
+		It("keeps the schedule when the interval is unchanged", func() {
+			host.CacheMock.On("SetInt", "discord.heartbeat.testuser", int64(41), int64(connectionIDTTL)).Return(nil)
+
+			err := r.OnTextMessage(websocket.OnTextMessageRequest{
+				ConnectionID: "testuser",
+				Message:      `{"op":10,"s":null,"d":{"heartbeat_interval":41250}}`,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleRecurring", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("schedules new connections with the stored interval", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetInt", "discord.heartbeat.testuser").Return(int64(30), true, nil)
+			registerCacheDefaults()
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"url":"wss://gateway.discord.gg"}`)}, nil)
+			host.WebSocketMock.On("Connect", mock.Anything, mock.Anything, "testuser").Return("testuser", nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 30s", payloadHeartbeat, "testuser").Return("testuser", nil)
+
+			Expect(r.connect("testuser", "test-token")).To(Succeed())
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+		})
+	})
+
+	Describe("gatewayConnectURL", func() {
+		DescribeTable("sets the connection parameters on the discovered URL",
+			func(gateway, expected string) {
+				result, err := gatewayConnectURL(gateway)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(expected))
+			},
+			Entry("bare host", "wss://gateway.discord.gg", "wss://gateway.discord.gg?v=10&encoding=json"),
+			Entry("root path", "wss://gateway.discord.gg/", "wss://gateway.discord.gg/?v=10&encoding=json"),
+			Entry("trailing path", "wss://gateway-us-east1-b.discord.gg/gateway/", "wss://gateway-us-east1-b.discord.gg/gateway/?v=10&encoding=json"),
+			Entry("existing query", "wss://gateway.discord.gg/?compress=zlib-stream", "wss://gateway.discord.gg/?v=10&encoding=json&compress=zlib-stream"),
+			Entry("existing encoding is replaced", "wss://gateway.discord.gg/?encoding=etf&x=1", "wss://gateway.discord.gg/?v=10&encoding=json&x=1"),
+			Entry("existing version is replaced", "wss://gateway.discord.gg/?v=6", "wss://gateway.discord.gg/?v=10&encoding=json"),
+		)
+
+		DescribeTable("rejects invalid gateway URLs",
+			func(gateway string) {
+				_, err := gatewayConnectURL(gateway)
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("empty", ""),
+			Entry("no host", "wss:///gateway"),
+			Entry("non-WebSocket scheme", "https://gateway.discord.gg"),
+		)
+	})
+
 	Describe("disconnect", func() {
 		It("cancels schedule and closes WebSocket connection", func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
 			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
 			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
 
 			err := r.disconnect("testuser")
 			Expect(err).ToNot(HaveOccurred())
 			host.SchedulerMock.AssertExpectations(GinkgoT())
 			host.WebSocketMock.AssertExpectations(GinkgoT())
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		It("reconciles all state even when closing the connection fails", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.connid.testuser").Return("conn-42", true, nil).Once()
+			host.CacheMock.On("Remove", "discord.seq.conn-42").Return(nil)
+			host.CacheMock.On("Remove", "discord.connid.testuser").Return(nil)
+			registerCacheDefaults()
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+			host.WebSocketMock.On("CloseConnection", "conn-42", int32(1000), "Navidrome disconnect").
+				Return(errors.New("connection already closed"))
+
+			err := r.disconnect("testuser")
+			Expect(err).To(MatchError(ContainSubstring("failed to close WebSocket connection")))
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+			host.CacheMock.AssertExpectations(GinkgoT())
+
+			// The next connect starts from scratch instead of reusing the stale connection
+			host.CacheMock.On("GetString", "discord.connid.testuser").Return("", false, nil)
+			gatewayResp := []byte(`{"url":"wss://gateway.discord.gg"}`)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: gatewayResp}, nil)
+			host.WebSocketMock.On("Connect", mock.Anything, mock.Anything, "testuser").Return("testuser", nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"op":2`)
+			})).Return(nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").
+				Return("testuser", nil)
+
+			Expect(r.connect("testuser", "test-token")).To(Succeed())
+			host.WebSocketMock.AssertCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, "testuser")
+		})
+
+		It("still closes the connection when cancelling the schedule fails", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(errors.New("schedule not found"))
+			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Navidrome disconnect").Return(nil)
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+
+			err := r.disconnect("testuser")
+			Expect(err).To(MatchError(ContainSubstring("failed to cancel schedule")))
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+	})
+
+	Describe("disconnect with a host-assigned connection ID", func() {
+		It("closes the mapped connection and forgets the mapping", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.connid.testuser").Return("conn-42", true, nil)
+			host.CacheMock.On("Remove", "discord.connid.testuser").Return(nil)
+			host.CacheMock.On("Remove", "discord.seq.conn-42").Return(nil)
+			registerCacheDefaults()
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+			host.WebSocketMock.On("CloseConnection", "conn-42", int32(1000), "Navidrome disconnect").Return(nil)
+
+			err := r.disconnect("testuser")
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertExpectations(GinkgoT())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
 		})
 	})
 
@@ -140,6 +716,182 @@ var _ = Describe("discordRPC", func() {
 		})
 	})
 
+	Describe("reconnect", func() {
+		var identified []string
+
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Connection lost").Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`{"url":"wss://gateway.discord.gg"}`)}, nil)
+			host.WebSocketMock.On("Connect", mock.Anything, mock.Anything, "testuser").Return("testuser", nil)
+			host.SchedulerMock.On("ScheduleRecurring", "@every 41s", payloadHeartbeat, "testuser").Return("testuser", nil)
+			identified = nil
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+				if msg := args.String(1); strings.Contains(msg, `"op":2`) {
+					identified = append(identified, msg)
+				}
+			}).Return(nil)
+		})
+
+		It("caches only the fingerprint of the token it connected with", func() {
+			Expect(r.connect("testuser", "test-token")).To(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.token.testuser", tokenFingerprint("test-token"), connTokenTTL)
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.token.testuser", "test-token", mock.Anything)
+		})
+
+		It("reconnects with the token from the config", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"changed-token"}]`, true)
+			Expect(r.connect("testuser", "old-token")).To(Succeed())
+			r.cleanupFailedConnection("testuser")
+
+			Expect(r.reconnect("testuser")).To(Succeed())
+			Expect(identified).To(HaveLen(2))
+			Expect(identified[1]).To(ContainSubstring("changed-token"))
+		})
+
+		It("blames a rejection on the token the connection was made with, not a rotated one", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+			host.CacheMock.On("GetString", "discord.token.testuser").Return(tokenFingerprint("old-token"), true, nil)
+			host.CacheMock.On("SetString", "discord.authfailed.testuser", tokenFingerprint("old-token"), authFailedTTL).Return(nil)
+			registerCacheDefaults()
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"new-token"}]`, true)
+			Expect(r.connect("testuser", "old-token")).To(Succeed())
+
+			Expect(r.OnClose(websocket.OnCloseRequest{ConnectionID: "testuser", Code: closeCodeAuthenticationFailed, Reason: "Authentication failed."})).To(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.authfailed.testuser", tokenFingerprint("old-token"), authFailedTTL)
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.authfailed.testuser", tokenFingerprint("new-token"), mock.Anything)
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.token.testuser")
+
+			Expect(r.reconnect("testuser")).To(Succeed())
+			Expect(identified[len(identified)-1]).To(ContainSubstring("new-token"))
+		})
+
+		It("doesn't disable the user when it can't tell which token was rejected", func() {
+			Expect(r.OnClose(websocket.OnCloseRequest{ConnectionID: "testuser", Code: closeCodeAuthenticationFailed, Reason: "Authentication failed."})).To(Succeed())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.authfailed.testuser", mock.Anything, mock.Anything)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.lasterror.testuser", mock.Anything, lastErrorTTL)
+		})
+
+		It("stops reconnecting once Discord rejected the token", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.authfailed.testuser").Return(tokenFingerprint("rejected-token"), true, nil)
+			registerCacheDefaults()
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"rejected-token"}]`, true)
+			host.SchedulerMock.On("CancelSchedule", "reconnect.testuser").Return(nil)
+
+			Expect(r.reconnect("testuser")).To(Succeed())
+			Expect(identified).To(BeEmpty())
+			host.SchedulerMock.AssertCalled(GinkgoT(), "CancelSchedule", "reconnect.testuser")
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.reconnects.testuser")
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "Connect", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("doesn't reconnect users removed from the config", func() {
+			pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true)
+			pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"otheruser","token":"token"}]`, true)
+			Expect(r.connect("testuser", "test-token")).To(Succeed())
+
+			Expect(r.reconnect("testuser")).To(MatchError(ContainSubstring("not authorized")))
+			Expect(identified).To(HaveLen(1))
+		})
+	})
+
+	Describe("cache unavailable", func() {
+		var warnings, infos []string
+
+		BeforeEach(func() {
+			warnings, infos = nil, nil
+			pdk.PDKMock.On("Log", pdk.LogWarn, mock.Anything).Run(func(args mock.Arguments) {
+				warnings = append(warnings, args.String(1))
+			}).Maybe()
+			pdk.PDKMock.On("Log", pdk.LogInfo, mock.Anything).Run(func(args mock.Arguments) {
+				infos = append(infos, args.String(1))
+			}).Maybe()
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", heartbeatToleranceKey).Return("", false).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("SetInt", "discord.seq.testuser", mock.Anything, mock.Anything).Return(errors.New("cache down"))
+			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(0), false, errors.New("cache down"))
+			registerCacheDefaults()
+		})
+
+		It("keeps heartbeats going with the sequence number kept in memory", func() {
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+			Expect(r.handleWebSocketMessage("testuser", `{"op":0,"s":7,"t":"MESSAGE_CREATE"}`)).To(Succeed())
+
+			Expect(r.handleHeartbeatCallback("testuser")).To(Succeed())
+			host.WebSocketMock.AssertCalled(GinkgoT(), "SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"op":1`) && strings.Contains(msg, `"d":7`)
+			}))
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "CloseConnection", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("skips heartbeats without any sequence number, keeping the connection", func() {
+			err := r.handleHeartbeatCallback("testuser")
+			Expect(err).To(MatchError(ContainSubstring("cache down")))
+			Expect(err.Error()).ToNot(ContainSubstring("connection cleaned up"))
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "CloseConnection", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("prefers the cache once it works again", func() {
+			Expect(r.handleWebSocketMessage("testuser", `{"op":0,"s":7,"t":"MESSAGE_CREATE"}`)).To(Succeed())
+
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(9), true, nil)
+			registerCacheDefaults()
+			Expect(loadSeq("testuser")).To(Equal(int64(9)))
+		})
+
+		It("doesn't repeat Spotify lookups while the cache is unavailable", func() {
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, errors.New("cache down"))
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(errors.New("cache down"))
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["63OQupATfueTdZMWIV7nzz"]}]`)}, nil)
+
+			track := scrobbler.TrackInfo{Title: "Karma Police", Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}}, MBZRecordingID: "mbid-123"}
+			Expect(resolveSpotifyURL(track)).To(Equal("https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"))
+			Expect(resolveSpotifyURL(track)).To(Equal("https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"))
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+		})
+
+		It("warns once while the cache keeps failing", func() {
+			for seq := int64(1); seq <= 5; seq++ {
+				Expect(r.handleWebSocketMessage("testuser", `{"op":0,"s":`+strconv.FormatInt(seq, 10)+`,"t":"MESSAGE_CREATE"}`)).To(Succeed())
+			}
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0]).To(ContainSubstring("Cache is unavailable"))
+		})
+
+		It("counts failed reads as well as failed writes", func() {
+			Expect(r.handleWebSocketMessage("testuser", `{"op":0,"s":7,"t":"MESSAGE_CREATE"}`)).To(Succeed())
+			for range cacheFailureThreshold - 1 {
+				_, err := loadSeq("testuser")
+				Expect(err).To(HaveOccurred())
+			}
+			Expect(warnings).To(ConsistOf(ContainSubstring("Cache is unavailable")))
+		})
+
+		It("logs the recovery once a sequence number is read again", func() {
+			for seq := int64(1); seq <= cacheFailureThreshold; seq++ {
+				Expect(r.handleWebSocketMessage("testuser", `{"op":0,"s":`+strconv.FormatInt(seq, 10)+`,"t":"MESSAGE_CREATE"}`)).To(Succeed())
+			}
+
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(8), true, nil)
+			registerCacheDefaults()
+			Expect(loadSeq("testuser")).To(Equal(int64(8)))
+			Expect(infos).To(ContainElement("Cache is available again"))
+			Expect(cacheHealth.degraded).To(BeFalse())
+		})
+	})
+
 	Describe("handleHeartbeatCallback", func() {
 		It("sends heartbeat successfully", func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
@@ -150,16 +902,130 @@ var _ = Describe("discordRPC", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
-		It("cleans up connection on heartbeat failure", func() {
+		Context("when a heartbeat can't be sent", func() {
+			BeforeEach(func() {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				pdk.PDKMock.On("GetConfig", heartbeatToleranceKey).Return("", false).Maybe()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(0), false, errors.New("cache miss"))
+				host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil).Maybe()
+				host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil).Maybe()
+				host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Connection lost").Return(nil).Maybe()
+			})
+
+			It("keeps the connection after a single failure", func() {
+				host.CacheMock.On("SetInt", "discord.heartbeatfailures.testuser", int64(1), mock.Anything).Return(nil)
+				host.CacheMock.On("SetInt", "discord.ack.testuser", int64(1), int64(heartbeatInterval*3)).Return(nil)
+				registerCacheDefaults()
+
+				err := r.handleHeartbeatCallback("testuser")
+				Expect(err).To(MatchError(ContainSubstring("heartbeat failed")))
+				Expect(err.Error()).ToNot(ContainSubstring("connection cleaned up"))
+				host.CacheMock.AssertExpectations(GinkgoT())
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "CloseConnection", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			It("cleans up the connection once the tolerance is reached", func() {
+				host.CacheMock.On("GetInt", "discord.heartbeatfailures.testuser").Return(int64(1), true, nil)
+				host.CacheMock.On("SetInt", "discord.heartbeatfailures.testuser", int64(2), mock.Anything).Return(nil)
+				registerCacheDefaults()
+
+				err := r.handleHeartbeatCallback("testuser")
+				Expect(err).To(MatchError(ContainSubstring("connection cleaned up")))
+				host.WebSocketMock.AssertCalled(GinkgoT(), "CloseConnection", "testuser", int32(1000), "Connection lost")
+				host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.heartbeatfailures.testuser")
+			})
+		})
+
+		It("resets the failure count once a heartbeat is sent", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(42), true, nil)
+			host.CacheMock.On("Remove", "discord.heartbeatfailures.testuser").Return(nil)
+			registerCacheDefaults()
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+			Expect(r.handleHeartbeatCallback("testuser")).To(Succeed())
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		DescribeTable("resolveHeartbeatFailureTolerance",
+			func(option string, expected int64) {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				pdk.PDKMock.On("GetConfig", heartbeatToleranceKey).Return(option, option != "")
+				Expect(resolveHeartbeatFailureTolerance()).To(Equal(expected))
+			},
+			Entry("defaults to 2", "", int64(defaultHeartbeatFailureTolerance)),
+			Entry("1 cleans up on the first failure", "1", int64(1)),
+			Entry("capped", "50", int64(maxHeartbeatFailureTolerance)),
+			Entry("invalid values", "0", int64(defaultHeartbeatFailureTolerance)),
+		)
+
+		It("sends the next heartbeat once the previous one was acknowledged", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetInt", "discord.ack.testuser").Return(int64(1), true, nil)
+			host.CacheMock.On("SetInt", "discord.ack.testuser", int64(0), int64(heartbeatInterval*3)).Return(nil)
+			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(42), true, nil)
+			registerCacheDefaults()
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+			Expect(r.handleHeartbeatCallback("testuser")).To(Succeed())
+			host.CacheMock.AssertExpectations(GinkgoT())
+			host.SchedulerMock.AssertNotCalled(GinkgoT(), "CancelSchedule", mock.Anything)
+		})
+
+		It("records the ACK from Discord", func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
-			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(0), false, errors.New("cache miss"))
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("SetInt", "discord.ack.testuser", int64(1), int64(heartbeatInterval*3)).Return(nil)
+			registerCacheDefaults()
+
+			err := r.OnTextMessage(websocket.OnTextMessageRequest{ConnectionID: "testuser", Message: `{"op":11}`})
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		It("cleans up a zombied connection that missed the ACK", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetInt", "discord.ack.testuser").Return(int64(0), true, nil)
+			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
+			registerCacheDefaults()
 			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
 			host.WebSocketMock.On("CloseConnection", "testuser", int32(1000), "Connection lost").Return(nil)
-			host.CacheMock.On("Remove", "discord.seq.testuser").Return(nil)
 
 			err := r.handleHeartbeatCallback("testuser")
-			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("connection cleaned up"))
+			Expect(err).To(MatchError(ContainSubstring("not acknowledged")))
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+		})
+
+		It("cancels an orphaned schedule without a live connection", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetInt", "discord.connected.testuser").Return(int64(0), false, nil)
+			registerCacheDefaults()
+			host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+
+			Expect(r.handleHeartbeatCallback("testuser")).To(Succeed())
+			host.SchedulerMock.AssertExpectations(GinkgoT())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "GetInt", "discord.seq.testuser")
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			host.WebSocketMock.AssertNotCalled(GinkgoT(), "CloseConnection", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("keeps the connection registered while heartbeats succeed", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetInt", "discord.seq.testuser").Return(int64(42), true, nil)
+			host.CacheMock.On("SetInt", "discord.connected.testuser", mock.Anything, int64(connectionIDTTL)).Return(nil)
+			registerCacheDefaults()
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+			Expect(r.handleHeartbeatCallback("testuser")).To(Succeed())
+			host.CacheMock.AssertExpectations(GinkgoT())
 		})
 	})
 
@@ -206,6 +1072,44 @@ var _ = Describe("discordRPC", func() {
 				})
 				Expect(err).ToNot(HaveOccurred())
 			})
+
+			It("leaves a dead connection alone by default", func() {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				pdk.PDKMock.On("GetConfig", reconnectOnErrorKey).Return("", false)
+
+				Expect(r.OnError(websocket.OnErrorRequest{ConnectionID: "testuser", Error: "read: connection reset by peer"})).To(Succeed())
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "CloseConnection", mock.Anything, mock.Anything, mock.Anything)
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			Context("with reconnect on error enabled", func() {
+				BeforeEach(func() {
+					pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+					pdk.PDKMock.On("GetConfig", reconnectOnErrorKey).Return("true", true)
+				})
+
+				It("cleans up and schedules a reconnect for a dead connection", func() {
+					host.CacheMock.ExpectedCalls = nil
+					host.CacheMock.On("GetString", "discord.connuser.conn-42").Return("testuser", true, nil)
+					host.CacheMock.On("GetString", "discord.connid.testuser").Return("conn-42", true, nil)
+					host.CacheMock.On("Remove", "discord.seq.conn-42").Return(nil)
+					host.CacheMock.On("Remove", "discord.connid.testuser").Return(nil)
+					registerCacheDefaults()
+					host.SchedulerMock.On("CancelSchedule", "testuser").Return(nil)
+					host.WebSocketMock.On("CloseConnection", "conn-42", int32(1000), "Connection lost").Return(nil)
+					host.SchedulerMock.On("ScheduleOneTime", int32(reconnectBaseDelay), payloadReconnect, "reconnect.testuser").Return("reconnect.testuser", nil)
+
+					Expect(r.OnError(websocket.OnErrorRequest{ConnectionID: "conn-42", Error: "write: broken pipe"})).To(Succeed())
+					host.WebSocketMock.AssertExpectations(GinkgoT())
+					host.SchedulerMock.AssertExpectations(GinkgoT())
+				})
+
+				It("ignores errors that leave the connection usable", func() {
+					Expect(r.OnError(websocket.OnErrorRequest{ConnectionID: "testuser", Error: "invalid UTF-8 in text frame"})).To(Succeed())
+					host.WebSocketMock.AssertNotCalled(GinkgoT(), "CloseConnection", mock.Anything, mock.Anything, mock.Anything)
+					host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+				})
+			})
 		})
 
 		Describe("OnClose", func() {
@@ -218,12 +1122,127 @@ var _ = Describe("discordRPC", func() {
 				})
 				Expect(err).ToNot(HaveOccurred())
 			})
+
+			It("remembers the rejected token on authentication failure", func() {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.connuser.conn-42").Return("testuser", true, nil)
+				host.CacheMock.On("GetString", "discord.token.testuser").Return(tokenFingerprint("bad-token"), true, nil)
+				host.CacheMock.On("SetString", "discord.authfailed.testuser", tokenFingerprint("bad-token"), authFailedTTL).Return(nil)
+				registerCacheDefaults()
+
+				err := r.OnClose(websocket.OnCloseRequest{
+					ConnectionID: "conn-42",
+					Code:         closeCodeAuthenticationFailed,
+					Reason:       "Authentication failed.",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				host.CacheMock.AssertExpectations(GinkgoT())
+				host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.lasterror.testuser", mock.Anything, lastErrorTTL)
+			})
+
+			It("disables the user on other fatal close codes", func() {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", "discord.token.testuser").Return(tokenFingerprint("test-token"), true, nil)
+				host.CacheMock.On("SetString", "discord.authfailed.testuser", tokenFingerprint("test-token"), authFailedTTL).Return(nil)
+				host.CacheMock.On("SetString", "discord.lasterror.testuser", mock.MatchedBy(func(v string) bool {
+					return strings.Contains(v, "disallowed intents (4014)")
+				}), lastErrorTTL).Return(nil)
+				registerCacheDefaults()
+
+				err := r.OnClose(websocket.OnCloseRequest{ConnectionID: "testuser", Code: 4014, Reason: "Disallowed intent(s)."})
+				Expect(err).ToNot(HaveOccurred())
+				host.CacheMock.AssertExpectations(GinkgoT())
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			It("schedules a reconnect with backoff on recoverable close codes", func() {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", "discord.reconnects.testuser").Return(int64(2), true, nil)
+				host.CacheMock.On("SetInt", "discord.reconnects.testuser", int64(3), reconnectAttemptsTTL).Return(nil)
+				registerCacheDefaults()
+				host.SchedulerMock.On("ScheduleOneTime", int32(20), payloadReconnect, "reconnect.testuser").Return("reconnect.testuser", nil)
+
+				err := r.OnClose(websocket.OnCloseRequest{ConnectionID: "testuser", Code: 4000, Reason: "Unknown error."})
+				Expect(err).ToNot(HaveOccurred())
+				host.CacheMock.AssertExpectations(GinkgoT())
+				host.SchedulerMock.AssertExpectations(GinkgoT())
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.authfailed.testuser", mock.Anything, mock.Anything)
+			})
+
+			It("does not reconnect after a normal close", func() {
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+
+				err := r.OnClose(websocket.OnCloseRequest{ConnectionID: "testuser", Code: 1000, Reason: "Navidrome disconnect"})
+				Expect(err).ToNot(HaveOccurred())
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+	})
+
+	DescribeTable("isFatalCloseCode",
+		func(code int, fatal, recoverable bool) {
+			Expect(isFatalCloseCode(code)).To(Equal(fatal))
+			Expect(isRecoverableCloseCode(code)).To(Equal(recoverable))
+		},
+		Entry("normal closure", 1000, false, false),
+		Entry("abnormal closure", 1006, false, false),
+		Entry("unknown error", 4000, false, true),
+		Entry("session timed out", 4009, false, true),
+		Entry("authentication failed", 4004, true, false),
+		Entry("invalid shard", 4010, true, false),
+		Entry("sharding required", 4011, true, false),
+		Entry("invalid API version", 4012, true, false),
+		Entry("invalid intents", 4013, true, false),
+		Entry("disallowed intents", 4014, true, false),
+	)
+
+	DescribeTable("reconnectBackoff",
+		func(attempt, expected int64) {
+			Expect(reconnectBackoff(attempt)).To(Equal(expected))
+		},
+		Entry("first attempt", int64(0), int64(5)),
+		Entry("second attempt", int64(1), int64(10)),
+		Entry("fourth attempt", int64(3), int64(40)),
+		Entry("capped", int64(10), int64(reconnectMaxDelay)),
+		Entry("capped for large counts", int64(1000), int64(reconnectMaxDelay)),
+	)
+
+	Describe("isAuthFailed", func() {
+		BeforeEach(func() {
+			host.CacheMock.ExpectedCalls = nil
+		})
+
+		It("reports the rejected token", func() {
+			host.CacheMock.On("GetString", "discord.authfailed.testuser").Return(tokenFingerprint("bad-token"), true, nil)
+			Expect(r.isAuthFailed("testuser", "bad-token")).To(BeTrue())
+		})
+
+		It("clears the sentinel once the token changes", func() {
+			host.CacheMock.On("GetString", "discord.authfailed.testuser").Return(tokenFingerprint("bad-token"), true, nil)
+			host.CacheMock.On("Remove", "discord.authfailed.testuser").Return(nil)
+			Expect(r.isAuthFailed("testuser", "new-token")).To(BeFalse())
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		It("is false when no token was rejected", func() {
+			host.CacheMock.On("GetString", "discord.authfailed.testuser").Return("", false, nil)
+			Expect(r.isAuthFailed("testuser", "token")).To(BeFalse())
+		})
+
+		It("never stores the token itself", func() {
+			Expect(tokenFingerprint("secret-token")).ToNot(ContainSubstring("secret"))
+			Expect(tokenFingerprint("secret-token")).To(HaveLen(16))
 		})
 	})
 
 	Describe("processImage", func() {
 		BeforeEach(func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", assetFreshnessKey).Return("", false).Maybe()
+			host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil).Maybe()
 		})
 
 		It("returns error for empty URL", func() {
@@ -248,17 +1267,150 @@ var _ = Describe("discordRPC", func() {
 			Expect(result).To(Equal("mp:cached/image"))
 		})
 
+		It("redacts artwork tokens from log messages", func() {
+			tokenized := "https://music.example.com/share/img/eyJhbGciOiJIUzI1NiJ9.eyJpZCI6ImFsLTEifQ.c2ln?size=300"
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", externalAssetsReq).
+				Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"}]`)}, nil)
+
+			_, err := r.processImage(tokenized, "client123", "token123", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			for _, call := range pdk.PDKMock.Calls {
+				if call.Method == "Log" {
+					Expect(call.Arguments.String(1)).ToNot(ContainSubstring("eyJ"))
+				}
+			}
+		})
+
 		It("processes image via Discord API and caches result", func() {
 			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
 			host.CacheMock.On("SetString", discordImageKey, mock.MatchedBy(func(val string) bool {
 				return val == "mp:external/new-asset"
 			}), int64(imageCacheTTL)).Return(nil)
 
-			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/new-asset"}]`)}, nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/new-asset"}]`)}, nil)
+
+			result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal("mp:external/new-asset"))
+		})
+
+		Context("uploads in progress", func() {
+			uploadKey := "discord.imageupload." + hashKey("https://example.com/art.jpg")
+
+			BeforeEach(func() {
+				pinRand(42)
+			})
+
+			It("marks the upload while it runs and removes its own marker", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("GetString", uploadKey).Return("", false, nil).Once()
+				host.CacheMock.On("GetString", uploadKey).Return("42", true, nil)
+				host.CacheMock.On("SetString", uploadKey, "42", imageUploadTTL).Return(nil).Once()
+				host.CacheMock.On("Remove", uploadKey).Return(nil).Once()
+				host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+				host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil)
+				registerCacheDefaults()
+				host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"}]`)}, nil)
+
+				_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+				Expect(err).ToNot(HaveOccurred())
+				host.CacheMock.AssertExpectations(GinkgoT())
+			})
+
+			It("reuses the asset of an upload in progress", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil).Once()
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil).Once()
+				host.CacheMock.On("GetString", discordImageKey).Return("mp:external/shared", true, nil)
+				host.CacheMock.On("GetString", uploadKey).Return("7", true, nil)
+				registerCacheDefaults()
+
+				result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal("mp:external/shared"))
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", uploadKey, mock.Anything, mock.Anything)
+			})
+
+			It("defers the image when the upload in progress caches nothing in time", func() {
+				var slept []time.Duration
+				sleep = func(d time.Duration) { slept = append(slept, d) }
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("GetString", uploadKey).Return("7", true, nil)
+				registerCacheDefaults()
+
+				_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+				Expect(err).To(MatchError(errImageDeferred))
+				Expect(slept).To(HaveLen(imageUploadPolls))
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+				host.CacheMock.AssertNotCalled(GinkgoT(), "Remove", uploadKey)
+			})
+
+			It("stops waiting once the upload in progress gave up", func() {
+				var slept []time.Duration
+				sleep = func(d time.Duration) { slept = append(slept, d) }
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("GetString", uploadKey).Return("7", true, nil).Once()
+				host.CacheMock.On("GetString", uploadKey).Return("", false, nil)
+				registerCacheDefaults()
+
+				_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+				Expect(err).To(MatchError(errImageDeferred))
+				Expect(slept).To(HaveLen(1))
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+			})
+
+			It("doesn't mistake the stale asset being replaced for the new one", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", discordImageKey).Return("mp:external/stale", true, nil)
+				host.CacheMock.On("GetString", uploadKey).Return("7", true, nil)
+				registerCacheDefaults()
+				pdk.PDKMock.ExpectedCalls = nil
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				pdk.PDKMock.On("GetConfig", assetFreshnessKey).Return("12", true)
+				host.CacheMock.On("GetInt", assetUploadedKey(hashKey("https://example.com/art.jpg"))).Return(int64(0), false, nil)
+				host.CacheMock.On("Remove", mock.Anything).Return(nil)
+				host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool { return req.Method == "HEAD" })).
+					Return(&host.HTTPResponse{StatusCode: 404}, nil)
+
+				_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+				Expect(err).To(MatchError(errImageDeferred))
+			})
+
+			It("defers the image when another call claimed the upload at the same time", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("GetString", uploadKey).Return("", false, nil).Once()
+				host.CacheMock.On("GetString", uploadKey).Return("7", true, nil)
+				registerCacheDefaults()
+
+				_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+				Expect(err).To(MatchError(errImageDeferred))
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+				host.CacheMock.AssertNotCalled(GinkgoT(), "Remove", uploadKey)
+			})
 
-			result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(result).To(Equal("mp:external/new-asset"))
+			It("leaves a marker another call set in place", func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("GetString", uploadKey).Return("", false, nil).Once()
+				host.CacheMock.On("GetString", uploadKey).Return("42", true, nil).Once()
+				host.CacheMock.On("GetString", uploadKey).Return("7", true, nil)
+				host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+				host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil)
+				registerCacheDefaults()
+				host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"}]`)}, nil)
+
+				result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal("mp:external/art"))
+				host.CacheMock.AssertNotCalled(GinkgoT(), "Remove", uploadKey)
+			})
 		})
 
 		It("returns error on HTTP failure", func() {
@@ -300,11 +1452,157 @@ var _ = Describe("discordRPC", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("empty external_asset_path"))
 		})
+
+		It("records the remaining rate limit budget from response headers", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			host.CacheMock.On("SetInt", "discord.ratelimit.external-assets.client123", int64(4), int64(2)).Return(nil)
+
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{
+				StatusCode: 200,
+				Headers:    map[string]string{"X-Ratelimit-Remaining": "4", "X-Ratelimit-Reset-After": "1.5"},
+				Body:       []byte(`[{"external_asset_path":"external/new-asset"}]`),
+			}, nil)
+
+			_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetInt", "discord.ratelimit.external-assets.client123", int64(4), int64(2))
+		})
+
+		It("defers the request while the rate limit budget is exhausted", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("GetInt", "discord.ratelimit.external-assets.client123").Return(int64(0), true, nil)
+			registerCacheDefaults()
+
+			_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("rate limited"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("keeps the last request of the budget in reserve", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("GetInt", "discord.ratelimit.external-assets.client123").Return(int64(1), true, nil)
+			registerCacheDefaults()
+
+			_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).To(MatchError(errRateLimited))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("marks the route exhausted on a 429 response using Retry-After", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetInt", "discord.ratelimit.external-assets.client123", int64(0), int64(3)).Return(nil)
+
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{
+				StatusCode: 429,
+				Headers:    map[string]string{"Retry-After": "3"},
+				Body:       []byte(`{"message":"You are being rate limited."}`),
+			}, nil)
+
+			_, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("rate limited"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetInt", "discord.ratelimit.external-assets.client123", int64(0), int64(3))
+		})
+	})
+
+	Describe("parseRateLimit", func() {
+		DescribeTable("parses Discord rate limit headers",
+			func(status int32, headers map[string]string, expectedRemaining, expectedReset int64, expectedOK bool) {
+				remaining, reset, ok := parseRateLimit(&host.HTTPResponse{StatusCode: status, Headers: headers})
+				Expect(ok).To(Equal(expectedOK))
+				Expect(remaining).To(Equal(expectedRemaining))
+				Expect(reset).To(Equal(expectedReset))
+			},
+			Entry("canonical header names", int32(200), map[string]string{"X-RateLimit-Remaining": "3", "X-RateLimit-Reset-After": "10"}, int64(3), int64(10), true),
+			Entry("case-insensitive header names", int32(200), map[string]string{"x-ratelimit-remaining": "1", "x-ratelimit-reset-after": "0.25"}, int64(1), int64(1), true),
+			Entry("rounds fractional reset up", int32(200), map[string]string{"X-Ratelimit-Remaining": "0", "X-Ratelimit-Reset-After": "2.1"}, int64(0), int64(3), true),
+			Entry("429 without remaining header", int32(429), map[string]string{"Retry-After": "5"}, int64(0), int64(5), true),
+			Entry("missing headers", int32(200), map[string]string{}, int64(0), int64(0), false),
+			Entry("malformed remaining", int32(200), map[string]string{"X-RateLimit-Remaining": "abc", "X-RateLimit-Reset-After": "1"}, int64(0), int64(0), false),
+		)
+	})
+
+	Describe("asset freshness", func() {
+		hash := hashKey("https://example.com/art.jpg")
+		proxyHead := mock.MatchedBy(func(req host.HTTPRequest) bool {
+			return req.Method == "HEAD" && req.URL == "https://media.discordapp.net/external/old-art"
+		})
+
+		BeforeEach(func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", assetFreshnessKey).Return("12", true)
+			host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil).Maybe()
+			host.CacheMock.On("GetString", "discord.image."+hash).Return("mp:external/old-art", true, nil)
+			pinClock(time.Unix(1714600000, 0))
+		})
+
+		It("uses assets uploaded within the freshness window", func() {
+			host.CacheMock.On("GetInt", "discord.imagetime."+hash).Return(int64(1714600000-3600), true, nil)
+
+			result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal("mp:external/old-art"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+		})
+
+		It("uploads stale assets again when Discord no longer serves them", func() {
+			host.CacheMock.On("GetInt", "discord.imagetime."+hash).Return(int64(1714600000-13*3600), true, nil)
+			host.HTTPMock.On("Send", proxyHead).Return(&host.HTTPResponse{StatusCode: 404}, nil)
+			host.CacheMock.On("Remove", "discord.image."+hash).Return(nil)
+			host.CacheMock.On("Remove", "discord.imagetime."+hash).Return(nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/new-art"}]`)}, nil)
+			host.CacheMock.On("SetString", "discord.image."+hash, "mp:external/new-art", imageCacheTTL).Return(nil)
+
+			result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal("mp:external/new-art"))
+			host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.image."+hash)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetInt", "discord.imagetime."+hash, int64(1714600000), imageCacheTTL)
+		})
+
+		It("uploads assets without an upload time again when they are gone", func() {
+			host.CacheMock.On("GetInt", "discord.imagetime."+hash).Return(int64(0), false, nil)
+			host.HTTPMock.On("Send", proxyHead).Return(&host.HTTPResponse{StatusCode: 404}, nil)
+			host.CacheMock.On("Remove", mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/new-art"}]`)}, nil)
+			host.CacheMock.On("SetString", "discord.image."+hash, mock.Anything, imageCacheTTL).Return(nil)
+
+			result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal("mp:external/new-art"))
+		})
+
+		It("keeps stale assets Discord still serves and restarts their window", func() {
+			host.CacheMock.On("GetInt", "discord.imagetime."+hash).Return(int64(1714600000-13*3600), true, nil)
+			host.HTTPMock.On("Send", proxyHead).Return(&host.HTTPResponse{StatusCode: 200}, nil)
+
+			result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal("mp:external/old-art"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", externalAssetsReq)
+			host.CacheMock.AssertCalled(GinkgoT(), "SetInt", "discord.imagetime."+hash, int64(1714600000), imageCacheTTL)
+		})
+
+		It("keeps stale assets when the check fails", func() {
+			host.CacheMock.On("GetInt", "discord.imagetime."+hash).Return(int64(1714600000-13*3600), true, nil)
+			host.HTTPMock.On("Send", proxyHead).Return((*host.HTTPResponse)(nil), errors.New("timeout"))
+
+			result, err := r.processImage("https://example.com/art.jpg", "client123", "token123", imageCacheTTL)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal("mp:external/old-art"))
+			host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", externalAssetsReq)
+		})
 	})
 
 	Describe("sendActivity", func() {
 		BeforeEach(func() {
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", assetFreshnessKey).Return("", false).Maybe()
+			host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil).Maybe()
 		})
 
 		It("sends activity with track artwork and SmallImage overlay", func() {
@@ -332,8 +1630,185 @@ var _ = Describe("discordRPC", func() {
 					SmallImage: navidromeLogoURL,
 					SmallText:  "Navidrome",
 				},
-			})
+			}, activityOptions{DefaultImage: navidromeLogoURL})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("logs the image fields sent", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"}]`)}, nil)
+			host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+			err := r.sendActivity("client123", "testuser", "token123", activity{
+				Application: "client123",
+				Name:        "Test Song",
+				Type:        2,
+				Assets:      activityAssets{LargeImage: "https://example.com/art.jpg", SmallImage: pauseIconURL},
+			}, activityOptions{})
 			Expect(err).ToNot(HaveOccurred())
+			pdk.PDKMock.AssertCalled(GinkgoT(), "Log", pdk.LogDebug, `Presence images for user testuser: large="mp:external/art" small="mp:external/art"`)
+		})
+
+		Context("asset modes", func() {
+			data := activity{
+				Application: "client123",
+				Name:        "Test Song",
+				Type:        2,
+				State:       "Test Artist",
+				Details:     "Test Album",
+				Assets: activityAssets{
+					LargeImage: "https://example.com/art.jpg",
+					SmallImage: pauseIconURL,
+					SmallText:  "Paused",
+				},
+			}
+
+			It("uses external-assets by default", func() {
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+				host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"}]`)}, nil)
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"large_image":"mp:external/art"`)
+				})).Return(nil)
+
+				Expect(r.sendActivity("client123", "testuser", "token123", data, activityOptions{})).To(Succeed())
+				host.HTTPMock.AssertCalled(GinkgoT(), "Send", externalAssetsReq)
+			})
+
+			It("sends image URLs as is in direct mode", func() {
+				var sent string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sent = args.Get(1).(string)
+				}).Return(nil)
+
+				Expect(r.sendActivity("client123", "testuser", "token123", data, activityOptions{AssetMode: assetModeDirect})).To(Succeed())
+				Expect(sent).To(ContainSubstring(`"large_image":"https://example.com/art.jpg"`))
+				Expect(sent).To(ContainSubstring(`"small_image":"` + pauseIconURL + `"`))
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+			})
+
+			It("drops non-https images in direct mode", func() {
+				var sent string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sent = args.Get(1).(string)
+				}).Return(nil)
+				host.CacheMock.On("SetString", lastErrorKeys, mock.Anything, lastErrorTTL).Return(nil)
+
+				plain := data
+				plain.Assets.LargeImage = "http://navidrome.local/art.jpg"
+				Expect(r.sendActivity("client123", "testuser", "token123", plain, activityOptions{AssetMode: assetModeDirect})).To(Succeed())
+				Expect(sent).To(ContainSubstring(`"large_image":""`))
+			})
+		})
+
+		Context("duplicate activities", func() {
+			data := activity{
+				Application: "client123",
+				Name:        "Test Song",
+				Type:        2,
+				State:       "Test Artist",
+				Details:     "Test Album",
+				Timestamps:  activityTimestamps{Start: 1714600000000, End: 1714600180000},
+				Assets:      activityAssets{LargeImage: "mp:external/art"},
+			}
+			opts := activityOptions{AssetMode: assetModeDirect}
+
+			BeforeEach(func() {
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil).Maybe()
+			})
+
+			It("skips an activity identical to the last one sent", func() {
+				host.CacheMock.On("GetString", "discord.lastactivity.testuser").Return(activityFingerprint(data, opts), true, nil)
+				registerCacheDefaults()
+
+				repeated := data
+				repeated.Timestamps.Start += 1200 // position jitter
+				Expect(r.sendActivity("client123", "testuser", "token123", repeated, opts)).To(Succeed())
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+			})
+
+			It("sends and remembers a changed activity", func() {
+				host.CacheMock.On("GetString", "discord.lastactivity.testuser").Return(activityFingerprint(data, opts), true, nil)
+				host.CacheMock.On("SetString", "discord.lastactivity.testuser", mock.Anything, lastActivityTTL).Return(nil)
+				registerCacheDefaults()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				next := data
+				next.Name = "Next Song"
+				Expect(r.sendActivity("client123", "testuser", "token123", next, opts)).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"name":"Next Song"`)
+				}))
+				host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.lastactivity.testuser", activityFingerprint(next, opts), lastActivityTTL)
+			})
+
+			It("does not remember activities that failed to send", func() {
+				registerCacheDefaults()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(errors.New("connection closed"))
+
+				Expect(r.sendActivity("client123", "testuser", "token123", data, opts)).ToNot(Succeed())
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.lastactivity.testuser", mock.Anything, mock.Anything)
+			})
+
+			It("forgets the last activity when it is cleared", func() {
+				registerCacheDefaults()
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				Expect(r.clearActivity("testuser")).To(Succeed())
+				host.CacheMock.AssertCalled(GinkgoT(), "Remove", "discord.lastactivity.testuser")
+			})
+
+			It("sends the activity again when switching back from a custom status", func() {
+				removed := false
+				pdk.PDKMock.On("GetConfig", customStatusFallbackKey).Return("true", true)
+				host.CacheMock.On("GetInt", "discord.customstatus.testuser").Return(int64(1714600000), true, nil)
+				host.CacheMock.On("GetInt", "discord.statusfailures.testuser").Return(int64(customStatusFallbackThreshold-1), true, nil)
+				host.CacheMock.On("Remove", "discord.lastactivity.testuser").Run(func(mock.Arguments) { removed = true }).Return(nil)
+				host.CacheMock.On("GetString", mock.MatchedBy(func(key string) bool {
+					return key == "discord.lastactivity.testuser" && !removed
+				})).Return(activityFingerprint(data, opts), true, nil)
+				registerCacheDefaults()
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"name":"Custom Status"`)
+				})).Return(errors.New("send failed"))
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Return(nil)
+
+				track := scrobbler.TrackInfo{Title: "Test Song", Artist: "Test Artist"}
+				Expect(sendWithFallback("client123", "testuser", "token123", data, opts, track, false)).To(Succeed())
+				host.WebSocketMock.AssertCalled(GinkgoT(), "SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"name":"Test Song"`)
+				}))
+			})
+		})
+
+		It("shares processed artwork across users", func() {
+			imageKey := "discord.image." + hashKey("https://example.com/album.jpg")
+			host.CacheMock.On("GetString", imageKey).Return("", false, nil).Once()
+			host.CacheMock.On("SetString", imageKey, "mp:external/album", mock.Anything).Return(nil).Once()
+			host.CacheMock.On("GetString", imageKey).Return("mp:external/album", true, nil)
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/album"}]`)}, nil)
+			for _, user := range []string{"alice", "bob"} {
+				host.WebSocketMock.On("SendText", user, mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"large_image":"mp:external/album"`)
+				})).Return(nil)
+			}
+
+			track := activity{
+				Application: "client123",
+				Name:        "Test Song",
+				Type:        2,
+				Assets:      activityAssets{LargeImage: "https://example.com/album.jpg", LargeText: "Test Album"},
+			}
+			Expect(r.sendActivity("client123", "alice", "alice-token", track, activityOptions{})).To(Succeed())
+			Expect(r.sendActivity("client123", "bob", "bob-token", track, activityOptions{})).To(Succeed())
+
+			// Only the first play uploads; the second user reuses the processed asset
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+			host.CacheMock.AssertExpectations(GinkgoT())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
 		})
 
 		It("falls back to default image and still processes SmallImage", func() {
@@ -364,8 +1839,166 @@ var _ = Describe("discordRPC", func() {
 					SmallImage: navidromeLogoURL,
 					SmallText:  "Navidrome",
 				},
+			}, activityOptions{DefaultImage: navidromeLogoURL})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Describe("image attribution", func() {
+			sendWithAttribution := func() string {
+				var sent string
+				host.WebSocketMock.On("SendText", "testuser", mock.Anything).Run(func(args mock.Arguments) {
+					sent = args.String(1)
+				}).Return(nil)
+
+				err := r.sendActivity("client123", "testuser", "token123", activity{
+					Application: "client123",
+					Name:        "Test Song",
+					Type:        activityTypeListening,
+					Assets: activityAssets{
+						LargeImage: "https://archive.org/art.jpg",
+						LargeText:  "Test Album",
+					},
+				}, activityOptions{DefaultImage: navidromeLogoURL, Attribution: "Art via Cover Art Archive"})
+				Expect(err).ToNot(HaveOccurred())
+				return sent
+			}
+
+			BeforeEach(func() {
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+				host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+			})
+
+			It("appends the attribution when the track artwork is shown", func() {
+				host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/art"}]`)}, nil)
+
+				Expect(sendWithAttribution()).To(ContainSubstring(`"large_text":"Test Album · Art via Cover Art Archive"`))
+			})
+
+			It("omits the attribution when falling back to the default image", func() {
+				host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 500, Body: []byte(`error`)}, nil).Once()
+				host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/logo"}]`)}, nil)
+
+				sent := sendWithAttribution()
+				Expect(sent).To(ContainSubstring(`"large_text":"Test Album"`))
+				Expect(sent).ToNot(ContainSubstring("Cover Art Archive"))
+			})
+		})
+
+		It("cleans up tag text before sending it", func() {
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"details":"Sigur Rós"`) &&
+					strings.Contains(msg, `"state":"Ágætis byrjun"`) &&
+					strings.Contains(msg, `"name":"Navidrome"`)
+			})).Return(nil)
+
+			err := r.sendActivity("client123", "testuser", "token123", activity{
+				Application: "client123",
+				Name:        "Navi\x00drome",
+				Type:        activityTypeListening,
+				Details:     "Sigur\u200b  Ro\u0301s",
+				State:       "A\u0301gætis\tbyrjun",
+				Assets:      activityAssets{LargeImage: "mp:external/art"},
+			}, activityOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+		})
+
+		Context("when Discord rejects the token on track art", func() {
+			track := activity{
+				Application: "client123",
+				Name:        "Test Song",
+				Type:        activityTypeListening,
+				Assets:      activityAssets{LargeImage: "https://example.com/art.jpg", LargeText: "Test Album"},
+			}
+
+			BeforeEach(func() {
+				pdk.PDKMock.On("GetConfig", clientIDKey).Return("1234567890123456789", true).Maybe()
+				pdk.PDKMock.On("GetConfig", usersKey).Return(`[{"username":"testuser","token":"token123"}]`, true).Maybe()
+				host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
 			})
+
+			It("skips the default image and disables the presence until the token changes", func() {
+				pdk.PDKMock.On("GetConfig", assetAuthFailureKey).Return("", false)
+				host.CacheMock.On("SetString", "discord.authfailed.testuser", tokenFingerprint("token123"), authFailedTTL).Return(nil)
+				host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 401, Body: []byte(`{"message":"401: Unauthorized"}`)}, nil)
+
+				err := r.sendActivity("client123", "testuser", "token123", track, activityOptions{DefaultImage: "https://example.com/default.png"})
+				Expect(err).To(MatchError(errAuthFailed))
+				Expect(err).To(MatchError(ContainSubstring("HTTP 401")))
+				Expect(isTransientError(err)).To(BeFalse())
+				host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+				host.CacheMock.AssertCalled(GinkgoT(), "SetString", "discord.authfailed.testuser", tokenFingerprint("token123"), authFailedTTL)
+				host.WebSocketMock.AssertNotCalled(GinkgoT(), "SendText", mock.Anything, mock.Anything)
+			})
+
+			It("sends the presence without artwork when configured to", func() {
+				pdk.PDKMock.On("GetConfig", assetAuthFailureKey).Return("noartwork", true)
+				host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 403, Body: []byte(`{"message":"Missing Access"}`)}, nil)
+				host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+					return strings.Contains(msg, `"large_image":""`)
+				})).Return(nil)
+
+				Expect(r.sendActivity("client123", "testuser", "token123", track, activityOptions{DefaultImage: "https://example.com/default.png"})).To(Succeed())
+				host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+				host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "discord.authfailed.testuser", mock.Anything, mock.Anything)
+				host.WebSocketMock.AssertExpectations(GinkgoT())
+			})
+		})
+
+		It("uses the default image from the options when track art fails", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+			host.CacheMock.On("SetString", discordImageKey, mock.Anything, mock.Anything).Return(nil)
+
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 500, Body: []byte(`error`)}, nil).Once()
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return strings.Contains(string(req.Body), "https://example.com/custom-default.png")
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"external_asset_path":"external/custom"}]`)}, nil)
+
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"large_image":"mp:external/custom"`)
+			})).Return(nil)
+
+			err := r.sendActivity("client123", "testuser", "token123", activity{
+				Application: "client123",
+				Name:        "Test Song",
+				Type:        activityTypeListening,
+				State:       "Test Artist",
+				Details:     "Test Album",
+				Assets: activityAssets{
+					LargeImage: "https://example.com/art.jpg",
+					LargeText:  "Test Album",
+				},
+			}, activityOptions{DefaultImage: "https://example.com/custom-default.png"})
+			Expect(err).ToNot(HaveOccurred())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+		})
+
+		It("skips the default image when the fallback is disabled", func() {
+			host.CacheMock.On("GetString", discordImageKey).Return("", false, nil)
+
+			host.HTTPMock.On("Send", externalAssetsReq).Return(&host.HTTPResponse{StatusCode: 500, Body: []byte(`error`)}, nil).Once()
+
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return strings.Contains(msg, `"large_image":""`) &&
+					!strings.Contains(msg, `"small_image":"mp:`)
+			})).Return(nil)
+
+			err := r.sendActivity("client123", "testuser", "token123", activity{
+				Application: "client123",
+				Name:        "Test Song",
+				Type:        activityTypeListening,
+				State:       "Test Artist",
+				Details:     "Test Album",
+				Assets: activityAssets{
+					LargeImage: "https://example.com/art.jpg",
+					LargeText:  "Test Album",
+					SmallImage: pauseIconURL,
+					SmallText:  "Paused",
+				},
+			}, activityOptions{})
 			Expect(err).ToNot(HaveOccurred())
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+			host.WebSocketMock.AssertExpectations(GinkgoT())
 		})
 
 		It("clears all images when both track art and default fail", func() {
@@ -391,7 +2024,7 @@ var _ = Describe("discordRPC", func() {
 					SmallImage: navidromeLogoURL,
 					SmallText:  "Navidrome",
 				},
-			})
+			}, activityOptions{DefaultImage: navidromeLogoURL})
 			Expect(err).ToNot(HaveOccurred())
 		})
 
@@ -419,7 +2052,7 @@ var _ = Describe("discordRPC", func() {
 					SmallImage: navidromeLogoURL,
 					SmallText:  "Navidrome",
 				},
-			})
+			}, activityOptions{DefaultImage: navidromeLogoURL})
 			Expect(err).ToNot(HaveOccurred())
 		})
 
@@ -479,7 +2112,7 @@ var _ = Describe("discordRPC", func() {
 					SmallText:  "Navidrome",
 					SmallURL:   longURL,
 				},
-			})
+			}, activityOptions{DefaultImage: navidromeLogoURL})
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
@@ -496,19 +2129,49 @@ var _ = Describe("discordRPC", func() {
 		})
 	})
 
+	Describe("truncateField", func() {
+		It("returns strings within the limit unchanged", func() {
+			Expect(truncateField("hello", 5)).To(Equal("hello"))
+			Expect(truncateField("", 5)).To(Equal(""))
+		})
+
+		It("counts CJK characters as one each", func() {
+			s := "東京事変の長いタイトル"
+			Expect(truncateField(s, 11)).To(Equal(s))
+			Expect(truncateField(s, 5)).To(Equal("東京事変…"))
+		})
+
+		It("never splits an emoji codepoint", func() {
+			result := truncateField(strings.Repeat("🎵", 10), 4)
+			Expect(result).To(Equal("🎵🎵🎵…"))
+			Expect(utf8.ValidString(result)).To(BeTrue())
+		})
+
+		It("truncates a 300-character artist to the limit", func() {
+			artist := strings.Repeat("Artist ", 43)[:300]
+			result := truncateField(artist, maxTextLength)
+			Expect([]rune(result)).To(HaveLen(maxTextLength))
+			Expect(result).To(Equal(artist[:maxTextLength-1] + "…"))
+		})
+
+		It("returns an empty string for a non-positive limit", func() {
+			Expect(truncateField("hello", 0)).To(Equal(""))
+		})
+	})
+
 	Describe("truncateText", func() {
 		It("returns short strings unchanged", func() {
-			Expect(truncateText("hello")).To(Equal("hello"))
+			Expect(truncateText("hello", truncateEllipsis)).To(Equal("hello"))
 		})
 
 		It("returns exactly 128-char strings unchanged", func() {
 			s := strings.Repeat("a", 128)
-			Expect(truncateText(s)).To(Equal(s))
+			Expect(truncateText(s, truncateEllipsis)).To(Equal(s))
 		})
 
 		It("truncates strings over 128 chars to 127 + ellipsis", func() {
 			s := strings.Repeat("a", 200)
-			result := truncateText(s)
+			result := truncateText(s, truncateEllipsis)
 			Expect([]rune(result)).To(HaveLen(128))
 			Expect(result).To(HaveSuffix("…"))
 		})
@@ -516,14 +2179,67 @@ var _ = Describe("discordRPC", func() {
 		It("handles multi-byte characters correctly", func() {
 			// 130 Japanese characters — each is one rune but 3 bytes
 			s := strings.Repeat("あ", 130)
-			result := truncateText(s)
+			result := truncateText(s, truncateEllipsis)
 			runes := []rune(result)
 			Expect(runes).To(HaveLen(128))
 			Expect(string(runes[127])).To(Equal("…"))
 		})
 
 		It("returns empty string unchanged", func() {
-			Expect(truncateText("")).To(Equal(""))
+			Expect(truncateText("", truncateEllipsis)).To(Equal(""))
+		})
+
+		Describe("strategies", func() {
+			// 10 words of 15 runes (each "ねこ" plus 13 "あ"), 159 runes with separators
+			word := "ねこ" + strings.Repeat("あ", 13)
+			long := strings.TrimSpace(strings.Repeat(word+" ", 10))
+
+			It("leaves short strings alone with every strategy", func() {
+				for _, strategy := range []string{truncateCut, truncateEllipsis, truncateWord} {
+					Expect(truncateText("あいう えお", strategy)).To(Equal("あいう えお"))
+				}
+			})
+
+			It("hard cuts at the limit", func() {
+				result := truncateText(long, truncateCut)
+				Expect([]rune(result)).To(HaveLen(128))
+				Expect(result).ToNot(HaveSuffix("…"))
+				Expect(long).To(HavePrefix(result))
+			})
+
+			It("cuts with an ellipsis", func() {
+				result := truncateText(long, truncateEllipsis)
+				Expect([]rune(result)).To(HaveLen(128))
+				Expect(result).To(Equal(string([]rune(long)[:127]) + "…"))
+			})
+
+			It("cuts at the last word boundary", func() {
+				result := truncateText(long, truncateWord)
+				// 8 full words and their separators take exactly 127 runes, the 9th doesn't fit
+				Expect(result).To(Equal(strings.TrimSpace(strings.Repeat(word+" ", 8)) + "…"))
+				Expect([]rune(result)).To(HaveLen(128))
+			})
+
+			It("never cuts a word in half", func() {
+				s := strings.Repeat("あいうえおかきくけこ ", 15)
+				result := truncateText(s, truncateWord)
+				Expect(result).To(HaveSuffix("こ…"))
+				Expect([]rune(result)).To(HaveLen(121))
+			})
+
+			It("falls back to an ellipsis cut when there is no word boundary", func() {
+				s := strings.Repeat("あ", 200)
+				Expect(truncateText(s, truncateWord)).To(Equal(truncateText(s, truncateEllipsis)))
+			})
+
+			It("ignores word boundaries too early in the text", func() {
+				s := "あ " + strings.Repeat("い", 200)
+				Expect(truncateText(s, truncateWord)).To(Equal(truncateText(s, truncateEllipsis)))
+			})
+
+			It("treats an empty strategy as ellipsis", func() {
+				Expect(truncateText(long, "")).To(Equal(truncateText(long, truncateEllipsis)))
+			})
 		})
 	})
 
@@ -546,4 +2262,93 @@ var _ = Describe("discordRPC", func() {
 			Expect(truncateURL("")).To(Equal(""))
 		})
 	})
+
+	Describe("fitButtons", func() {
+		It("keeps at most two buttons", func() {
+			buttons := fitButtons([]activityButton{
+				{Label: "One", URL: "https://example.com/1"},
+				{Label: "Two", URL: "https://example.com/2"},
+				{Label: "Three", URL: "https://example.com/3"},
+			})
+			Expect(buttons).To(HaveLen(maxButtons))
+			Expect(buttons[1].Label).To(Equal("Two"))
+		})
+
+		It("truncates labels to Discord's limit", func() {
+			buttons := fitButtons([]activityButton{{Label: strings.Repeat("é", 40), URL: "https://example.com"}})
+			Expect(utf8.RuneCountInString(buttons[0].Label)).To(Equal(maxButtonLabelLength))
+			Expect(buttons[0].Label).To(HaveSuffix("…"))
+		})
+
+		It("drops buttons without a usable URL", func() {
+			buttons := fitButtons([]activityButton{
+				{Label: "Empty", URL: ""},
+				{Label: "Too long", URL: "https://example.com/" + strings.Repeat("a", maxURLLength)},
+				{Label: "Listen", URL: "https://example.com/ok"},
+			})
+			Expect(buttons).To(Equal([]activityButton{{Label: "Listen", URL: "https://example.com/ok"}}))
+		})
+
+		It("returns nil when no buttons remain", func() {
+			Expect(fitButtons(nil)).To(BeNil())
+			Expect(fitButtons([]activityButton{{Label: "Empty"}})).To(BeNil())
+		})
+	})
+
+	Describe("fitPresence", func() {
+		longURL := func(name string) string {
+			return "https://example.com/" + name + "/" + strings.Repeat("a", 200)
+		}
+		oversized := func(largeImageLength int) presencePayload {
+			return presencePayload{
+				Status: "dnd",
+				Activities: []activity{{
+					Name:       "Test Song",
+					Details:    "Test Song",
+					DetailsURL: longURL("details"),
+					State:      "Test Artist",
+					StateURL:   longURL("state"),
+					Assets: activityAssets{
+						LargeImage: "mp:external/" + strings.Repeat("x", largeImageLength),
+						LargeURL:   longURL("large"),
+						SmallImage: "mp:external/small",
+						SmallText:  "Paused",
+					},
+					Buttons: []activityButton{{Label: "Listen on Spotify", URL: longURL("button")}},
+				}},
+			}
+		}
+
+		It("leaves presences within the limit untouched", func() {
+			presence := oversized(100)
+			Expect(fitPresence(&presence)).To(BeEmpty())
+			Expect(presence.Activities[0].Assets.SmallImage).To(Equal("mp:external/small"))
+		})
+
+		It("drops optional fields in order until the presence fits", func() {
+			presence := oversized(3500)
+			Expect(payloadSize(presenceOpCode, presence)).To(BeNumerically(">", maxPayloadSize))
+
+			dropped := fitPresence(&presence)
+			Expect(dropped).To(Equal([]string{"small image", "buttons", "large image URL", "state URL"}))
+			Expect(payloadSize(presenceOpCode, presence)).To(BeNumerically("<=", maxPayloadSize))
+			Expect(presence.Activities[0].DetailsURL).ToNot(BeEmpty())
+			Expect(presence.Activities[0].Assets.SmallImage).To(BeEmpty())
+		})
+
+		It("is applied by sendActivity before sending", func() {
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			host.CacheMock.On("GetInt", rateLimitKey).Return(int64(0), false, nil).Maybe()
+			presence := oversized(3500)
+			host.WebSocketMock.On("SendText", "testuser", mock.MatchedBy(func(msg string) bool {
+				return len(msg) <= maxPayloadSize &&
+					strings.Contains(msg, `"large_image":"mp:external/xxx`) &&
+					!strings.Contains(msg, `"small_image"`)
+			})).Return(nil)
+
+			err := r.sendActivity("client123", "testuser", "token123", presence.Activities[0], activityOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			host.WebSocketMock.AssertExpectations(GinkgoT())
+		})
+	})
 })
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// zeroWidthJoiner joins emoji into a single one, e.g. 👨‍👩‍👧. It is kept between
+// symbols and stripped anywhere else.
+const zeroWidthJoiner = '\u200D'
+
+// zeroWidthRunes are invisible characters that only clutter tags.
+var zeroWidthRunes = map[rune]bool{
+	'\u200B': true, // Zero width space
+	'\u200C': true, // Zero width non-joiner
+	'\u2060': true, // Word joiner
+	'\uFEFF': true, // Byte order mark
+}
+
+// latinCompositions maps combining marks to the Latin letters they compose with and
+// the precomposed letters they form, as pairs of strings of the same length. It
+// covers Latin-1 Supplement and Latin Extended-A, where decomposed accents, as
+// written by some taggers and file systems, show up in practice. A full Unicode
+// normalization table would weigh more than the rest of the plugin.
+var latinCompositions = map[rune][2]string{
+	'\u0300': {"AEIOUaeiou", "ÀÈÌÒÙàèìòù"},
+	'\u0301': {"ACEILNORSUYZaceilnorsuyz", "ÁĆÉÍĹŃÓŔŚÚÝŹáćéíĺńóŕśúýź"},
+	'\u0302': {"ACEGHIJOSUWYaceghijosuwy", "ÂĈÊĜĤÎĴÔŜÛŴŶâĉêĝĥîĵôŝûŵŷ"},
+	'\u0303': {"AINOUainou", "ÃĨÑÕŨãĩñõũ"},
+	'\u0304': {"AEIOUaeiou", "ĀĒĪŌŪāēīōū"},
+	'\u0306': {"AEGIOUaegiou", "ĂĔĞĬŎŬăĕğĭŏŭ"},
+	'\u0307': {"CEGIZcegz", "ĊĖĠİŻċėġż"},
+	'\u0308': {"AEIOUYaeiouy", "ÄËÏÖÜŸäëïöüÿ"},
+	'\u030A': {"AUau", "ÅŮåů"},
+	'\u030B': {"OUou", "ŐŰőű"},
+	'\u030C': {"CDELNRSTZcdelnrstz", "ČĎĚĽŇŘŠŤŽčďěľňřšťž"},
+	'\u0327': {"CGKLNRSTcgklnrst", "ÇĢĶĻŅŖŞŢçģķļņŗşţ"},
+	'\u0328': {"AEIUaeiu", "ĄĘĮŲąęįų"},
+}
+
+// composeLatin returns the precomposed letter for a Latin letter followed by a
+// combining mark, if there is one.
+func composeLatin(base, mark rune) (rune, bool) {
+	pair, ok := latinCompositions[mark]
+	if !ok || base > unicode.MaxASCII {
+		return 0, false
+	}
+	i := strings.IndexRune(pair[0], base)
+	if i < 0 {
+		return 0, false
+	}
+	// Bases are ASCII, so the byte index is the rune index
+	return []rune(pair[1])[i], true
+}
+
+// isEmojiPart reports whether r can precede a zero width joiner in an emoji sequence.
+func isEmojiPart(r rune) bool {
+	return unicode.Is(unicode.So, r) || r == '\uFE0F' || (r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+// sanitizeText cleans up tag text for Discord: control characters (C0 and C1) and
+// zero-width characters are removed, runs of whitespace become a single space, and
+// decomposed Latin accents are composed (NFC for the letters in latinCompositions).
+func sanitizeText(s string) string {
+	runes := []rune(s)
+	out := make([]rune, 0, len(runes))
+	space := false
+	for i, r := range runes {
+		switch {
+		case unicode.IsSpace(r):
+			space = len(out) > 0
+			continue
+		case unicode.IsControl(r), zeroWidthRunes[r]:
+			continue
+		case r == zeroWidthJoiner:
+			if len(out) == 0 || i+1 == len(runes) || !isEmojiPart(out[len(out)-1]) || !unicode.Is(unicode.So, runes[i+1]) {
+				continue
+			}
+		}
+		if space {
+			out = append(out, ' ')
+			space = false
+		}
+		if n := len(out); n > 0 {
+			if composed, ok := composeLatin(out[n-1], r); ok {
+				out[n-1] = composed
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("sanitizeText",
+	func(input, expected string) {
+		Expect(sanitizeText(input)).To(Equal(expected))
+	},
+	Entry("plain text", "Karma Police", "Karma Police"),
+	Entry("a null byte", "Karma\x00 Police", "Karma Police"),
+	Entry("C1 control characters", "Karma\u0085Police\u009f", "Karma Police"),
+	Entry("a zero width space", "Karma\u200bPolice", "KarmaPolice"),
+	Entry("zero width characters between words", "Karma \u200b\ufeffPolice", "Karma Police"),
+	Entry("decomposed accents", "Sigur Ro\u0301s - A\u0301gæt byrjun", "Sigur Rós - Ágæt byrjun"),
+	Entry("decomposed umlauts and cedillas", "Mo\u0308tley Cru\u0308e, Franc\u0327ois", "Mötley Crüe, François"),
+	Entry("marks without a precomposed letter are kept", "x\u0301", "x\u0301"),
+	Entry("repeated and unusual whitespace", "  Karma\t\tPolice \n(Live)  ", "Karma Police (Live)"),
+	Entry("joiners inside emoji are kept", "Family \U0001F468\u200d\U0001F469\u200d\U0001F467", "Family \U0001F468\u200d\U0001F469\u200d\U0001F467"),
+	Entry("joiners after emoji presentation selectors are kept", "❤\ufe0f\u200d\U0001F525", "❤\ufe0f\u200d\U0001F525"),
+	Entry("stray joiners are stripped", "Karma\u200dPolice\u200d", "KarmaPolice"),
+	Entry("empty text", "", ""),
+)
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// scrobbledBadge is appended to the album text once the playing track was scrobbled.
+const scrobbledBadge = "Scrobbled"
+
+// defaultScrobbledTTL is how long the scrobbled flag of a track without a duration
+// is kept, in seconds.
+const defaultScrobbledTTL int64 = 10 * 60
+
+// scrobbledKey returns the cache key holding the ID of the last track scrobbled by a user.
+func scrobbledKey(username string) string {
+	return fmt.Sprintf("discord.scrobbled.%s", username)
+}
+
+// recordScrobble flags the scrobbled track for the user, when the badge is enabled.
+// Navidrome scrobbles once a track is half played, so keeping the flag for the
+// track's duration covers the rest of the play without outliving it by much.
+func recordScrobble(input scrobbler.ScrobbleRequest) {
+	if enabled, _ := pdk.GetConfig(showScrobbledKey); enabled != "true" || input.Track.ID == "" {
+		return
+	}
+	ttl := int64(input.Track.Duration)
+	if ttl <= 0 {
+		ttl = defaultScrobbledTTL
+	}
+	if err := host.CacheSetString(scrobbledKey(input.Username), input.Track.ID, ttl); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to record scrobble for user %s: %v", input.Username, err))
+		return
+	}
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Recorded scrobble for user %s, track: %s", input.Username, input.Track.Title))
+}
+
+// withScrobbledBadge appends the scrobbled badge to the album text when the track
+// was scrobbled, so the next presence update for the track shows it.
+func withScrobbledBadge(albumText, username string, track scrobbler.TrackInfo) string {
+	enabled, _ := pdk.GetConfig(showScrobbledKey)
+	if enabled != "true" || albumText == "" || track.ID == "" {
+		return albumText
+	}
+	scrobbled, exists, err := host.CacheGetString(scrobbledKey(username))
+	if err != nil || !exists || scrobbled != track.ID {
+		return albumText
+	}
+	return albumText + " · " + scrobbledBadge
+}
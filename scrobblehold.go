@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// Scheduler callback payload for presence clears held back after playback stops
+const payloadHeldClear = "heldclear"
+
+// heldClearScheduleIDPrefix prefixes the username in held clear schedule IDs.
+const heldClearScheduleIDPrefix = "heldclear."
+
+// maxScrobbleThresholdMs caps the scrobble threshold: a track counts as listened after
+// half its duration or 4 minutes, whichever comes first.
+const maxScrobbleThresholdMs = 4 * 60 * 1000
+
+// heldClearKey returns the cache key marking a pending held clear for a user.
+func heldClearKey(username string) string {
+	return fmt.Sprintf("discord.heldclear.%s", username)
+}
+
+// scrobbleThresholdMs returns the track position at which Navidrome scrobbles a track,
+// or 0 for tracks without a duration.
+func scrobbleThresholdMs(durationSec float32) int64 {
+	return min(int64(durationSec*1000)/2, maxScrobbleThresholdMs)
+}
+
+// Caps for the clear delays, in seconds. Longer waits would leave a presence up well
+// after the music has stopped.
+const (
+	maxClearGrace     = 60
+	maxMinimumDisplay = 120
+)
+
+// resolveClearGrace returns how long a stopped track's presence is kept before it is
+// cleared, in seconds. 0 clears right away.
+func resolveClearGrace() int64 {
+	option, _ := pdk.GetConfig(clearGraceKey)
+	option = strings.TrimSpace(option)
+	if option == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(option)
+	if err != nil || seconds < 0 {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid clear grace %q, clearing presence right away", option))
+		return 0
+	}
+	return int64(min(seconds, maxClearGrace))
+}
+
+// resolveMinimumDisplay returns how long a track's presence is shown at least, in
+// seconds, so very short tracks don't disappear as soon as they appear. 0 disables it.
+func resolveMinimumDisplay() int64 {
+	option, _ := pdk.GetConfig(minimumDisplaySecondsKey)
+	option = strings.TrimSpace(option)
+	if option == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(option)
+	if err != nil || seconds < 0 {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid minimum display time %q, not extending short tracks", option))
+		return 0
+	}
+	return int64(min(seconds, maxMinimumDisplay))
+}
+
+// clearDelayMs returns how long to keep a stopped track's presence, in milliseconds:
+// the longest of the clear grace, what remains of the minimum display time, and, when
+// holding until scrobbled, what remains until the scrobble threshold.
+func clearDelayMs(input scrobbler.PlaybackReportRequest) int64 {
+	rate := input.PlaybackRate
+	if rate <= 0 {
+		rate = 1.0
+	}
+	delayMs := resolveClearGrace() * 1000
+	if minimum := resolveMinimumDisplay(); minimum > 0 {
+		delayMs = max(delayMs, minimum*1000-int64(float64(input.PositionMs)/rate))
+	}
+	if enabled, _ := pdk.GetConfig(holdUntilScrobbleKey); enabled == "true" {
+		delayMs = max(delayMs, int64(float64(scrobbleThresholdMs(input.Track.Duration)-input.PositionMs)/rate))
+	}
+	return delayMs
+}
+
+// holdClear delays clearing a stopped track's presence by clearDelayMs. It returns
+// false when the presence should be cleared right away.
+func holdClear(input scrobbler.PlaybackReportRequest) bool {
+	if input.State != stateStopped {
+		return false
+	}
+	remainingMs := clearDelayMs(input)
+	if remainingMs <= 0 {
+		return false
+	}
+	delay := int32((remainingMs + 999) / 1000)
+
+	if err := host.CacheSetString(heldClearKey(input.Username), input.Track.ID, int64(delay)+60); err != nil {
+		return false
+	}
+	if _, err := host.SchedulerScheduleOneTime(delay, payloadHeldClear, heldClearScheduleIDPrefix+input.Username); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule held presence clear for user %s: %v", input.Username, err))
+		_ = host.CacheRemove(heldClearKey(input.Username))
+		return false
+	}
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Holding presence for user %s, clearing it %ds from now", input.Username, delay))
+	return true
+}
+
+// cancelHeldClear drops a pending held clear for a user, as a newer report supersedes it.
+func cancelHeldClear(username string) {
+	if _, exists, err := host.CacheGetString(heldClearKey(username)); err != nil || !exists {
+		return
+	}
+	_ = host.CacheRemove(heldClearKey(username))
+	_ = host.SchedulerCancelSchedule(heldClearScheduleIDPrefix + username)
+}
+
+// handleHeldClearCallback clears the presence once the held clear is due.
+func (p *discordPlugin) handleHeldClearCallback(scheduleID string) error {
+	username := strings.TrimPrefix(scheduleID, heldClearScheduleIDPrefix)
+	if _, exists, err := host.CacheGetString(heldClearKey(username)); err != nil || !exists {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("No pending held presence clear for user %s", username))
+		return nil
+	}
+	_ = host.CacheRemove(heldClearKey(username))
+	if err := p.clearPresence(username); err != nil {
+		recordLastError(username, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// sessionTTL bounds how long a gateway session is kept for resuming. Discord
+// invalidates stale sessions with op 9, which falls back to a fresh identify.
+const sessionTTL int64 = connectionIDTTL
+
+// gatewaySession is the Discord gateway session of a user, captured from the READY
+// dispatch so a dropped connection can be resumed instead of re-identified.
+type gatewaySession struct {
+	SessionID string `json:"session_id"`
+	ResumeURL string `json:"resume_gateway_url"`
+	Seq       int64  `json:"seq"`
+}
+
+// resumePayload represents a Discord resume payload.
+type resumePayload struct {
+	Token     string `json:"token"`
+	SessionID string `json:"session_id"`
+	Seq       int64  `json:"seq"`
+}
+
+// sessionKey returns the cache key holding a user's gateway session.
+func sessionKey(username string) string {
+	return fmt.Sprintf("discord.session.%s", username)
+}
+
+// getSession returns the resumable gateway session of a user, if any.
+func (r *discordRPC) getSession(username string) (gatewaySession, bool) {
+	value, exists, err := host.CacheGetString(sessionKey(username))
+	if err != nil || !exists {
+		return gatewaySession{}, false
+	}
+	var session gatewaySession
+	if err := json.Unmarshal([]byte(value), &session); err != nil || session.SessionID == "" {
+		return gatewaySession{}, false
+	}
+	return session, true
+}
+
+// storeSession saves the gateway session of a user.
+func (r *discordRPC) storeSession(username string, session gatewaySession) {
+	b, _ := json.Marshal(session)
+	if err := host.CacheSetString(sessionKey(username), string(b), sessionTTL); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to store gateway session for user %s: %v", username, err))
+	}
+}
+
+// updateSessionSeq records the last sequence number seen in a user's session,
+// which Discord needs to replay missed events on resume.
+func (r *discordRPC) updateSessionSeq(username string, seq int64) {
+	session, ok := r.getSession(username)
+	if !ok || session.Seq == seq {
+		return
+	}
+	session.Seq = seq
+	r.storeSession(username, session)
+}
+
+// clearSession forgets the gateway session of a user, so the next connect identifies.
+func (r *discordRPC) clearSession(username string) {
+	_ = host.CacheRemove(sessionKey(username))
+}
+
+// handleReady captures the session from a READY dispatch.
+func (r *discordRPC) handleReady(username string, data map[string]any, seq int64) {
+	sessionID, _ := data["session_id"].(string)
+	if sessionID == "" {
+		return
+	}
+	resumeURL, _ := data["resume_gateway_url"].(string)
+	r.storeSession(username, gatewaySession{SessionID: sessionID, ResumeURL: resumeURL, Seq: seq})
+	_ = host.CacheRemove(reconnectAttemptsKey(username))
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Stored gateway session for user %s", username))
+}
+
+// Scheduler callback payload for gateway reconnects
+const payloadReconnect = "reconnect"
+
+// reconnectScheduleIDPrefix prefixes the username in reconnect schedule IDs.
+const reconnectScheduleIDPrefix = "reconnect."
+
+// Discord asks clients to wait a random 1-5 seconds before identifying again
+// after an invalid session.
+const (
+	invalidSessionMinDelay = 1
+	invalidSessionMaxDelay = 5
+)
+
+// Backoff for reconnects after recoverable close codes, in seconds. The delay
+// doubles with each attempt until a session is established again.
+const (
+	reconnectBaseDelay = 5
+	reconnectMaxDelay  = 300
+
+	// reconnectAttemptsTTL bounds how long failed attempts count towards the backoff.
+	reconnectAttemptsTTL int64 = 30 * 60
+)
+
+// reconnectAttemptsKey returns the cache key counting a user's consecutive reconnects.
+func reconnectAttemptsKey(username string) string {
+	return fmt.Sprintf("discord.reconnects.%s", username)
+}
+
+// reconnectBackoff returns the delay before the given reconnect attempt, counting from zero.
+func reconnectBackoff(attempt int64) int64 {
+	delay := int64(reconnectBaseDelay)
+	for i := int64(0); i < attempt && delay < reconnectMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, reconnectMaxDelay)
+}
+
+// scheduleBackoffReconnect schedules a reconnect after a recoverable close, waiting
+// longer with each consecutive attempt.
+func (r *discordRPC) scheduleBackoffReconnect(username string) error {
+	attempts, _, _ := host.CacheGetInt(reconnectAttemptsKey(username))
+	delay := reconnectBackoff(attempts)
+	_ = host.CacheSetInt(reconnectAttemptsKey(username), attempts+1, reconnectAttemptsTTL)
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Reconnecting user %s in %ds", username, delay))
+	return r.scheduleReconnect(username, delay)
+}
+
+// scheduleReconnect schedules a reconnect for a user after delay seconds.
+func (r *discordRPC) scheduleReconnect(username string, delay int64) error {
+	if _, err := host.SchedulerScheduleOneTime(int32(delay), payloadReconnect, reconnectScheduleIDPrefix+username); err != nil {
+		return fmt.Errorf("failed to schedule reconnect: %w", err)
+	}
+	return nil
+}
+
+// handleReconnectRequest tears down a connection Discord asked us to leave (op 7)
+// and connects again right away, resuming the session.
+func (r *discordRPC) handleReconnectRequest(username string) error {
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Discord requested a reconnect for user %s", username))
+	return r.reconnect(username)
+}
+
+// handleInvalidSession drops a session Discord refused (op 9), closes the connection it
+// was on, and schedules a reconnect with a fresh identify after a random delay.
+func (r *discordRPC) handleInvalidSession(username string) error {
+	r.clearSession(username)
+	r.cleanupFailedConnection(username)
+	delay := invalidSessionMinDelay + randIntn(invalidSessionMaxDelay-invalidSessionMinDelay+1)
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Gateway session for user %s is invalid, identifying again in %ds", username, delay))
+	return r.scheduleReconnect(username, int64(delay))
+}
+
+// handleReconnectCallback processes scheduled reconnects.
+func (r *discordRPC) handleReconnectCallback(scheduleID string) error {
+	return r.reconnect(strings.TrimPrefix(scheduleID, reconnectScheduleIDPrefix))
+}
+
+// connTokenTTL bounds how long the token fingerprint of a user's last connection is kept.
+const connTokenTTL int64 = connectionIDTTL
+
+// connTokenKey returns the cache key holding the fingerprint of the token a user last
+// connected with.
+func connTokenKey(username string) string {
+	return fmt.Sprintf("discord.token.%s", username)
+}
+
+// storeConnToken remembers which token a user's connection was made with. Only its
+// fingerprint is cached, so a close code rejecting the token is blamed on that token,
+// even when the configuration has changed since.
+func storeConnToken(username, token string) {
+	if err := host.CacheSetString(connTokenKey(username), tokenFingerprint(token), connTokenTTL); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to store connection token fingerprint for user %s: %v", username, err))
+	}
+}
+
+// connTokenFingerprint returns the fingerprint of the token a user last connected with,
+// or "" when it isn't cached.
+func connTokenFingerprint(username string) string {
+	fingerprint, exists, err := host.CacheGetString(connTokenKey(username))
+	if err != nil || !exists {
+		return ""
+	}
+	return fingerprint
+}
+
+// forgetConnToken drops the fingerprint of the token a user last connected with.
+func forgetConnToken(username string) {
+	_ = host.CacheRemove(connTokenKey(username))
+}
+
+// reconnect replaces the user's connection with a new one. A stored session is
+// resumed; otherwise the new connection identifies from scratch. The token is read
+// from the config, so a changed token takes effect on the next reconnect. A token
+// Discord already rejected isn't retried, and pending reconnects are dropped.
+func (r *discordRPC) reconnect(username string) error {
+	_, users, err := getConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	user, ok := users[username]
+	if !ok {
+		return fmt.Errorf("user '%s' not authorized", username)
+	}
+	if r.isAuthFailed(username, user.Token) {
+		_ = host.SchedulerCancelSchedule(reconnectScheduleIDPrefix + username)
+		_ = host.CacheRemove(reconnectAttemptsKey(username))
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Not reconnecting user %s: Discord rejected their token", username))
+		return nil
+	}
+	r.cleanupFailedConnection(username)
+	if err := r.connect(username, user.Token); err != nil {
+		return fmt.Errorf("failed to reconnect: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+)
+
+// songCacheTTL is how long a track's Subsonic song details are cached: 24 hours
+const songCacheTTL int64 = 24 * 60 * 60
+
+// songDetails are the track details looked up from Subsonic, which Navidrome
+// doesn't include in playback reports.
+type songDetails struct {
+	Suffix   string   `json:"suffix,omitempty"`
+	BitDepth int      `json:"bitDepth,omitempty"`
+	Year     int      `json:"year,omitempty"`
+	Genres   []string `json:"genres,omitempty"`
+}
+
+// subsonicSongResponse is the subset of the Subsonic getSong response used here.
+// OpenSubsonic servers list every genre; the legacy field holds only the first.
+type subsonicSongResponse struct {
+	Response struct {
+		Song struct {
+			Suffix   string `json:"suffix"`
+			BitDepth int    `json:"bitDepth"`
+			Year     int    `json:"year"`
+			Genre    string `json:"genre"`
+			Genres   []struct {
+				Name string `json:"name"`
+			} `json:"genres"`
+		} `json:"song"`
+	} `json:"subsonic-response"`
+}
+
+// songCacheKey returns the cache key for a track's song details.
+func songCacheKey(trackID string) string {
+	return fmt.Sprintf("discord.song.%s", trackID)
+}
+
+// trackSong looks up the track's song details, caching them so the presence
+// features that need them share one Subsonic call per track. Returns ok=false
+// when the details are unavailable.
+func trackSong(username, trackID string) (songDetails, bool) {
+	var song songDetails
+	cacheKey := songCacheKey(trackID)
+	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		if err := json.Unmarshal([]byte(cached), &song); err == nil {
+			return song, true
+		}
+	}
+
+	resp, err := host.SubsonicAPICall(fmt.Sprintf("/getSong?u=%s&id=%s", url.QueryEscape(username), url.QueryEscape(trackID)))
+	if err != nil {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Failed to get song details for track %s: %v", trackID, err))
+		return song, false
+	}
+	var parsed subsonicSongResponse
+	if err := json.Unmarshal([]byte(resp), &parsed); err != nil {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Failed to parse song details for track %s: %v", trackID, err))
+		return song, false
+	}
+
+	s := parsed.Response.Song
+	song = songDetails{Suffix: s.Suffix, BitDepth: s.BitDepth, Year: s.Year}
+	if s.Genre != "" {
+		song.Genres = append(song.Genres, s.Genre)
+	}
+	for _, g := range s.Genres {
+		song.Genres = append(song.Genres, g.Name)
+	}
+	if data, err := json.Marshal(song); err == nil {
+		_ = host.CacheSetString(cacheKey, string(data), songCacheTTL)
+	}
+	return song, true
+}
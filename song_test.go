@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("trackSong", func() {
+	track := scrobbler.TrackInfo{ID: "track1"}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.SubsonicAPIMock.ExpectedCalls = nil
+		host.SubsonicAPIMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+	})
+
+	It("looks up every detail with a single call and caches them", func() {
+		host.CacheMock.On("GetString", "discord.song.track1").Return("", false, nil)
+		host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track1").
+			Return(`{"subsonic-response":{"song":{"suffix":"flac","bitDepth":24,"year":1997,"genre":"Rock","genres":[{"name":"Rock"},{"name":"Art Rock"}]}}}`, nil).Once()
+		host.CacheMock.On("SetString", "discord.song.track1",
+			`{"suffix":"flac","bitDepth":24,"year":1997,"genres":["Rock","Rock","Art Rock"]}`, songCacheTTL).Return(nil)
+
+		song, ok := trackSong("testuser", "track1")
+		Expect(ok).To(BeTrue())
+		Expect(song).To(Equal(songDetails{Suffix: "flac", BitDepth: 24, Year: 1997, Genres: []string{"Rock", "Rock", "Art Rock"}}))
+		host.CacheMock.AssertExpectations(GinkgoT())
+	})
+
+	It("serves the year, quality and genres from the cached details", func() {
+		host.CacheMock.On("GetString", "discord.song.track1").
+			Return(`{"suffix":"flac","year":1997,"genres":["Comedy"]}`, true, nil)
+		pdk.PDKMock.On("GetConfig", hideArtistsKey).Return("", false)
+		pdk.PDKMock.On("GetConfig", hideGenresKey).Return("comedy", true)
+
+		Expect(trackYear("testuser", "track1")).To(Equal("1997"))
+		Expect(trackIsLossless("testuser", track)).To(BeTrue())
+		Expect(isTrackHidden("testuser", track)).To(BeTrue())
+		host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+	})
+
+	It("escapes the username and track ID", func() {
+		host.CacheMock.On("GetString", "discord.song.a&b").Return("", false, nil)
+		host.SubsonicAPIMock.On("Call", "/getSong?u=j%C3%BCrgen+k&id=a%26b").
+			Return(`{"subsonic-response":{"song":{"year":2004}}}`, nil)
+		host.CacheMock.On("SetString", "discord.song.a&b", mock.Anything, songCacheTTL).Return(nil)
+
+		Expect(trackYear("jürgen k", "a&b")).To(Equal("2004"))
+	})
+})
@@ -1,26 +1,27 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
 	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
 )
 
-// hashKey returns a hex-encoded FNV-1a hash of s, for use as a cache key suffix.
+// hashKey returns a hex-encoded hash of s, for use as a cache key suffix. It is the
+// first 128 bits of a SHA-256 hash: a shorter, non-cryptographic hash lets distinct
+// inputs, such as two artwork URLs, share a key and serve each other's cache entry.
 func hashKey(s string) string {
-	const offset64 uint64 = 14695981039346656037
-	const prime64 uint64 = 1099511628211
-	h := offset64
-	for i := 0; i < len(s); i++ {
-		h ^= uint64(s[i])
-		h *= prime64
-	}
-	return fmt.Sprintf("%016x", h)
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
 }
 
 const (
@@ -28,6 +29,13 @@ const (
 	spotifyCacheTTLMiss int64 = 4 * 60 * 60       // 4 hours for misses (retry later)
 )
 
+// listenBrainzDefaultBaseURL is the public ListenBrainz Labs API, used unless
+// listenbrainzbaseurl points to a self-hosted instance or mirror.
+const listenBrainzDefaultBaseURL = "https://labs.api.listenbrainz.org"
+
+// listenBrainzTimeOut keeps a slow ListenBrainz response from blocking the presence update.
+const listenBrainzTimeOut = 3000
+
 // listenBrainzResult captures the relevant field from ListenBrainz Labs JSON responses.
 // The API returns spotify_track_ids as an array of strings.
 type listenBrainzResult struct {
@@ -44,19 +52,107 @@ func spotifySearchURL(terms ...string) string {
 	return "https://open.spotify.com/search/" + url.PathEscape(query)
 }
 
+// normalizeLookupTitle strips suffixes matching the configured title patterns, such
+// as "(feat. X)" or "- Remastered 2011", which hurt metadata matching. The displayed
+// title is unaffected.
+func normalizeLookupTitle(title string) string {
+	option, _ := pdk.GetConfig(titleStripPatternsKey)
+	return stripTitleSuffixes(title, parseSuffixPatterns(option))
+}
+
+// parseSuffixPatterns splits a comma-separated list of suffix words, lowercased.
+func parseSuffixPatterns(option string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(option, ",") {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// stripTitleSuffixes removes suffixes containing one of the patterns from a title or
+// album name. Suffixes are parenthesized or bracketed, or follow " - ", and are
+// removed from the end one at a time. A name that would end up empty is kept as is.
+func stripTitleSuffixes(title string, patterns []string) string {
+	if len(patterns) == 0 {
+		return title
+	}
+	normalized := strings.TrimSpace(title)
+	for {
+		rest, suffix := splitTitleSuffix(normalized)
+		if suffix == "" || !slices.ContainsFunc(patterns, func(p string) bool { return containsWord(suffix, p) }) {
+			break
+		}
+		normalized = rest
+	}
+	if normalized == "" {
+		return title
+	}
+	return normalized
+}
+
+// splitTitleSuffix splits the last parenthesized, bracketed or dash-separated suffix
+// off a title. The suffix is "" when there is none.
+func splitTitleSuffix(title string) (string, string) {
+	for _, pair := range []string{"()", "[]"} {
+		if strings.HasSuffix(title, pair[1:]) {
+			if open := strings.LastIndex(title, pair[:1]); open > 0 {
+				return strings.TrimSpace(title[:open]), title[open+1 : len(title)-1]
+			}
+		}
+	}
+	if dash := strings.LastIndex(title, " - "); dash > 0 {
+		return strings.TrimSpace(title[:dash]), title[dash+3:]
+	}
+	return title, ""
+}
+
+// containsWord reports whether s contains pattern at the start of a word, ignoring case.
+func containsWord(s, pattern string) bool {
+	s = strings.ToLower(s)
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], pattern)
+		if i < 0 {
+			return false
+		}
+		i += offset
+		if i == 0 {
+			return true
+		}
+		if r, _ := utf8.DecodeLastRuneInString(s[:i]); !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return true
+		}
+		offset = i + 1
+	}
+}
+
 // spotifyCacheKey returns a deterministic cache key for a track's Spotify URL.
 func spotifyCacheKey(artist, title, album string) string {
-	return "spotify.url." + hashKey(strings.ToLower(artist)+"\x00"+strings.ToLower(title)+"\x00"+strings.ToLower(album))
+	return "spotify.url." + trackHash(artist, title, album)
+}
+
+// trackHash returns a case-insensitive hash identifying a track by its metadata.
+func trackHash(artist, title, album string) string {
+	return hashKey(strings.ToLower(artist) + "\x00" + strings.ToLower(title) + "\x00" + strings.ToLower(album))
+}
+
+// spotifyMBIDCacheKey returns the cache key for a recording's Spotify URL. It is preferred
+// over spotifyCacheKey when the MBID is known, as it survives retagging and is shared by
+// differently-tagged copies of the same recording.
+func spotifyMBIDCacheKey(mbid string) string {
+	return "spotify.mbid." + mbid
 }
 
 // trySpotifyFromMBID calls the ListenBrainz spotify-id-from-mbid endpoint.
 func trySpotifyFromMBID(mbid string) string {
 	body := fmt.Sprintf(`[{"recording_mbid":%q}]`, mbid)
-	resp, err := host.HTTPSend(host.HTTPRequest{
-		Method:  "POST",
-		URL:     "https://labs.api.listenbrainz.org/spotify-id-from-mbid/json",
-		Headers: map[string]string{"Content-Type": "application/json"},
-		Body:    []byte(body),
+	resp, err := httpSendWithRetry(host.HTTPRequest{
+		Method:    "POST",
+		URL:       configBaseURL(listenBrainzBaseURLKey, listenBrainzDefaultBaseURL) + "/spotify-id-from-mbid/json",
+		Headers:   map[string]string{"Content-Type": "application/json"},
+		TimeoutMs: listenBrainzTimeOut,
+		Body:      []byte(body),
 	})
 	if err != nil {
 		pdk.Log(pdk.LogInfo, fmt.Sprintf("ListenBrainz MBID lookup request failed: %v", err))
@@ -74,16 +170,21 @@ func trySpotifyFromMBID(mbid string) string {
 }
 
 // trySpotifyFromMetadata calls the ListenBrainz spotify-id-from-metadata endpoint.
-func trySpotifyFromMetadata(artist, title, album string) string {
+// A non-empty releaseMBID is added to the query to constrain the match to that release.
+func trySpotifyFromMetadata(artist, title, album, releaseMBID string) string {
 	payload := fmt.Sprintf(`[{"artist_name":%q,"track_name":%q,"release_name":%q}]`, artist, title, album)
+	if releaseMBID != "" {
+		payload = fmt.Sprintf(`[{"artist_name":%q,"track_name":%q,"release_name":%q,"release_mbid":%q}]`, artist, title, album, releaseMBID)
+	}
 
 	pdk.Log(pdk.LogDebug, fmt.Sprintf("ListenBrainz metadata request: %s", payload))
 
-	resp, err := host.HTTPSend(host.HTTPRequest{
-		Method:  "POST",
-		URL:     "https://labs.api.listenbrainz.org/spotify-id-from-metadata/json",
-		Headers: map[string]string{"Content-Type": "application/json"},
-		Body:    []byte(payload),
+	resp, err := httpSendWithRetry(host.HTTPRequest{
+		Method:    "POST",
+		URL:       configBaseURL(listenBrainzBaseURLKey, listenBrainzDefaultBaseURL) + "/spotify-id-from-metadata/json",
+		Headers:   map[string]string{"Content-Type": "application/json"},
+		TimeoutMs: listenBrainzTimeOut,
+		Body:      []byte(payload),
 	})
 	if err != nil {
 		pdk.Log(pdk.LogInfo, fmt.Sprintf("ListenBrainz metadata lookup request failed: %v", err))
@@ -132,28 +233,79 @@ func isValidSpotifyID(id string) bool {
 	return true
 }
 
+// spotifyLookupSteps reports which Spotify resolution steps are enabled. All steps
+// are on unless explicitly disabled.
+func spotifyLookupSteps() (mbid, metadata, search bool) {
+	mbidOption, _ := pdk.GetConfig(spotifyMBIDLookupKey)
+	metadataOption, _ := pdk.GetConfig(spotifyMetadataLookupKey)
+	searchOption, _ := pdk.GetConfig(spotifySearchFallbackKey)
+	return mbidOption != "false", metadataOption != "false", searchOption != "false"
+}
+
 // resolveSpotifyURL resolves a direct Spotify track URL via ListenBrainz Labs,
 // falling back to a search URL. Results are cached.
 func resolveSpotifyURL(track scrobbler.TrackInfo) string {
-	var primary string
-	if len(track.Artists) > 0 {
-		primary = track.Artists[0].Name
+	primary, cacheKey := spotifyTrackKey(track)
+	_, _, searchEnabled := spotifyLookupSteps()
+	if !searchEnabled {
+		cacheKey += spotifyNoSearchSuffix
 	}
 
-	cacheKey := spotifyCacheKey(primary, track.Title, track.Album)
-
-	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+	if cached, exists, err := cacheGetString(cacheKey); err == nil && exists {
 		pdk.Log(pdk.LogDebug, fmt.Sprintf("Spotify URL cache hit for %q - %q → %s", primary, track.Title, cached))
+		if isSpotifySearchURL(cached) && randIntn(spotifySearchRefreshChance) == 0 {
+			scheduleSpotifyRefresh(track, cacheKey)
+		}
 		return cached
 	}
 
 	pdk.Log(pdk.LogDebug, fmt.Sprintf("Resolving Spotify URL for: artist=%q title=%q album=%q mbid=%q", primary, track.Title, track.Album, track.MBZRecordingID))
 
-	// 1. Try MBID lookup (most accurate)
+	if directURL := lookupSpotifyTrackURL(track, primary, cacheKey); directURL != "" {
+		return directURL
+	}
+
+	// 3. Fallback to search URL
+	if !searchEnabled {
+		_ = cacheSetString(cacheKey, "", spotifyCacheTTLMiss)
+		pdk.Log(pdk.LogInfo, fmt.Sprintf("Spotify resolution missed and search fallback is disabled for %q - %q", primary, track.Title))
+		return ""
+	}
+	searchURL := spotifySearchURL(track.Artist, normalizeLookupTitle(track.Title))
+	_ = cacheSetString(cacheKey, searchURL, spotifyCacheTTLMiss)
+	pdk.Log(pdk.LogInfo, fmt.Sprintf("Spotify resolution missed, falling back to search URL for %q - %q: %s", primary, track.Title, searchURL))
+	return searchURL
+}
+
+// spotifyNoSearchSuffix keeps results resolved with the search fallback disabled apart
+// in the cache, so a cached miss never hides a search URL once the fallback is enabled
+// again, and a cached search URL is never shown while it is disabled.
+const spotifyNoSearchSuffix = ".nosearch"
+
+// spotifyTrackKey returns the primary artist used for lookups and the cache key of a track.
+func spotifyTrackKey(track scrobbler.TrackInfo) (primary, cacheKey string) {
+	if len(track.Artists) > 0 {
+		primary = track.Artists[0].Name
+	}
+	cacheKey = spotifyCacheKey(primary, track.Title, track.Album)
 	if track.MBZRecordingID != "" {
+		cacheKey = spotifyMBIDCacheKey(track.MBZRecordingID)
+	}
+	return primary, cacheKey
+}
+
+// lookupSpotifyTrackURL tries the enabled ListenBrainz lookups for a direct track URL,
+// caching it when found. It returns "" when neither lookup resolves the track.
+func lookupSpotifyTrackURL(track scrobbler.TrackInfo, primary, cacheKey string) string {
+	mbidEnabled, metadataEnabled, _ := spotifyLookupSteps()
+
+	// 1. Try MBID lookup (most accurate)
+	if !mbidEnabled {
+		pdk.Log(pdk.LogDebug, "MBID lookup disabled, skipping")
+	} else if track.MBZRecordingID != "" {
 		if trackID := trySpotifyFromMBID(track.MBZRecordingID); trackID != "" {
 			directURL := "https://open.spotify.com/track/" + trackID
-			_ = host.CacheSetString(cacheKey, directURL, spotifyCacheTTLHit)
+			_ = cacheSetString(cacheKey, directURL, spotifyCacheTTLHit)
 			pdk.Log(pdk.LogInfo, fmt.Sprintf("Resolved Spotify via MBID for %q: %s", track.Title, directURL))
 			return directURL
 		}
@@ -162,19 +314,85 @@ func resolveSpotifyURL(track scrobbler.TrackInfo) string {
 		pdk.Log(pdk.LogDebug, "No MBZRecordingID available, skipping MBID lookup")
 	}
 
-	// 2. Try metadata lookup
-	if primary != "" && track.Title != "" {
-		if trackID := trySpotifyFromMetadata(primary, track.Title, track.Album); trackID != "" {
+	// 2. Try metadata lookup, constrained to the release when there's no recording MBID
+	var releaseMBID string
+	if useReleaseMBID, _ := pdk.GetConfig(releaseMBIDLookupKey); useReleaseMBID == "true" && track.MBZRecordingID == "" {
+		releaseMBID = track.MBZAlbumID
+	}
+	if metadataEnabled && primary != "" && track.Title != "" {
+		// ListenBrainz matches against the full artist credit, so collaborators help
+		credit := primary
+		if names := artistNames(track); len(names) > 1 {
+			credit = joinArtistNames(names, "")
+		}
+		if trackID := trySpotifyFromMetadata(credit, normalizeLookupTitle(track.Title), track.Album, releaseMBID); trackID != "" {
 			directURL := "https://open.spotify.com/track/" + trackID
-			_ = host.CacheSetString(cacheKey, directURL, spotifyCacheTTLHit)
+			_ = cacheSetString(cacheKey, directURL, spotifyCacheTTLHit)
 			pdk.Log(pdk.LogInfo, fmt.Sprintf("Resolved Spotify via metadata for %q - %q: %s", primary, track.Title, directURL))
 			return directURL
 		}
 	}
+	return ""
+}
 
-	// 3. Fallback to search URL
-	searchURL := spotifySearchURL(track.Artist, track.Title)
-	_ = host.CacheSetString(cacheKey, searchURL, spotifyCacheTTLMiss)
-	pdk.Log(pdk.LogInfo, fmt.Sprintf("Spotify resolution missed, falling back to search URL for %q - %q: %s", primary, track.Title, searchURL))
-	return searchURL
+// Scheduler callback payload for background Spotify re-resolution
+const payloadSpotifyRefresh = "spotifyrefresh"
+
+// spotifyRefreshScheduleIDPrefix prefixes the track cache key in re-resolution schedule IDs.
+const spotifyRefreshScheduleIDPrefix = "spotifyrefresh."
+
+// spotifySearchRefreshChance is the inverse probability that serving a cached search
+// URL triggers a background attempt at a direct link: 1 in 10 cache hits. ListenBrainz
+// mappings improve over time, and this spreads retries out instead of waiting for the
+// search URL to expire.
+const spotifySearchRefreshChance = 10
+
+// spotifyRefreshTTL bounds how long a track waits for its scheduled re-resolution: 5 minutes
+const spotifyRefreshTTL int64 = 5 * 60
+
+// isSpotifySearchURL reports whether url is a search fallback rather than a direct track link.
+func isSpotifySearchURL(url string) bool {
+	return strings.HasPrefix(url, "https://open.spotify.com/search/")
+}
+
+// spotifyRefreshKey returns the cache key holding the track to re-resolve.
+func spotifyRefreshKey(cacheKey string) string {
+	return "spotify.refresh." + cacheKey
+}
+
+// scheduleSpotifyRefresh schedules a background attempt at a direct link for a track
+// served a search URL, so the current presence update isn't delayed by the lookups.
+func scheduleSpotifyRefresh(track scrobbler.TrackInfo, cacheKey string) {
+	b, err := json.Marshal(track)
+	if err != nil {
+		return
+	}
+	if err := host.CacheSetString(spotifyRefreshKey(cacheKey), string(b), spotifyRefreshTTL); err != nil {
+		return
+	}
+	if _, err := host.SchedulerScheduleOneTime(1, payloadSpotifyRefresh, spotifyRefreshScheduleIDPrefix+cacheKey); err != nil {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Failed to schedule Spotify re-resolution for %q: %v", track.Title, err))
+		_ = host.CacheRemove(spotifyRefreshKey(cacheKey))
+	}
+}
+
+// handleSpotifyRefreshCallback retries the direct lookups for a track. A direct link
+// replaces the cached search URL; otherwise the search URL is kept until it expires.
+func handleSpotifyRefreshCallback(scheduleID string) error {
+	cacheKey := strings.TrimPrefix(scheduleID, spotifyRefreshScheduleIDPrefix)
+	value, exists, err := host.CacheGetString(spotifyRefreshKey(cacheKey))
+	if err != nil || !exists {
+		return nil
+	}
+	_ = host.CacheRemove(spotifyRefreshKey(cacheKey))
+
+	var track scrobbler.TrackInfo
+	if err := json.Unmarshal([]byte(value), &track); err != nil {
+		return fmt.Errorf("failed to parse Spotify re-resolution: %w", err)
+	}
+	primary, _ := spotifyTrackKey(track)
+	if lookupSpotifyTrackURL(track, primary, cacheKey) == "" {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("Spotify re-resolution still missed for %q - %q", primary, track.Title))
+	}
+	return nil
 }
@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"strings"
 
 	"github.com/navidrome/navidrome/plugins/pdk/go/host"
 	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
@@ -26,6 +28,25 @@ var _ = Describe("Spotify", func() {
 		)
 	})
 
+	Describe("hashKey", func() {
+		It("returns 128 bits as hex", func() {
+			Expect(hashKey("https://example.com/art.jpg")).To(MatchRegexp(`^[0-9a-f]{32}$`))
+			Expect(hashKey("https://example.com/art.jpg")).To(Equal(hashKey("https://example.com/art.jpg")))
+		})
+
+		It("keeps apart artwork URLs that collided under 64-bit FNV-1a", func() {
+			first := "https://example.com/art/a35cf0c5dc2b2809"
+			second := "https://example.com/art/2cdef8441ac574d3"
+			fnv64a := func(s string) uint64 {
+				h := fnv.New64a()
+				_, _ = h.Write([]byte(s))
+				return h.Sum64()
+			}
+			Expect(fnv64a(first)).To(Equal(fnv64a(second)))
+			Expect(hashKey(first)).ToNot(Equal(hashKey(second)))
+		})
+	})
+
 	Describe("spotifyCacheKey", func() {
 		It("produces identical keys for identical inputs", func() {
 			key1 := spotifyCacheKey("Radiohead", "Karma Police", "OK Computer")
@@ -113,6 +134,35 @@ var _ = Describe("Spotify", func() {
 		})
 	})
 
+	Describe("normalizeLookupTitle", func() {
+		BeforeEach(func() {
+			pdk.ResetMock()
+			pdk.PDKMock.On("GetConfig", titleStripPatternsKey).Return("feat., ft., remaster, live", true)
+		})
+
+		DescribeTable("strips configured suffixes",
+			func(title, expected string) {
+				Expect(normalizeLookupTitle(title)).To(Equal(expected))
+			},
+			Entry("featured artist", "Song (feat. X)", "Song"),
+			Entry("bracketed featured artist", "Song [ft. X]", "Song"),
+			Entry("dash remaster", "Song - Remastered 2011", "Song"),
+			Entry("stacked suffixes", "Song (feat. X) - Remastered 2011", "Song"),
+			Entry("live recording", "Song (Live at Wembley)", "Song"),
+			Entry("keeps unmatched suffixes", "Song (Acoustic)", "Song (Acoustic)"),
+			Entry("matches at word starts only", "Song (Olive Tree)", "Song (Olive Tree)"),
+			Entry("stops at the first unmatched suffix", "Song (Acoustic) (feat. X)", "Song (Acoustic)"),
+			Entry("keeps titles that are all suffix", "(Live)", "(Live)"),
+			Entry("plain title", "Song", "Song"),
+		)
+
+		It("leaves titles alone without patterns", func() {
+			pdk.ResetMock()
+			pdk.PDKMock.On("GetConfig", titleStripPatternsKey).Return("", false)
+			Expect(normalizeLookupTitle("Song (feat. X)")).To(Equal("Song (feat. X)"))
+		})
+	})
+
 	Describe("resolveSpotifyURL", func() {
 		BeforeEach(func() {
 			pdk.ResetMock()
@@ -121,6 +171,12 @@ var _ = Describe("Spotify", func() {
 			host.HTTPMock.ExpectedCalls = nil
 			host.HTTPMock.Calls = nil
 			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", listenBrainzBaseURLKey).Return("", false).Maybe()
+			pdk.PDKMock.On("GetConfig", releaseMBIDLookupKey).Return("", false).Maybe()
+			pdk.PDKMock.On("GetConfig", spotifyMBIDLookupKey).Return("", false).Maybe()
+			pdk.PDKMock.On("GetConfig", spotifyMetadataLookupKey).Return("", false).Maybe()
+			pdk.PDKMock.On("GetConfig", spotifySearchFallbackKey).Return("", false).Maybe()
+			pdk.PDKMock.On("GetConfig", titleStripPatternsKey).Return("", false).Maybe()
 		})
 
 		It("returns cached URL on cache hit", func() {
@@ -155,6 +211,32 @@ var _ = Describe("Spotify", func() {
 			host.CacheMock.AssertCalled(GinkgoT(), "SetString", spotifyURLKey, "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz", spotifyCacheTTLHit)
 		})
 
+		It("uses the configured ListenBrainz base URL", func() {
+			pdk.ResetMock()
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", listenBrainzBaseURLKey).Return("https://lb.example.com/labs/", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://lb.example.com/labs/spotify-id-from-mbid/json"
+			})).Return(&host.HTTPResponse{StatusCode: 404, Body: []byte(`[]`)}, nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return req.URL == "https://lb.example.com/labs/spotify-id-from-metadata/json"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["4wlLbLeDWbA6TzwZFp1UaK"]}]`)}, nil)
+
+			url := resolveSpotifyURL(scrobbler.TrackInfo{
+				Title:          "Karma Police",
+				Artist:         "Radiohead",
+				Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:          "OK Computer",
+				MBZRecordingID: "mbid-123",
+			})
+			Expect(url).To(Equal("https://open.spotify.com/track/4wlLbLeDWbA6TzwZFp1UaK"))
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 2)
+		})
+
 		It("falls back to metadata lookup when MBID fails", func() {
 			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
 			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
@@ -199,6 +281,218 @@ var _ = Describe("Spotify", func() {
 			host.CacheMock.AssertCalled(GinkgoT(), "SetString", spotifyURLKey, mock.Anything, spotifyCacheTTLMiss)
 		})
 
+		It("looks up multi-artist tracks with the full artist credit", func() {
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				return strings.Contains(string(req.Body), `"artist_name":"Lil Baby & Drake"`)
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["6vN77lE9LK6HP2DewaN6HZ"]}]`)}, nil)
+
+			url := resolveSpotifyURL(scrobbler.TrackInfo{
+				Title:   "Yes Indeed",
+				Artist:  "Lil Baby • Drake",
+				Artists: []scrobbler.ArtistRef{{Name: "Lil Baby"}, {Name: "Drake"}},
+			})
+			Expect(url).To(Equal("https://open.spotify.com/track/6vN77lE9LK6HP2DewaN6HZ"))
+		})
+
+		Context("with release MBID lookup", func() {
+			// The release-constrained query matches the right edition; the plain one doesn't
+			setupMetadataMocks := func() {
+				host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+				host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+				host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+					return strings.Contains(string(req.Body), `"release_mbid":"release-123"`)
+				})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["1bSpwPhAxZwlR2enJJsv7U"]}]`)}, nil)
+				host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[]`)}, nil)
+			}
+			track := scrobbler.TrackInfo{
+				Title:      "Karma Police",
+				Artists:    []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:      "OK Computer",
+				MBZAlbumID: "release-123",
+			}
+
+			It("adds the release MBID to the metadata query when enabled", func() {
+				pdk.ResetMock()
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				pdk.PDKMock.On("GetConfig", releaseMBIDLookupKey).Return("true", true)
+				pdk.PDKMock.On("GetConfig", listenBrainzBaseURLKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+				setupMetadataMocks()
+
+				url := resolveSpotifyURL(track)
+				Expect(url).To(Equal("https://open.spotify.com/track/1bSpwPhAxZwlR2enJJsv7U"))
+			})
+
+			It("leaves the release MBID out when disabled", func() {
+				setupMetadataMocks()
+
+				url := resolveSpotifyURL(track)
+				Expect(url).To(HavePrefix("https://open.spotify.com/search/"))
+			})
+
+			It("doesn't use the release MBID when a recording MBID is present", func() {
+				pdk.ResetMock()
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				pdk.PDKMock.On("GetConfig", releaseMBIDLookupKey).Return("true", true)
+				pdk.PDKMock.On("GetConfig", listenBrainzBaseURLKey).Return("", false)
+				pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+				setupMetadataMocks()
+
+				withRecording := track
+				withRecording.MBZRecordingID = "recording-123"
+				url := resolveSpotifyURL(withRecording)
+				Expect(url).To(HavePrefix("https://open.spotify.com/search/"))
+			})
+		})
+
+		DescribeTable("enabled lookup steps",
+			func(mbid, metadata, search bool, expected string) {
+				pdk.ResetMock()
+				pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+				pdk.PDKMock.On("GetConfig", spotifyMBIDLookupKey).Return(fmt.Sprint(mbid), true)
+				pdk.PDKMock.On("GetConfig", spotifyMetadataLookupKey).Return(fmt.Sprint(metadata), true)
+				pdk.PDKMock.On("GetConfig", spotifySearchFallbackKey).Return(fmt.Sprint(search), true)
+				pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+				host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+				host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+
+				mbidReq := mock.MatchedBy(func(req host.HTTPRequest) bool { return strings.HasSuffix(req.URL, "/spotify-id-from-mbid/json") })
+				metadataReq := mock.MatchedBy(func(req host.HTTPRequest) bool { return strings.HasSuffix(req.URL, "/spotify-id-from-metadata/json") })
+				host.HTTPMock.On("Send", mbidReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["63OQupATfueTdZMWIV7nzz"]}]`)}, nil).Maybe()
+				host.HTTPMock.On("Send", metadataReq).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["4wlLbLeDWbA6TzwZFp1UaK"]}]`)}, nil).Maybe()
+
+				url := resolveSpotifyURL(scrobbler.TrackInfo{
+					Title:          "Karma Police",
+					Artist:         "Radiohead",
+					Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
+					Album:          "OK Computer",
+					MBZRecordingID: "mbid-123",
+				})
+				Expect(url).To(Equal(expected))
+				if !mbid {
+					host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mbidReq)
+				}
+				if !metadata {
+					host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", metadataReq)
+				}
+			},
+			Entry("all steps", true, true, true, "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"),
+			Entry("MBID and metadata", true, true, false, "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"),
+			Entry("MBID and search", true, false, true, "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"),
+			Entry("MBID only", true, false, false, "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"),
+			Entry("metadata and search", false, true, true, "https://open.spotify.com/track/4wlLbLeDWbA6TzwZFp1UaK"),
+			Entry("metadata only", false, true, false, "https://open.spotify.com/track/4wlLbLeDWbA6TzwZFp1UaK"),
+			Entry("search only", false, false, true, "https://open.spotify.com/search/Radiohead%20Karma%20Police"),
+			Entry("no steps", false, false, false, ""),
+		)
+
+		It("skips to search when the MBID misses and metadata lookup is disabled", func() {
+			pdk.ResetMock()
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", spotifyMetadataLookupKey).Return("false", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
+			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[]`)}, nil)
+
+			url := resolveSpotifyURL(scrobbler.TrackInfo{
+				Title:          "Karma Police",
+				Artist:         "Radiohead",
+				Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:          "OK Computer",
+				MBZRecordingID: "mbid-123",
+			})
+			Expect(url).To(HavePrefix("https://open.spotify.com/search/"))
+			host.HTTPMock.AssertNumberOfCalls(GinkgoT(), "Send", 1)
+		})
+
+		It("caches by MBID when the recording MBID is present", func() {
+			host.CacheMock.On("GetString", "spotify.mbid.mbid-123").Return("https://open.spotify.com/track/byMBID", true, nil)
+
+			url := resolveSpotifyURL(scrobbler.TrackInfo{
+				Title:          "Karma Police (Remastered)",
+				Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:          "OK Computer OKNOTOK",
+				MBZRecordingID: "mbid-123",
+			})
+			Expect(url).To(Equal("https://open.spotify.com/track/byMBID"))
+			host.CacheMock.AssertNotCalled(GinkgoT(), "GetString", spotifyCacheKey("Radiohead", "Karma Police (Remastered)", "OK Computer OKNOTOK"))
+		})
+
+		It("keeps misses with the search fallback disabled apart from search URLs", func() {
+			pdk.ResetMock()
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", spotifySearchFallbackKey).Return("false", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			host.CacheMock.On("GetString", "spotify.mbid.mbid-123.nosearch").Return("", false, nil)
+			host.CacheMock.On("SetString", "spotify.mbid.mbid-123.nosearch", "", spotifyCacheTTLMiss).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[]`)}, nil)
+
+			url := resolveSpotifyURL(scrobbler.TrackInfo{
+				Title:          "Karma Police",
+				Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:          "OK Computer",
+				MBZRecordingID: "mbid-123",
+			})
+			Expect(url).To(BeEmpty())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "GetString", "spotify.mbid.mbid-123")
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", "spotify.mbid.mbid-123", mock.Anything, mock.Anything)
+		})
+
+		It("stores resolutions under the MBID key", func() {
+			host.CacheMock.On("GetString", "spotify.mbid.mbid-123").Return("", false, nil)
+			host.CacheMock.On("SetString", "spotify.mbid.mbid-123", mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["63OQupATfueTdZMWIV7nzz"]}]`)}, nil)
+
+			resolveSpotifyURL(scrobbler.TrackInfo{
+				Title:          "Karma Police",
+				Artists:        []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:          "OK Computer",
+				MBZRecordingID: "mbid-123",
+			})
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", "spotify.mbid.mbid-123", "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz", spotifyCacheTTLHit)
+		})
+
+		It("falls back to the text key without an MBID", func() {
+			key := spotifyCacheKey("Radiohead", "Karma Police", "OK Computer")
+			host.CacheMock.On("GetString", key).Return("https://open.spotify.com/track/byText", true, nil)
+
+			url := resolveSpotifyURL(scrobbler.TrackInfo{
+				Title:   "Karma Police",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:   "OK Computer",
+			})
+			Expect(url).To(Equal("https://open.spotify.com/track/byText"))
+		})
+
+		It("looks up the normalized title but caches under the original", func() {
+			pdk.ResetMock()
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", titleStripPatternsKey).Return("feat., remaster", true)
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false)
+			cacheKey := spotifyCacheKey("Radiohead", "Karma Police (feat. X) - Remastered 2011", "OK Computer")
+			host.CacheMock.On("GetString", cacheKey).Return("", false, nil)
+			host.CacheMock.On("SetString", cacheKey, mock.Anything, mock.Anything).Return(nil)
+
+			var payload string
+			host.HTTPMock.On("Send", mock.MatchedBy(func(req host.HTTPRequest) bool {
+				payload = string(req.Body)
+				return req.URL == "https://labs.api.listenbrainz.org/spotify-id-from-metadata/json"
+			})).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["63OQupATfueTdZMWIV7nzz"]}]`)}, nil)
+
+			url := resolveSpotifyURL(scrobbler.TrackInfo{
+				Title:   "Karma Police (feat. X) - Remastered 2011",
+				Artist:  "Radiohead",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:   "OK Computer",
+			})
+			Expect(url).To(Equal("https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz"))
+			Expect(payload).To(ContainSubstring(`"track_name":"Karma Police"`))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", cacheKey, url, spotifyCacheTTLHit)
+		})
+
 		It("uses Artists[0] for primary artist", func() {
 			host.CacheMock.On("GetString", spotifyURLKey).Return("", false, nil)
 			host.CacheMock.On("SetString", spotifyURLKey, mock.Anything, mock.Anything).Return(nil)
@@ -215,5 +509,86 @@ var _ = Describe("Spotify", func() {
 			})
 			Expect(url).To(Equal("https://open.spotify.com/track/4tIGK5G9hNDA50ZdGioZRG"))
 		})
+
+		Context("with a cached search URL", func() {
+			track := scrobbler.TrackInfo{
+				Title:   "Karma Police",
+				Artist:  "Radiohead",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:   "OK Computer",
+			}
+			searchURL := spotifySearchURL("Radiohead", "Karma Police")
+			refreshKey := spotifyRefreshKey(spotifyCacheKey("Radiohead", "Karma Police", "OK Computer"))
+
+			BeforeEach(func() {
+				host.SchedulerMock.ExpectedCalls = nil
+				host.SchedulerMock.Calls = nil
+				host.CacheMock.On("GetString", spotifyURLKey).Return(searchURL, true, nil)
+			})
+
+			It("schedules a background re-resolution when the draw hits", func() {
+				pinRand(0)
+				host.CacheMock.On("SetString", refreshKey, mock.Anything, spotifyRefreshTTL).Return(nil)
+				host.SchedulerMock.On("ScheduleOneTime", int32(1), payloadSpotifyRefresh, mock.Anything).Return("", nil)
+
+				Expect(resolveSpotifyURL(track)).To(Equal(searchURL))
+				host.SchedulerMock.AssertCalled(GinkgoT(), "ScheduleOneTime", int32(1), payloadSpotifyRefresh, spotifyRefreshScheduleIDPrefix+spotifyCacheKey("Radiohead", "Karma Police", "OK Computer"))
+				host.HTTPMock.AssertNotCalled(GinkgoT(), "Send", mock.Anything)
+			})
+
+			It("serves the search URL without scheduling otherwise", func() {
+				pinRand(1)
+
+				Expect(resolveSpotifyURL(track)).To(Equal(searchURL))
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			It("never re-resolves cached direct links", func() {
+				pinRand(0)
+				host.CacheMock.ExpectedCalls = nil
+				host.CacheMock.On("GetString", spotifyURLKey).Return("https://open.spotify.com/track/cached123", true, nil)
+
+				Expect(resolveSpotifyURL(track)).To(Equal("https://open.spotify.com/track/cached123"))
+				host.SchedulerMock.AssertNotCalled(GinkgoT(), "ScheduleOneTime", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+	})
+
+	Describe("handleSpotifyRefreshCallback", func() {
+		cacheKey := spotifyCacheKey("Radiohead", "Karma Police", "OK Computer")
+
+		BeforeEach(func() {
+			pdk.ResetMock()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.Calls = nil
+			host.HTTPMock.ExpectedCalls = nil
+			host.HTTPMock.Calls = nil
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+			pdk.PDKMock.On("GetConfig", mock.Anything).Return("", false).Maybe()
+
+			b, _ := json.Marshal(scrobbler.TrackInfo{
+				Title:   "Karma Police",
+				Artist:  "Radiohead",
+				Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+				Album:   "OK Computer",
+			})
+			host.CacheMock.On("GetString", spotifyRefreshKey(cacheKey)).Return(string(b), true, nil)
+			host.CacheMock.On("Remove", spotifyRefreshKey(cacheKey)).Return(nil)
+		})
+
+		It("replaces the search URL with a direct link", func() {
+			host.CacheMock.On("SetString", cacheKey, mock.Anything, mock.Anything).Return(nil)
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[{"spotify_track_ids":["63OQupATfueTdZMWIV7nzz"]}]`)}, nil)
+
+			Expect(handleSpotifyRefreshCallback(spotifyRefreshScheduleIDPrefix + cacheKey)).To(Succeed())
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", cacheKey, "https://open.spotify.com/track/63OQupATfueTdZMWIV7nzz", spotifyCacheTTLHit)
+		})
+
+		It("keeps the search URL when the lookups still miss", func() {
+			host.HTTPMock.On("Send", mock.Anything).Return(&host.HTTPResponse{StatusCode: 200, Body: []byte(`[]`)}, nil)
+
+			Expect(handleSpotifyRefreshCallback(spotifyRefreshScheduleIDPrefix + cacheKey)).To(Succeed())
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
 	})
 })
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// templatePlaceholder matches a placeholder such as {title} in a text template.
+var templatePlaceholder = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// renderTemplate replaces the placeholders of a text template with the track's
+// details. Unknown placeholders, and details the track doesn't have, render empty.
+// Details needing a lookup, such as {year} or {user}, are only looked up when used.
+func renderTemplate(template, username string, track scrobbler.TrackInfo) string {
+	rendered := templatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch strings.Trim(placeholder, "{}") {
+		case "title", "track":
+			return track.Title
+		case "artist":
+			return displayArtist(track)
+		case "albumartist":
+			return track.AlbumArtist
+		case "album":
+			return track.Album
+		case "year":
+			return trackYear(username, track.ID)
+		case "tracknumber":
+			if track.TrackNumber > 0 {
+				return strconv.Itoa(int(track.TrackNumber))
+			}
+		case "user":
+			return userDisplayName(username)
+		}
+		return ""
+	})
+	return strings.TrimSpace(rendered)
+}
+
+// resolveTextTemplate renders the template configured under key, returning fallback
+// when none is configured or the template renders empty for this track.
+func resolveTextTemplate(key, fallback, username string, track scrobbler.TrackInfo) string {
+	template, _ := pdk.GetConfig(key)
+	if strings.TrimSpace(template) == "" {
+		return fallback
+	}
+	if rendered := renderTemplate(template, username, track); rendered != "" {
+		return rendered
+	}
+	return fallback
+}
+
+// trackYear looks up the track's release year, which Navidrome doesn't include in
+// playback reports. It returns "" when the year is unknown.
+func trackYear(username, trackID string) string {
+	if trackID == "" {
+		return ""
+	}
+	if song, ok := trackSong(username, trackID); ok && song.Year > 0 {
+		return strconv.Itoa(song.Year)
+	}
+	return ""
+}
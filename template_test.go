@@ -0,0 +1,98 @@
+package main
+
+import (
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("text templates", func() {
+	track := scrobbler.TrackInfo{
+		ID:          "track1",
+		Title:       "Karma Police",
+		Artist:      "Radiohead",
+		AlbumArtist: "Radiohead",
+		Album:       "OK Computer",
+		TrackNumber: 6,
+	}
+
+	BeforeEach(func() {
+		pdk.ResetMock()
+		host.CacheMock.ExpectedCalls = nil
+		host.CacheMock.Calls = nil
+		host.SubsonicAPIMock.ExpectedCalls = nil
+		host.SubsonicAPIMock.Calls = nil
+		pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		pdk.PDKMock.On("GetConfig", artistSeparatorKey).Return("", false).Maybe()
+		pdk.PDKMock.On("GetConfig", joinArtistsKey).Return("", false).Maybe()
+		host.CacheMock.On("GetString", "discord.song.track1").Return(`{"year":1997}`, true, nil).Maybe()
+	})
+
+	DescribeTable("renderTemplate",
+		func(template string, track scrobbler.TrackInfo, expected string) {
+			Expect(renderTemplate(template, "testuser", track)).To(Equal(expected))
+		},
+		Entry("album and year", "{album} — {year}", track, "OK Computer — 1997"),
+		Entry("title and album", "{title} ({album})", track, "Karma Police (OK Computer)"),
+		Entry("track number", "{tracknumber}. {track}", track, "6. Karma Police"),
+		Entry("album artist", "{artist} on {albumartist}'s album", track, "Radiohead on Radiohead's album"),
+		Entry("the tagged artist of a multi-artist track", "{artist}",
+			scrobbler.TrackInfo{Artist: "A feat. B", Artists: []scrobbler.ArtistRef{{Name: "A"}, {Name: "B"}}}, "A feat. B"),
+		Entry("unknown placeholders render empty", "{title}{mood}", track, "Karma Police"),
+		Entry("missing fields render empty", "{title} ({album})", scrobbler.TrackInfo{Title: "Loose Track"}, "Loose Track ()"),
+		Entry("a missing track number renders empty", "{tracknumber} {title}", scrobbler.TrackInfo{Title: "Loose Track"}, "Loose Track"),
+		Entry("text without placeholders", "Now playing", track, "Now playing"),
+	)
+
+	It("renders all artists of a multi-artist track when enabled", func() {
+		pdk.PDKMock.ExpectedCalls = nil
+		pdk.PDKMock.On("GetConfig", joinArtistsKey).Return("true", true)
+		pdk.PDKMock.On("GetConfig", artistSeparatorKey).Return("", false)
+		track := scrobbler.TrackInfo{Artist: "A feat. B", Artists: []scrobbler.ArtistRef{{Name: "A"}, {Name: "B"}}}
+		Expect(renderTemplate("{artist}", "testuser", track)).To(Equal("A & B"))
+	})
+
+	DescribeTable("resolveTextTemplate",
+		func(template, expected string) {
+			pdk.PDKMock.On("GetConfig", detailsTemplateKey).Return(template, template != "")
+			Expect(resolveTextTemplate(detailsTemplateKey, "Fallback", "testuser", scrobbler.TrackInfo{Title: "Loose Track"})).To(Equal(expected))
+		},
+		Entry("uses the fallback when unset", "", "Fallback"),
+		Entry("renders the template", "{title}!", "Loose Track!"),
+		Entry("uses the fallback when the template renders empty", "{album}", "Fallback"),
+	)
+
+	Describe("trackYear", func() {
+		It("looks up and caches the year", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.song.track2").Return("", false, nil)
+			host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track2").
+				Return(`{"subsonic-response":{"status":"ok","song":{"id":"track2","year":2004}}}`, nil)
+			host.CacheMock.On("SetString", "discord.song.track2", `{"year":2004}`, songCacheTTL).Return(nil)
+
+			Expect(trackYear("testuser", "track2")).To(Equal("2004"))
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		It("caches the details of tracks without a year", func() {
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.On("GetString", "discord.song.track2").Return("", false, nil)
+			host.SubsonicAPIMock.On("Call", "/getSong?u=testuser&id=track2").
+				Return(`{"subsonic-response":{"status":"ok","song":{"id":"track2"}}}`, nil)
+			host.CacheMock.On("SetString", "discord.song.track2", `{}`, songCacheTTL).Return(nil)
+
+			Expect(trackYear("testuser", "track2")).To(BeEmpty())
+			host.CacheMock.AssertExpectations(GinkgoT())
+		})
+
+		It("doesn't look up the year unless the template uses it", func() {
+			Expect(renderTemplate("{title}", "testuser", track)).To(Equal("Karma Police"))
+			host.CacheMock.AssertNotCalled(GinkgoT(), "GetString", "discord.song.track1")
+			host.SubsonicAPIMock.AssertNotCalled(GinkgoT(), "Call", mock.Anything)
+		})
+	})
+})
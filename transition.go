@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// Scheduler callback payload for presence updates held back by the transition grace
+const payloadPendingPresence = "pendingpresence"
+
+// pendingPresenceScheduleIDPrefix prefixes the username in pending presence schedule IDs.
+const pendingPresenceScheduleIDPrefix = "pendingpresence."
+
+// maxTransitionGrace caps the transition grace, in seconds. Longer waits would make
+// every track change feel sluggish.
+const maxTransitionGrace = 5
+
+// pendingPresenceKey returns the cache key holding the latest held back report for a user.
+func pendingPresenceKey(username string) string {
+	return fmt.Sprintf("discord.pending.%s", username)
+}
+
+// resolveTransitionGrace returns how long playing reports are held back before the
+// presence is sent, in seconds. 0 disables the grace.
+func resolveTransitionGrace() int32 {
+	option, _ := pdk.GetConfig(transitionGraceKey)
+	option = strings.TrimSpace(option)
+	if option == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(option)
+	if err != nil || seconds < 0 {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Invalid transition grace %q, sending presence right away", option))
+		return 0
+	}
+	return int32(min(seconds, maxTransitionGrace))
+}
+
+// presentedTrackKey returns the cache key holding the ID of the track last shown to a user.
+func presentedTrackKey(username string) string {
+	return fmt.Sprintf("discord.presentedtrack.%s", username)
+}
+
+// storePresentedTrack remembers the track whose presence was just sent to a user.
+func storePresentedTrack(username, trackID string) {
+	_ = host.CacheSetString(presentedTrackKey(username), trackID, lastPresenceTTL)
+}
+
+// isPresentedTrack reports whether trackID is the track last shown to a user.
+func isPresentedTrack(username, trackID string) bool {
+	presented, exists, err := host.CacheGetString(presentedTrackKey(username))
+	return err == nil && exists && trackID != "" && presented == trackID
+}
+
+// deferPresence holds back a playing report for the transition grace, so a burst of
+// track changes (like short interludes on an album) results in a single presence
+// update for the latest track. Reports for the track already shown, such as position
+// updates or a resume after a pause, aren't track changes and are sent right away. It
+// returns false when the report should be sent now.
+func deferPresence(input scrobbler.PlaybackReportRequest) bool {
+	if input.State != statePlaying {
+		return false
+	}
+	grace := resolveTransitionGrace()
+	if grace == 0 || isPresentedTrack(input.Username, input.Track.ID) {
+		return false
+	}
+	b, err := json.Marshal(input)
+	if err != nil {
+		return false
+	}
+	if err := host.CacheSetString(pendingPresenceKey(input.Username), string(b), int64(grace)+60); err != nil {
+		return false
+	}
+	if _, err := host.SchedulerScheduleOneTime(grace, payloadPendingPresence, pendingPresenceScheduleIDPrefix+input.Username); err != nil {
+		pdk.Log(pdk.LogWarn, fmt.Sprintf("Failed to schedule presence for user %s: %v", input.Username, err))
+		_ = host.CacheRemove(pendingPresenceKey(input.Username))
+		return false
+	}
+	pdk.Log(pdk.LogDebug, fmt.Sprintf("Holding presence for user %s for %ds in case the track changes again", input.Username, grace))
+	return true
+}
+
+// cancelPendingPresence drops a held back report for a user, as a newer report supersedes it.
+func cancelPendingPresence(username string) {
+	if _, exists, err := host.CacheGetString(pendingPresenceKey(username)); err != nil || !exists {
+		return
+	}
+	_ = host.CacheRemove(pendingPresenceKey(username))
+	_ = host.SchedulerCancelSchedule(pendingPresenceScheduleIDPrefix + username)
+}
+
+// handlePendingPresenceCallback sends the latest report held back by the transition grace.
+func (p *discordPlugin) handlePendingPresenceCallback(scheduleID string) error {
+	username := strings.TrimPrefix(scheduleID, pendingPresenceScheduleIDPrefix)
+	value, exists, err := host.CacheGetString(pendingPresenceKey(username))
+	if err != nil || !exists {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("No pending presence for user %s", username))
+		return nil
+	}
+	_ = host.CacheRemove(pendingPresenceKey(username))
+
+	var input scrobbler.PlaybackReportRequest
+	if err := json.Unmarshal([]byte(value), &input); err != nil {
+		return fmt.Errorf("failed to parse pending presence: %w", err)
+	}
+	err = p.handlePlayingOrPaused(input, false)
+	if err != nil && isTransientError(err) {
+		scheduleRetry(input)
+	}
+	if err != nil {
+		recordLastError(username, err)
+	}
+	return err
+}
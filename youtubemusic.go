@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+)
+
+// Link services that can feed the activity button
+const (
+	linkServiceSpotify      = "spotify"
+	linkServiceYouTubeMusic = "youtubemusic"
+	linkServiceSongLink     = "songlink"
+)
+
+// ytmCacheTTL is how long a YouTube Music URL is cached: 30 days
+const ytmCacheTTL int64 = 30 * 24 * 60 * 60
+
+// ytmSearchURL builds a YouTube Music search URL from one or more terms.
+// Empty terms are ignored. Returns "" if all terms are empty.
+func ytmSearchURL(terms ...string) string {
+	query := strings.Join(strings.Fields(strings.Join(terms, " ")), " ")
+	if query == "" {
+		return ""
+	}
+	return "https://music.youtube.com/search?q=" + url.QueryEscape(query)
+}
+
+// ytmCacheKey returns a deterministic cache key for a track's YouTube Music URL,
+// following the same scheme as spotifyCacheKey.
+func ytmCacheKey(artist, title, album string) string {
+	return "ytm.url." + trackHash(artist, title, album)
+}
+
+// resolveYouTubeMusicURL returns a YouTube Music URL for the track. There is no
+// lookup service for YouTube Music, so this is always a search for the artist and
+// title, which is guaranteed to exist. Results are cached.
+func resolveYouTubeMusicURL(track scrobbler.TrackInfo) string {
+	primary, _ := spotifyTrackKey(track)
+	cacheKey := ytmCacheKey(primary, track.Title, track.Album)
+
+	if cached, exists, err := host.CacheGetString(cacheKey); err == nil && exists {
+		pdk.Log(pdk.LogDebug, fmt.Sprintf("YouTube Music URL cache hit for %q - %q → %s", primary, track.Title, cached))
+		return cached
+	}
+
+	searchURL := ytmSearchURL(track.Artist, track.Title)
+	if searchURL != "" {
+		_ = host.CacheSetString(cacheKey, searchURL, ytmCacheTTL)
+	}
+	return searchURL
+}
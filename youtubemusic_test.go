@@ -0,0 +1,65 @@
+package main
+
+import (
+	"github.com/navidrome/navidrome/plugins/pdk/go/host"
+	"github.com/navidrome/navidrome/plugins/pdk/go/pdk"
+	"github.com/navidrome/navidrome/plugins/pdk/go/scrobbler"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("YouTube Music", func() {
+	Describe("ytmSearchURL", func() {
+		DescribeTable("constructs YouTube Music search URL",
+			func(expected string, terms ...string) {
+				Expect(ytmSearchURL(terms...)).To(Equal(expected))
+			},
+			Entry("artist and title", "https://music.youtube.com/search?q=Radiohead+Karma+Police", "Radiohead", "Karma Police"),
+			Entry("escapes special characters", "https://music.youtube.com/search?q=AC%2FDC+Rock+%26+Roll", "AC/DC", "Rock & Roll"),
+			Entry("collapses blank terms", "https://music.youtube.com/search?q=Karma+Police", "", "Karma Police"),
+			Entry("all empty", "", "", ""),
+		)
+	})
+
+	Describe("ytmCacheKey", func() {
+		It("uses the ytm prefix with the Spotify key scheme", func() {
+			key := ytmCacheKey("Radiohead", "Karma Police", "OK Computer")
+			Expect(key).To(HavePrefix("ytm.url."))
+			Expect(key).To(HaveSuffix(spotifyCacheKey("radiohead", "karma police", "ok computer")[len("spotify.url."):]))
+		})
+	})
+
+	Describe("resolveYouTubeMusicURL", func() {
+		track := scrobbler.TrackInfo{
+			Title:   "Karma Police",
+			Artist:  "Radiohead",
+			Artists: []scrobbler.ArtistRef{{Name: "Radiohead"}},
+			Album:   "OK Computer",
+		}
+		cacheKey := ytmCacheKey("Radiohead", "Karma Police", "OK Computer")
+
+		BeforeEach(func() {
+			pdk.ResetMock()
+			host.CacheMock.ExpectedCalls = nil
+			host.CacheMock.Calls = nil
+			pdk.PDKMock.On("Log", mock.Anything, mock.Anything).Maybe()
+		})
+
+		It("returns the cached URL on a cache hit", func() {
+			host.CacheMock.On("GetString", cacheKey).Return("https://music.youtube.com/watch?v=cached", true, nil)
+
+			Expect(resolveYouTubeMusicURL(track)).To(Equal("https://music.youtube.com/watch?v=cached"))
+			host.CacheMock.AssertNotCalled(GinkgoT(), "SetString", mock.Anything, mock.Anything, mock.Anything)
+		})
+
+		It("builds and caches a search URL on a cache miss", func() {
+			host.CacheMock.On("GetString", cacheKey).Return("", false, nil)
+			host.CacheMock.On("SetString", cacheKey, mock.Anything, ytmCacheTTL).Return(nil)
+
+			Expect(resolveYouTubeMusicURL(track)).To(Equal("https://music.youtube.com/search?q=Radiohead+Karma+Police"))
+			host.CacheMock.AssertCalled(GinkgoT(), "SetString", cacheKey, "https://music.youtube.com/search?q=Radiohead+Karma+Police", ytmCacheTTL)
+		})
+	})
+})